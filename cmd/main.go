@@ -2,58 +2,418 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/vvjke314/vk-test-03-2025/config"
+	"github.com/vvjke314/vk-test-03-2025/internal/backup"
+	"github.com/vvjke314/vk-test-03-2025/internal/cache"
 	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+	"github.com/vvjke314/vk-test-03-2025/internal/metrics"
+	"github.com/vvjke314/vk-test-03-2025/internal/ratelimit"
 	"github.com/vvjke314/vk-test-03-2025/internal/repository"
+	"github.com/vvjke314/vk-test-03-2025/internal/tracing"
 	"github.com/vvjke314/vk-test-03-2025/internal/usecases"
+	"github.com/vvjke314/vk-test-03-2025/internal/validation"
+	"github.com/vvjke314/vk-test-03-2025/internal/watch"
+	"github.com/vvjke314/vk-test-03-2025/pkg/grpcserver"
+	"github.com/vvjke314/vk-test-03-2025/pkg/handlers"
+	"github.com/vvjke314/vk-test-03-2025/pkg/memcacheserver"
+	"github.com/vvjke314/vk-test-03-2025/pkg/respserver"
 	api "github.com/vvjke314/vk-test-03-2025/pkg/routes"
 )
 
 func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
 	// cfg init
 	loader := config.NewLoader()
 	loader.Load()
 
+	appCfg := config.NewAppConfig()
+	if err := appCfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
 	// repository config init
 	repoCfg := config.NewTnConfig()
 
 	// logger init
-	appLogger, err := logger.NewSimpleLogger("application.log")
+	appLogger, err := newLogger(appCfg)
 	if err != nil {
-		log.Fatalf("error while getting log while: %v", err)
+		return fmt.Errorf("error while getting log while: %w", err)
 	}
 	defer appLogger.Close()
 
+	// tracing init
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		ServiceName: "vk-test-app",
+		Exporter:    appCfg.Tracing.Exporter,
+		Endpoint:    appCfg.Tracing.Endpoint,
+		Insecure:    appCfg.Tracing.Insecure,
+		File:        appCfg.Tracing.File,
+		SampleRatio: appCfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		appLogger.Error("error while initing tracing", logger.Err(err))
+		return fmt.Errorf("error initing tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			appLogger.Error("error while flushing spans", logger.Err(err))
+		}
+	}()
+
 	// init tarantool repository
 	repo := repository.NewTnRepository()
-	ctx := context.Background()
-	if err := repo.Init(ctx, repoCfg, appLogger); err != nil {
-		appLogger.Error(fmt.Sprintf("error while initing repository: %v", err))
-		log.Fatalf("error initing repository: %v", err)
+	if err := repo.Init(context.Background(), repoCfg, appLogger); err != nil {
+		appLogger.Error("error while initing repository", logger.Err(err))
+		return fmt.Errorf("error initing repository: %w", err)
 	}
 	defer repo.Close()
 
+	// expose storage state on /metrics
+	if err := metrics.RegisterStorage(repo, appCfg.HealthTimeout); err != nil {
+		return fmt.Errorf("error registering storage metrics: %w", err)
+	}
+
 	// use cases initsialize
-	uc := usecases.NewKeyValueUseCase(repo)
+	var store cache.Repository = repo
+	var cached *cache.CachedRepository
+	if appCfg.Cache.MaxSizeMB > 0 {
+		if cached, err = newCache(repo, appCfg.Cache, appLogger); err != nil {
+			return err
+		}
+		store = cached
+	}
+	hub, stopWatch := watchChanges(repo, cached, appLogger)
+	defer stopWatch()
+	keyPattern, err := appCfg.Limits.KeyPattern()
+	if err != nil {
+		return fmt.Errorf("invalid KEYCHARSET: %w", err)
+	}
+	uc := usecases.NewKeyValueUseCase(store, validation.Rules{
+		MaxKeyLen:     int(appCfg.Limits.MaxKeyLen),
+		MaxValueBytes: int(appCfg.Limits.MaxValueBytes),
+		KeyPattern:    keyPattern,
+		KeyCharset:    appCfg.Limits.KeyCharset,
+	})
 
 	// HTTP setting up
-	r := api.SetupRoutes(uc)
+	health := handlers.NewHealthHandler(appCfg.HealthTimeout)
+	health.AddCheck("tarantool", repo)
+	admin := handlers.NewAdminHandler(appLogger, appLogger)
+	var backups *handlers.BackupHandler
+	if appCfg.Backup.Dir != "-" {
+		store, err := backup.NewStore(appCfg.Backup.Dir, uc, appLogger)
+		if err != nil {
+			return err
+		}
+		backups = handlers.NewBackupHandler(store, appLogger, appCfg.Backup.Timeout)
+	}
+	// one set of budgets, a client is accounted the same on every front end
+	limits := ratelimit.New(appCfg.RateLimits)
+	r := api.SetupRoutes(api.Options{
+		UseCase:    uc,
+		Health:     health,
+		Admin:      admin,
+		Backup:     backups,
+		Logger:     appLogger,
+		Timeouts:   appCfg.Timeouts,
+		Limits:     limits,
+		RateLimits: appCfg.RateLimits,
+		MaxBody:    int64(appCfg.Limits.MaxBodyBytes),
+		AdminToken: appCfg.AdminToken,
+	})
+
+	// every port is bound before any server starts, so a taken port fails
+	// the start without leaving other servers running
+	var listeners []net.Listener
+	defer func() {
+		// servers close their listeners on shutdown, this covers a failed start
+		for _, lis := range listeners {
+			lis.Close()
+		}
+	}()
+	listen := func(name, port string) (net.Listener, error) {
+		if port == "0" {
+			return nil, nil
+		}
+		lis, err := net.Listen("tcp", ":"+port)
+		if err != nil {
+			return nil, fmt.Errorf("error listening %s port: %w", name, err)
+		}
+		listeners = append(listeners, lis)
+		return lis, nil
+	}
+	httpLis, err := listen("HTTP", appCfg.Port)
+	if err != nil {
+		return err
+	}
+	grpcLis, err := listen("gRPC", appCfg.GRPCPort)
+	if err != nil {
+		return err
+	}
+	respLis, err := listen("RESP", appCfg.RESPPort)
+	if err != nil {
+		return err
+	}
+	memcacheLis, err := listen("memcached", appCfg.MemcachePort)
+	if err != nil {
+		return err
+	}
 
 	// server start
 	server := &http.Server{
-		Addr:    ":" + "8080",
+		Addr:    ":" + appCfg.Port,
 		Handler: r,
 	}
 
-	appLogger.Info("server is up, on port :8080")
-	log.Printf("server is up, on port :8080")
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// reopen log file after external logrotate moved it away
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go func() {
+		for range hup {
+			if err := appLogger.Reopen(); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to reopen log file: %v\n", err)
+				continue
+			}
+			appLogger.Info("log file reopened")
+		}
+	}()
+
+	serverErr := make(chan error, 4)
+	go func() {
+		serverErr <- server.Serve(httpLis)
+	}()
+	// servers are drained in parallel on shutdown
+	servers := []shutdowner{server}
+
+	// gRPC server on its own port shares the use case with HTTP
+	var grpcServer *grpcserver.Server
+	if grpcLis != nil {
+		grpcServer = grpcserver.New(grpcserver.Options{
+			UseCase:  uc,
+			Hub:      hub,
+			Logger:   appLogger,
+			Timeouts: appCfg.Timeouts,
+			Limits:   limits,
+			MaxRecv:  int(appCfg.Limits.MaxBodyBytes),
+		})
+		go func() {
+			serverErr <- grpcServer.Serve(grpcLis)
+		}()
+		servers = append(servers, grpcServer)
+		grpcServer.SetServing(true)
+		appLogger.Info("grpc server is up", logger.F("port", appCfg.GRPCPort))
+	}
+
+	// Redis protocol front end for existing tooling
+	if respLis != nil {
+		respServer := respserver.New(respserver.Options{
+			UseCase:    uc,
+			Logger:     appLogger,
+			Timeouts:   appCfg.Timeouts,
+			Limits:     limits,
+			MaxCommand: int(appCfg.Limits.MaxBodyBytes),
+		})
+		go func() {
+			serverErr <- respServer.Serve(respLis)
+		}()
+		servers = append(servers, respServer)
+		appLogger.Info("resp server is up", logger.F("port", appCfg.RESPPort))
+	}
+
+	// memcached protocol front end for legacy clients
+	if memcacheLis != nil {
+		memcacheServer := memcacheserver.New(memcacheserver.Options{
+			UseCase:  uc,
+			Logger:   appLogger,
+			Timeouts: appCfg.Timeouts,
+			Limits:   limits,
+			MaxItem:  int(appCfg.Limits.MaxBodyBytes),
+		})
+		go func() {
+			serverErr <- memcacheServer.Serve(memcacheLis)
+		}()
+		servers = append(servers, memcacheServer)
+		appLogger.Info("memcache server is up", logger.F("port", appCfg.MemcachePort))
+	}
+
+	health.SetReady(true)
+	appLogger.Info("server is up", logger.F("port", appCfg.Port))
 
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		appLogger.Error(fmt.Sprintf("server error: %v", err))
-		log.Fatalf("server error: %v", err)
+	// a failed server takes the others down the same way a signal does
+	var runErr error
+	select {
+	case err := <-serverErr:
+		if err == nil || errors.Is(err, http.ErrServerClosed) {
+			err = errors.New("server stopped unexpectedly")
+		}
+		appLogger.Error("server error, shutting down", logger.Err(err))
+		runErr = fmt.Errorf("server error: %w", err)
+	case <-ctx.Done():
+		appLogger.Info("shutdown signal received, draining requests")
+	}
+	stop()
+
+	// graceful shutdown: stop advertising readiness, let the orchestrator
+	// notice, then drain in-flight requests before closing the repository
+	health.SetReady(false)
+	if grpcServer != nil {
+		grpcServer.SetServing(false)
+	}
+	time.Sleep(appCfg.ShutdownDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), appCfg.ShutdownTimeout)
+	defer cancel()
+
+	// all servers drain in parallel before the repository is closed
+	shutdownErrs := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func() {
+			shutdownErrs <- srv.Shutdown(shutdownCtx)
+		}()
+	}
+	var shutdownErr error
+	for range servers {
+		if err := <-shutdownErrs; err != nil {
+			appLogger.Error("error while draining requests", logger.Err(err))
+			shutdownErr = err
+		}
+	}
+	if shutdownErr != nil {
+		return errors.Join(runErr, fmt.Errorf("server shutdown: %w", shutdownErr))
+	}
+
+	appLogger.Info("server stopped")
+	return runErr
+}
+
+// shutdowner is a server that drains in-flight requests on Shutdown
+type shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// settings validated by config mapped onto the logger types
+var (
+	overflowPolicies = map[config.LogOverflow]logger.OverflowPolicy{
+		config.LogOverflowBlock: logger.OverflowBlock,
+		config.LogOverflowDrop:  logger.OverflowDrop,
+	}
+	redactModes = map[config.LogRedactMode]logger.RedactMode{
+		config.LogRedactHide:    logger.RedactHide,
+		config.LogRedactHash:    logger.RedactHash,
+		config.LogRedactPreview: logger.RedactPreview,
+	}
+)
+
+// newLogger builds the logging pipeline: optional rotating file,
+// optional asynchronous buffering and the structured JSON logger with
+// value redaction on top
+func newLogger(cfg *config.AppConfig) (*logger.SimpleLogger, error) {
+	redactor, err := logger.NewRedactor(logger.RedactOptions{
+		Mode:        redactModes[cfg.LogRedact.Mode],
+		KeyPatterns: cfg.LogRedact.KeyPatterns,
+		JSONFields:  cfg.LogRedact.JSONFields,
+		PreviewLen:  int(cfg.LogRedact.PreviewLen),
+		HashKey:     []byte(cfg.LogRedact.HashKey),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var out io.Writer = os.Stdout
+	if cfg.LogFile != "-" {
+		rotation := cfg.LogRotation
+		file, err := logger.NewRotatingFile(logger.RotateOptions{
+			Path:       cfg.LogFile,
+			MaxSize:    int64(rotation.MaxSizeMB) << 20,
+			Interval:   rotation.Interval,
+			MaxBackups: int(rotation.MaxBackups),
+			MaxAge:     rotation.MaxAge,
+			Compress:   rotation.Compress,
+		})
+		if err != nil {
+			return nil, err
+		}
+		out = file
+	}
+
+	if cfg.LogAsync.Enabled {
+		async := logger.NewAsyncWriter(out, logger.AsyncOptions{
+			QueueSize:     int(cfg.LogAsync.QueueSize),
+			FlushInterval: cfg.LogAsync.FlushInterval,
+			Overflow:      overflowPolicies[cfg.LogAsync.Overflow],
+		})
+		if err := metrics.RegisterLogWriter(async); err != nil {
+			async.Close()
+			return nil, err
+		}
+		out = async
+	}
+
+	appLogger := logger.NewWriterLogger(out)
+	appLogger.SetRedactor(redactor)
+	appLogger.SetLevel(cfg.LogLevel)
+
+	return appLogger, nil
+}
+
+// newCache wraps repo with a cache invalidated by Tarantool change events
+func newCache(repo *repository.TnRepository, cfg config.Cache, l logger.Logger) (*cache.CachedRepository, error) {
+	cached := cache.New(repo, cache.Options{
+		MaxBytes:    int64(cfg.MaxSizeMB) << 20,
+		TTL:         cfg.TTL,
+		NegativeTTL: cfg.NegativeTTL,
+		LoadTimeout: cfg.LoadTimeout,
+	})
+	if err := metrics.RegisterCache(cached); err != nil {
+		return nil, fmt.Errorf("error registering cache metrics: %w", err)
+	}
+
+	l.Info("cache enabled", logger.F("max_size_mb", cfg.MaxSizeMB), logger.F("ttl", cfg.TTL.String()))
+	return cached, nil
+}
+
+// watchChanges subscribes to Tarantool change events, they invalidate cached
+// (if any) and are fanned out to gRPC Watch calls through the returned hub.
+// Without change events other clients' writes are seen after the TTL and the
+// hub is nil.
+func watchChanges(repo *repository.TnRepository, cached *cache.CachedRepository, l logger.Logger) (*watch.Hub, func()) {
+	hub := watch.NewHub()
+	stop, err := repo.WatchChanges(func(key string) {
+		if cached != nil {
+			cached.Invalidate(key)
+		}
+		hub.Publish(key)
+	}, func() {
+		if cached != nil {
+			cached.Purge()
+		}
+		hub.Reset()
+	})
+	if err != nil {
+		l.Warn("change events unavailable, cached values may be stale up to CACHETTL and gRPC Watch is disabled", logger.Err(err))
+		return nil, func() {}
 	}
+	return hub, stop
 }
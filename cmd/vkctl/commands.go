@@ -0,0 +1,639 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vvjke314/vk-test-03-2025/pkg/client"
+)
+
+func runGet(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("get")
+	if err := a.parse(fs, args, 1, -1); err != nil {
+		return err
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	p, err := a.printer()
+	if err != nil {
+		return err
+	}
+
+	records := make([]record, 0, fs.NArg())
+	for _, key := range fs.Args() {
+		value, err := c.Get(ctx, key)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		records = append(records, record{Key: key, Value: value})
+	}
+	return p.print(records...)
+}
+
+func runCreate(ctx context.Context, a *app, args []string) error {
+	return runWrite(ctx, a, "create", args, func(c *client.Client, key string, value json.RawMessage) error {
+		return c.Create(ctx, key, value)
+	})
+}
+
+func runPut(ctx context.Context, a *app, args []string) error {
+	return runWrite(ctx, a, "put", args, func(c *client.Client, key string, value json.RawMessage) error {
+		return put(ctx, c, key, value)
+	})
+}
+
+// runWrite reads the value of create and put from an argument, a file or stdin
+func runWrite(ctx context.Context, a *app, name string, args []string, write func(*client.Client, string, json.RawMessage) error) error {
+	fs := a.flags(name)
+	file := fs.String("f", "", "read the value from `file`, - for stdin")
+	asString := fs.Bool("string", false, "store the input as a JSON string instead of parsing it as JSON")
+	if err := a.parse(fs, args, 1, 2); err != nil {
+		return err
+	}
+
+	value, err := readValue(fs.Args()[1:], *file, *asString, a.stdin)
+	if err != nil {
+		return err
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	return write(c, fs.Arg(0), value)
+}
+
+// put creates key or replaces its value, retrying when a concurrent
+// create or delete wins between the two calls
+func put(ctx context.Context, c *client.Client, key string, value json.RawMessage) error {
+	for attempt := 0; ; attempt++ {
+		err := c.Update(ctx, key, value)
+		if !errors.Is(err, client.ErrNotFound) {
+			return err
+		}
+		err = c.Create(ctx, key, value)
+		if !errors.Is(err, client.ErrAlreadyExists) || attempt == 2 {
+			return err
+		}
+	}
+}
+
+// readValue returns the JSON value given as the single argument, read from
+// file or, when neither is set, from stdin. One trailing newline is dropped.
+func readValue(args []string, file string, asString bool, stdin io.Reader) (json.RawMessage, error) {
+	var data []byte
+	switch {
+	case len(args) == 1 && file != "":
+		return nil, fmt.Errorf("%w: pass the value either as an argument or with -f", errUsage)
+	case len(args) == 1:
+		data = []byte(args[0])
+	case file == "" || file == "-":
+		b, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stdin: %w", err)
+		}
+		data = b
+	default:
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		data = b
+	}
+	data = bytes.TrimSuffix(bytes.TrimSuffix(data, []byte("\n")), []byte("\r"))
+
+	if asString {
+		return json.Marshal(string(data))
+	}
+	data = bytes.TrimSpace(data)
+	if !json.Valid(data) {
+		return nil, errors.New("value is not valid JSON, use -string to store text")
+	}
+	return data, nil
+}
+
+func runDelete(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("delete")
+	ignoreMissing := fs.Bool("ignore-missing", false, "do not fail on keys that do not exist")
+	if err := a.parse(fs, args, 1, -1); err != nil {
+		return err
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range fs.Args() {
+		err := c.Delete(ctx, key)
+		if errors.Is(err, client.ErrNotFound) && *ignoreMissing {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func runList(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("list")
+	prefix := fs.String("prefix", "", "list only keys starting with `prefix`")
+	if err := a.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	p, err := a.printer()
+	if err != nil {
+		return err
+	}
+
+	return c.Export(ctx, *prefix, func(r client.Record) error {
+		return p.print(record{Key: r.Key})
+	})
+}
+
+func runWatch(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("watch")
+	interval := fs.Duration("interval", 2*time.Second, "polling `interval`")
+	if err := a.parse(fs, args, 1, -1); err != nil {
+		return err
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("%w: -interval must be positive", errUsage)
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	p, err := a.printer()
+	if err != nil {
+		return err
+	}
+
+	// nil marks a missing key
+	last := make(map[string]json.RawMessage, fs.NArg())
+	first := true
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		for _, key := range fs.Args() {
+			value, err := c.Get(ctx, key)
+			if errors.Is(err, client.ErrNotFound) {
+				value, err = nil, nil
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+
+			previous, seen := last[key]
+			last[key] = value
+			event := changeEvent(previous, value)
+			switch {
+			case first && value == nil:
+				continue
+			case first:
+				event = ""
+			case !seen || event == "":
+				continue
+			}
+			if err := p.print(record{Key: key, Value: value, Event: event}); err != nil {
+				return err
+			}
+		}
+		first = false
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// changeEvent names the change from previous to current, empty when equal
+func changeEvent(previous, current json.RawMessage) string {
+	switch {
+	case previous == nil && current == nil:
+		return ""
+	case previous == nil:
+		return "created"
+	case current == nil:
+		return "deleted"
+	case !bytes.Equal(previous, current):
+		return "updated"
+	}
+	return ""
+}
+
+// progressEvery is the number of records between progress lines of import
+const progressEvery = 10000
+
+func runImport(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("import")
+	file := fs.String("f", "-", "read records from `file`, - for stdin")
+	mode := fs.String("mode", string(client.ImportOverwrite), "what to do with existing keys: fail-on-conflict, overwrite or skip-existing")
+	createOnly := fs.Bool("create-only", false, "fail on existing keys, same as -mode fail-on-conflict")
+	dryRun := fs.Bool("dry-run", false, "only report what the import would do")
+	keyField := fs.String("key-field", "", "take the key from `field` of every input object and store the whole object as the value")
+	keepGoing := fs.Bool("continue", false, "write records one by one, report failed records and go on with the rest")
+	if err := a.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *createOnly {
+		*mode = string(client.ImportFailOnConflict)
+	}
+	switch client.ImportMode(*mode) {
+	case client.ImportFailOnConflict, client.ImportOverwrite, client.ImportSkipExisting:
+	default:
+		return fmt.Errorf("%w: unknown -mode %q, use fail-on-conflict, overwrite or skip-existing", errUsage, *mode)
+	}
+	if *keepGoing && *dryRun {
+		return fmt.Errorf("%w: -dry-run is not available with -continue", errUsage)
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+
+	in := a.stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	if *keepGoing {
+		return importEach(ctx, a, c, in, *keyField, client.ImportMode(*mode))
+	}
+
+	// records are converted to the JSON lines of /_import as they are read
+	body, w := io.Pipe()
+	sent := 0
+	go func() {
+		enc := json.NewEncoder(w)
+		err := readRecords(in, *keyField, func(r record) error {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+			if sent++; sent%progressEvery == 0 {
+				fmt.Fprintf(a.stderr, "vkctl import: sent %d records\n", sent)
+			}
+			return nil
+		})
+		w.CloseWithError(err)
+	}()
+	defer body.Close()
+
+	report, err := c.Import(ctx, body, client.ImportOptions{Mode: client.ImportMode(*mode), DryRun: *dryRun})
+	var importErr *client.ImportError
+	if errors.As(err, &importErr) {
+		fmt.Fprintf(a.stderr, "vkctl import: stopped, %d records were written\n", importErr.Committed)
+		return importErr.Err
+	}
+	if err != nil {
+		return err
+	}
+
+	verb := "imported"
+	if report.DryRun {
+		verb = "dry run, would import"
+	}
+	fmt.Fprintf(a.stderr, "%s %d records: %d created, %d updated, %d skipped",
+		verb, report.Records, report.Created, report.Updated, report.Skipped)
+	if report.Conflicts > 0 {
+		fmt.Fprintf(a.stderr, ", %d conflicts: %s", report.Conflicts, strings.Join(report.ConflictKeys, " "))
+	}
+	fmt.Fprintln(a.stderr)
+	return nil
+}
+
+// errKeepsExpiry fails records that importEach can not write as they are
+var errKeepsExpiry = errors.New("record has expires_at or flags, import it without -continue")
+
+// importEach writes records one request at a time, failed records are
+// reported and skipped
+func importEach(ctx context.Context, a *app, c *client.Client, in io.Reader, keyField string, mode client.ImportMode) error {
+	var imported, skipped, failed int
+	err := readRecords(in, keyField, func(r record) error {
+		var err error
+		switch {
+		case r.ExpiresAt != nil || r.Flags != 0:
+			// the key API has no expiry and flags, the record would lose them
+			err = errKeepsExpiry
+		case mode == client.ImportOverwrite:
+			err = put(ctx, c, r.Key, r.Value)
+		default:
+			err = c.Create(ctx, r.Key, r.Value)
+		}
+		switch {
+		case err == nil:
+			imported++
+			return nil
+		case mode == client.ImportSkipExisting && errors.Is(err, client.ErrAlreadyExists):
+			skipped++
+			return nil
+		case ctx.Err() != nil:
+			return fmt.Errorf("%s: %w", r.Key, err)
+		}
+		failed++
+		fmt.Fprintf(a.stderr, "vkctl import: %s: %v\n", r.Key, err)
+		return nil
+	})
+	fmt.Fprintf(a.stderr, "imported %d keys", imported)
+	if skipped > 0 {
+		fmt.Fprintf(a.stderr, ", %d skipped", skipped)
+	}
+	if failed > 0 {
+		fmt.Fprintf(a.stderr, ", %d failed", failed)
+	}
+	fmt.Fprintln(a.stderr)
+
+	if err == nil && failed > 0 {
+		err = fmt.Errorf("%d records failed", failed)
+	}
+	return err
+}
+
+// readRecords calls fn for every record of a JSON array or JSON lines input.
+// With keyField every input object is a value and its keyField is the key.
+func readRecords(in io.Reader, keyField string, fn func(record) error) error {
+	br := bufio.NewReader(in)
+	dec := json.NewDecoder(br)
+
+	array := false
+	if b, err := peekNonSpace(br); err == nil && b == '[' {
+		array = true
+		dec.Token()
+	}
+
+	for n := 1; ; n++ {
+		if array && !dec.More() {
+			_, err := dec.Token()
+			return err
+		}
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if err == io.EOF && !array {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+		r, err := parseRecord(raw, keyField)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+}
+
+// parseRecord takes a record from a {"key", "value"} object,
+// or from any object and its keyField
+func parseRecord(raw json.RawMessage, keyField string) (record, error) {
+	if keyField == "" {
+		var r record
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return record{}, err
+		}
+		if r.Key == "" || r.Value == nil {
+			return record{}, errors.New("key and value are required")
+		}
+		return record{Key: r.Key, Value: r.Value, ExpiresAt: r.ExpiresAt, Flags: r.Flags}, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return record{}, fmt.Errorf("want a JSON object: %w", err)
+	}
+	var key string
+	if err := json.Unmarshal(fields[keyField], &key); err != nil || key == "" {
+		return record{}, fmt.Errorf("field %q must be a non-empty string", keyField)
+	}
+	var value bytes.Buffer
+	if err := json.Compact(&value, raw); err != nil {
+		return record{}, err
+	}
+	return record{Key: key, Value: value.Bytes()}, nil
+}
+
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if !strings.ContainsRune(" \t\r\n", rune(b)) {
+			return b, br.UnreadByte()
+		}
+	}
+}
+
+func runExport(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("export")
+	keysFile := fs.String("keys", "", "read keys one per line from `file`, - for stdin")
+	ignoreMissing := fs.Bool("ignore-missing", false, "skip keys that do not exist")
+	prefix := fs.String("prefix", "", "export only keys starting with `prefix`, all keys when no keys are named")
+	if err := a.parse(fs, args, 0, -1); err != nil {
+		return err
+	}
+
+	keys := fs.Args()
+	if *keysFile != "" {
+		more, err := readKeys(*keysFile, a.stdin)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, more...)
+	}
+	if len(keys) > 0 && *prefix != "" {
+		return fmt.Errorf("%w: -prefix exports all matching keys, do not name keys with it", errUsage)
+	}
+
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	p, err := a.printer()
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return c.Export(ctx, *prefix, func(r client.Record) error {
+			return p.print(record{Key: r.Key, Value: r.Value, ExpiresAt: r.ExpiresAt, Flags: r.Flags})
+		})
+	}
+
+	for _, key := range keys {
+		value, err := c.Get(ctx, key)
+		if errors.Is(err, client.ErrNotFound) && *ignoreMissing {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		if err := p.print(record{Key: key, Value: value}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readKeys reads non-empty lines of file
+func readKeys(file string, stdin io.Reader) ([]string, error) {
+	in := stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var keys []string
+	sc := bufio.NewScanner(in)
+	for sc.Scan() {
+		if key := strings.TrimSpace(sc.Text()); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys, sc.Err()
+}
+
+func runConfig(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("config")
+	if err := a.parse(fs, args, 1, -1); err != nil {
+		return err
+	}
+	cfg, err := loadConfig(a.configPath)
+	if err != nil {
+		return err
+	}
+
+	sub, rest := fs.Arg(0), fs.Args()[1:]
+	switch sub {
+	case "view":
+		for _, name := range cfg.names() {
+			p := cfg.Profiles[name]
+			current := " "
+			if name == cfg.Current {
+				current = "*"
+			}
+			fmt.Fprintf(a.stdout, "%s %s\t%s\tapi key: %s\tadmin token: %s\n",
+				current, name, p.Server, mask(p.APIKey), mask(p.AdminToken))
+		}
+		return nil
+
+	case "use":
+		if len(rest) != 1 {
+			return fmt.Errorf("%w: config use NAME", errUsage)
+		}
+		if _, ok := cfg.Profiles[rest[0]]; !ok {
+			return fmt.Errorf("profile %q not found", rest[0])
+		}
+		cfg.Current = rest[0]
+		return cfg.save(a.configPath)
+
+	case "delete-profile":
+		if len(rest) != 1 {
+			return fmt.Errorf("%w: config delete-profile NAME", errUsage)
+		}
+		delete(cfg.Profiles, rest[0])
+		if cfg.Current == rest[0] {
+			cfg.Current = ""
+		}
+		return cfg.save(a.configPath)
+
+	case "set-profile":
+		return setProfile(a, cfg, rest)
+	}
+	return fmt.Errorf("%w: unknown config command %q, use view, use, set-profile or delete-profile", errUsage, sub)
+}
+
+// setProfile creates or changes a profile, only flags that are set are changed
+func setProfile(a *app, cfg *Config, args []string) error {
+	fs := a.flags("config")
+	apiKey := fs.String("api-key", "", "X-API-Key sent with every request")
+	adminToken := fs.String("admin-token", "", "bearer token of /admin routes")
+	language := fs.String("language", "", "language of error messages, ru or en")
+	timeout := fs.Duration("timeout", 0, "per-request `timeout`")
+	retries := fs.Int("retries", -1, "retries of failed requests")
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("%w: config set-profile NAME [flags]", errUsage)
+	}
+	name := args[0]
+	if err := a.parse(fs, args[1:], 0, 0); err != nil {
+		return err
+	}
+
+	p, ok := cfg.Profiles[name]
+	if !ok {
+		p = &Profile{}
+		cfg.Profiles[name] = p
+	}
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	if set["server"] {
+		if _, err := client.New(a.server); err != nil {
+			return err
+		}
+		p.Server = a.server
+	}
+	if set["api-key"] {
+		p.APIKey = *apiKey
+	}
+	if set["admin-token"] {
+		p.AdminToken = *adminToken
+	}
+	if set["language"] {
+		p.Language = *language
+	}
+	if set["timeout"] {
+		p.Timeout = timeout.String()
+	}
+	if set["retries"] {
+		p.Retries = retries
+	}
+	if p.Server == "" {
+		return errors.New("profile needs -server")
+	}
+	if cfg.Current == "" {
+		cfg.Current = name
+	}
+	return cfg.save(a.configPath)
+}
+
+// mask hides all but the last characters of a credential
+func mask(secret string) string {
+	if secret == "" {
+		return "-"
+	}
+	if len(secret) <= 4 {
+		return "****"
+	}
+	return "****" + secret[len(secret)-4:]
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/vvjke314/vk-test-03-2025/pkg/client"
+)
+
+// Profile holds connection settings of one server
+type Profile struct {
+	Server     string `json:"server"`
+	APIKey     string `json:"api_key,omitempty"`
+	AdminToken string `json:"admin_token,omitempty"`
+	Language   string `json:"language,omitempty"`
+	Timeout    string `json:"timeout,omitempty"`
+	Retries    *int   `json:"retries,omitempty"`
+}
+
+// Config is the vkctl configuration file
+type Config struct {
+	Current  string              `json:"current,omitempty"`
+	Profiles map[string]*Profile `json:"profiles"`
+}
+
+// defaultConfigPath returns $VKCTL_CONFIG or vkctl/config.json in the user config dir
+func defaultConfigPath() string {
+	if path := os.Getenv("VKCTL_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "vkctl.json"
+	}
+	return filepath.Join(dir, "vkctl", "config.json")
+}
+
+// loadConfig reads the configuration file, a missing file is an empty config
+func loadConfig(path string) (*Config, error) {
+	cfg := &Config{Profiles: map[string]*Profile{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]*Profile{}
+	}
+	return cfg, nil
+}
+
+// save writes the configuration readable only by the owner, it holds credentials
+func (c *Config) save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create config dir: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
+
+// names returns profile names in order
+func (c *Config) names() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolve picks the profile by name, the current one when name is empty.
+// Having no profiles at all is fine, flags and env then describe the server.
+func (c *Config) resolve(name string) (Profile, error) {
+	if name == "" {
+		name = c.Current
+	}
+	if name == "" {
+		return Profile{}, nil
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("profile %q not found", name)
+	}
+	return *p, nil
+}
+
+// clientOptions converts profile settings into client options
+func (p Profile) clientOptions() ([]client.Option, error) {
+	var opts []client.Option
+	if p.APIKey != "" {
+		opts = append(opts, client.WithAPIKey(p.APIKey))
+	}
+	if p.AdminToken != "" {
+		opts = append(opts, client.WithAdminToken(p.AdminToken))
+	}
+	if p.Language != "" {
+		opts = append(opts, client.WithLanguage(p.Language))
+	}
+	if p.Timeout != "" {
+		d, err := time.ParseDuration(p.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout %q: %w", p.Timeout, err)
+		}
+		opts = append(opts, client.WithTimeout(d))
+	}
+	if p.Retries != nil {
+		opts = append(opts, client.WithRetries(*p.Retries))
+	}
+	return opts, nil
+}
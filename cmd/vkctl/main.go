@@ -0,0 +1,177 @@
+// Command vkctl is a command-line client of the vault key-value API
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/vvjke314/vk-test-03-2025/pkg/client"
+)
+
+// errUsage marks errors caused by wrong arguments, they exit with code 2
+var errUsage = errors.New("usage")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// command is a vkctl subcommand
+type command struct {
+	args    string // Arguments after the command name, for help
+	summary string
+	run     func(ctx context.Context, a *app, args []string) error
+}
+
+// commands is filled in init, the commands print their usage from it
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"get":    {"KEY...", "print values of keys", runGet},
+		"create": {"KEY [VALUE]", "create a key, fails if it exists", runCreate},
+		"put":    {"KEY [VALUE]", "create a key or replace its value", runPut},
+		"delete": {"KEY...", "delete keys", runDelete},
+		"list":   {"", "list keys", runList},
+		"watch":  {"KEY...", "print changes of keys until interrupted", runWatch},
+		"import": {"", "load keys read as JSON lines or a JSON array", runImport},
+		"export": {"[KEY...]", "print keys, all by default, as JSON lines accepted by import", runExport},
+		"config": {"view|use|set-profile|delete-profile", "manage profiles", runConfig},
+	}
+}
+
+// app holds settings shared by all commands
+type app struct {
+	configPath string
+	profile    string
+	server     string
+	output     string
+
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	a := &app{
+		configPath: defaultConfigPath(),
+		profile:    os.Getenv("VKCTL_PROFILE"),
+		server:     os.Getenv("VKCTL_SERVER"),
+		output:     "json",
+		stdin:      stdin,
+		stdout:     stdout,
+		stderr:     stderr,
+	}
+
+	fs := a.flags("")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	name := fs.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "vkctl: unknown command %q\n", name)
+		fs.Usage()
+		return 2
+	}
+
+	err := cmd.run(ctx, a, fs.Args()[1:])
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 2
+	case errors.Is(err, errUsage):
+		if err != errUsage {
+			fmt.Fprintf(stderr, "vkctl %s: %v\n", name, err)
+		}
+		return 2
+	}
+	fmt.Fprintf(stderr, "vkctl %s: %v\n", name, err)
+	return 1
+}
+
+// flags creates the flag set of a command with the global flags registered.
+// Globals are accepted before and after the command name.
+func (a *app) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("vkctl "+name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.StringVar(&a.configPath, "config", a.configPath, "configuration `file`, $VKCTL_CONFIG")
+	fs.StringVar(&a.profile, "profile", a.profile, "profile `name`, the current profile by default, $VKCTL_PROFILE")
+	fs.StringVar(&a.server, "server", a.server, "server `url` overriding the profile, $VKCTL_SERVER")
+	fs.StringVar(&a.output, "o", a.output, "output `format`: json, yaml or table")
+
+	fs.Usage = func() {
+		out := fs.Output()
+		if name == "" {
+			fmt.Fprintln(out, "Usage: vkctl [flags] COMMAND [flags] [ARGS]\n\nCommands:")
+			names := make([]string, 0, len(commands))
+			for n := range commands {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			for _, n := range names {
+				fmt.Fprintf(out, "  %-8s %s\n", n, commands[n].summary)
+			}
+		} else {
+			fmt.Fprintf(out, "Usage: vkctl %s [flags] %s\n\n%s\n", name, commands[name].args, commands[name].summary)
+		}
+		fmt.Fprintln(out, "\nFlags:")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses command flags and checks the number of positional arguments
+func (a *app) parse(fs *flag.FlagSet, args []string, minArgs, maxArgs int) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() < minArgs || maxArgs >= 0 && fs.NArg() > maxArgs {
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+// client builds an API client from the profile and flags
+func (a *app) client() (*client.Client, error) {
+	cfg, err := loadConfig(a.configPath)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := cfg.resolve(a.profile)
+	if err != nil {
+		return nil, err
+	}
+	if a.server != "" {
+		profile.Server = a.server
+	}
+	if profile.Server == "" {
+		return nil, errors.New("no server configured, pass -server or create a profile with vkctl config set-profile")
+	}
+
+	opts, err := profile.clientOptions()
+	if err != nil {
+		return nil, fmt.Errorf("profile: %w", err)
+	}
+	return client.New(profile.Server, opts...)
+}
+
+func (a *app) printer() (printer, error) {
+	return newPrinter(strings.ToLower(a.output), a.stdout)
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeServer serves /kv and bulk routes from a map answering like the vault server
+func fakeServer(t *testing.T) *httptest.Server {
+	var (
+		mu    sync.Mutex
+		items = map[string]json.RawMessage{}
+	)
+	problem := func(w http.ResponseWriter, status int, code string) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"status":%d,"code":%q}`, status, code)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /kv", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Key   string          `json:"key"`
+			Value json.RawMessage `json:"value"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		defer mu.Unlock()
+		if _, ok := items[req.Key]; ok {
+			problem(w, 409, "already_exists")
+			return
+		}
+		items[req.Key] = req.Value
+		fmt.Fprint(w, `{"status":"ok"}`)
+	})
+	mux.HandleFunc("/kv/{id}", func(w http.ResponseWriter, r *http.Request) {
+		key := r.PathValue("id")
+		mu.Lock()
+		defer mu.Unlock()
+		value, ok := items[key]
+		if !ok {
+			problem(w, 404, "not_found")
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(map[string]interface{}{"key": key, "value": value})
+		case http.MethodPut:
+			var req struct {
+				Value json.RawMessage `json:"value"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			items[key] = req.Value
+			fmt.Fprint(w, `{"status":"ok"}`)
+		case http.MethodDelete:
+			delete(items, key)
+			fmt.Fprint(w, `{"status":"ok"}`)
+		}
+	})
+
+	mux.HandleFunc("GET /_export", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		keys := make([]string, 0, len(items))
+		for key := range items {
+			if strings.HasPrefix(key, r.URL.Query().Get("prefix")) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		enc := json.NewEncoder(w)
+		for _, key := range keys {
+			enc.Encode(map[string]interface{}{"key": key, "value": items[key]})
+		}
+	})
+	mux.HandleFunc("POST /_import", func(w http.ResponseWriter, r *http.Request) {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			problem(w, 400, "validation")
+			return
+		}
+		mode, dryRun := r.URL.Query().Get("mode"), r.URL.Query().Get("dry_run") == "true"
+		report := map[string]interface{}{"mode": mode, "dry_run": dryRun}
+		var records, created, updated, skipped int
+		mu.Lock()
+		defer mu.Unlock()
+		dec := json.NewDecoder(zr)
+		for {
+			var rec struct {
+				Key   string          `json:"key"`
+				Value json.RawMessage `json:"value"`
+			}
+			if err := dec.Decode(&rec); err == io.EOF {
+				break
+			} else if err != nil {
+				w.Header().Set("X-Import-Committed", "0")
+				problem(w, 400, "validation")
+				return
+			}
+			records++
+			_, exists := items[rec.Key]
+			switch {
+			case !exists:
+				created++
+			case mode == "overwrite":
+				updated++
+			case mode == "skip-existing":
+				skipped++
+				continue
+			default:
+				w.Header().Set("X-Import-Committed", "0")
+				problem(w, 409, "already_exists")
+				return
+			}
+			if !dryRun {
+				items[rec.Key] = rec.Value
+			}
+		}
+		report["records"], report["created"], report["updated"], report["skipped"] = records, created, updated, skipped
+		json.NewEncoder(w).Encode(report)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+type cli struct {
+	t      *testing.T
+	config string
+}
+
+func (c cli) run(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"-config", c.config}, args...)
+	code := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func (c cli) ok(stdin string, args ...string) string {
+	c.t.Helper()
+	code, out, errOut := c.run(stdin, args...)
+	if code != 0 {
+		c.t.Fatalf("vkctl %v exited with %d: %s", args, code, errOut)
+	}
+	return out
+}
+
+func TestCommands(t *testing.T) {
+	srv := fakeServer(t)
+	c := cli{t: t, config: filepath.Join(t.TempDir(), "config.json")}
+
+	c.ok("", "config", "set-profile", "local", "-server", srv.URL, "-api-key", "secret-key")
+	if out := c.ok("", "config", "view"); !strings.Contains(out, "* local") || strings.Contains(out, "secret-key") {
+		t.Errorf("config view = %q", out)
+	}
+
+	c.ok("", "create", "user:1", `{"name":"a"}`)
+	if code, _, errOut := c.run("", "create", "user:1", `1`); code != 1 || !strings.Contains(errOut, "already_exists") {
+		t.Errorf("duplicate create: %d %s", code, errOut)
+	}
+	c.ok("hello\n", "put", "-string", "note")
+	c.ok("[1, 2]", "put", "user:1")
+
+	if out := c.ok("", "get", "user:1", "note"); out != `{"key":"user:1","value":[1,2]}`+"\n"+`{"key":"note","value":"hello"}`+"\n" {
+		t.Errorf("get json = %q", out)
+	}
+	if out := c.ok("", "-o", "yaml", "get", "user:1"); out != "- key: user:1\n  value:\n    - 1\n    - 2\n" {
+		t.Errorf("get yaml = %q", out)
+	}
+	if out := c.ok("", "get", "-o", "table", "note"); !strings.Contains(out, `note  "hello"`) {
+		t.Errorf("get table = %q", out)
+	}
+
+	// export and import round trip through JSON lines
+	dump := c.ok("", "export", "user:1", "note")
+	c.ok("", "delete", "user:1", "note")
+	if code, _, _ := c.run("", "get", "note"); code != 1 {
+		t.Errorf("deleted key still readable")
+	}
+	c.ok(dump, "import")
+	if out := c.ok("", "export", "user:1", "note"); out != dump {
+		t.Errorf("import of %q restored %q", dump, out)
+	}
+
+	if code, _, errOut := c.run("", "put", "k", "not json"); code != 1 || !strings.Contains(errOut, "not valid JSON") {
+		t.Errorf("invalid value: %d %s", code, errOut)
+	}
+	if code, _, _ := c.run("", "get"); code != 2 {
+		t.Errorf("get without keys exited with %d", code)
+	}
+}
+
+func TestBulk(t *testing.T) {
+	srv := fakeServer(t)
+	c := cli{t: t, config: filepath.Join(t.TempDir(), "config.json")}
+	c.ok("", "config", "set-profile", "local", "-server", srv.URL)
+
+	requests := `{"request_id":"user-001","title":"a"}` + "\n" + `{"request_id":"user-002","title":"b"}` + "\n"
+	if _, _, errOut := c.run(requests, "import", "-key-field", "request_id", "-dry-run"); !strings.Contains(errOut, "would import 2 records: 2 created") {
+		t.Errorf("dry run summary = %q", errOut)
+	}
+	if out := c.ok("", "list"); out != "" {
+		t.Errorf("dry run wrote keys: %q", out)
+	}
+
+	c.ok(requests, "import", "-key-field", "request_id")
+	c.ok("", "put", "other", "1")
+	if out := c.ok("", "list", "-prefix", "user-"); out != `{"key":"user-001"}`+"\n"+`{"key":"user-002"}`+"\n" {
+		t.Errorf("list = %q", out)
+	}
+	dump := c.ok("", "export", "-prefix", "user-")
+	if dump != `{"key":"user-001","value":{"request_id":"user-001","title":"a"}}`+"\n"+`{"key":"user-002","value":{"request_id":"user-002","title":"b"}}`+"\n" {
+		t.Errorf("export = %q", dump)
+	}
+
+	if code, _, errOut := c.run(dump, "import", "-create-only"); code != 1 || !strings.Contains(errOut, "stopped, 0 records were written") {
+		t.Errorf("create-only import of existing keys: %d %q", code, errOut)
+	}
+	if _, _, errOut := c.run(dump, "import", "-mode", "skip-existing"); !strings.Contains(errOut, "imported 2 records: 0 created, 0 updated, 2 skipped") {
+		t.Errorf("skip-existing summary = %q", errOut)
+	}
+	expiring := `{"key":"user-003","value":1,"expires_at":"2030-01-02T03:04:05Z","flags":7}` + "\n"
+	if code, _, errOut := c.run(expiring, "import", "-continue"); code != 1 || !strings.Contains(errOut, "user-003: record has expires_at or flags") {
+		t.Errorf("record with expiry imported one by one: %d %q", code, errOut)
+	}
+	if code, _, _ := c.run(dump, "import", "-mode", "merge"); code != 2 {
+		t.Errorf("unknown mode exited with %d", code)
+	}
+	if code, _, _ := c.run("", "export", "-prefix", "user-", "other"); code != 2 {
+		t.Errorf("prefix with keys exited with %d", code)
+	}
+}
+
+func TestReadRecords(t *testing.T) {
+	for _, input := range []string{
+		`{"key":"a","value":1}` + "\n" + `{"key":"b","value":{"x":true}}`,
+		` [{"key":"a","value":1}, {"key":"b","value":{"x":true}}]`,
+	} {
+		var keys []string
+		err := readRecords(strings.NewReader(input), "", func(r record) error {
+			keys = append(keys, r.Key+"="+string(r.Value))
+			return nil
+		})
+		if err != nil || strings.Join(keys, " ") != `a=1 b={"x":true}` {
+			t.Errorf("readRecords(%q) = %v, %v", input, keys, err)
+		}
+	}
+
+	err := readRecords(strings.NewReader(`{"key":"a","value":1,"expires_at":"2030-01-02T03:04:05Z","flags":7}`), "", func(r record) error {
+		if r.ExpiresAt == nil || r.ExpiresAt.Year() != 2030 || r.Flags != 7 {
+			t.Errorf("record lost expires_at or flags: %+v", r)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if err := readRecords(strings.NewReader(`{"key":"a"}`), "", func(record) error { return nil }); err == nil {
+		t.Errorf("record without value accepted")
+	}
+}
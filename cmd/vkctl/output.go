@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// record is a key with its value, the unit of every command output
+type record struct {
+	Key       string          `json:"key"`
+	Value     json.RawMessage `json:"value,omitempty"`
+	ExpiresAt *time.Time      `json:"expires_at,omitempty"` // Set by export for keys that expire
+	Flags     uint32          `json:"flags,omitempty"`      // Set by export for keys with memcached flags
+	Event     string          `json:"event,omitempty"`      // Set by watch: created, updated or deleted
+}
+
+// printer writes records in one of the output formats
+type printer interface {
+	print(records ...record) error
+}
+
+func newPrinter(format string, w io.Writer) (printer, error) {
+	switch format {
+	case "json":
+		return &jsonPrinter{enc: json.NewEncoder(w)}, nil
+	case "yaml":
+		return &yamlPrinter{w: w}, nil
+	case "table":
+		return &tablePrinter{tw: tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q, use json, yaml or table", format)
+}
+
+// jsonPrinter writes a JSON object per line, the format import reads back
+type jsonPrinter struct {
+	enc *json.Encoder
+}
+
+func (p *jsonPrinter) print(records ...record) error {
+	for _, r := range records {
+		if err := p.enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// yamlPrinter writes records as items of a YAML sequence
+type yamlPrinter struct {
+	w io.Writer
+}
+
+func (p *yamlPrinter) print(records ...record) error {
+	var b strings.Builder
+	for _, r := range records {
+		b.WriteString("- key: " + yamlString(r.Key) + "\n")
+		if r.Event != "" {
+			b.WriteString("  event: " + r.Event + "\n")
+		}
+		if r.ExpiresAt != nil {
+			b.WriteString("  expires_at: " + r.ExpiresAt.Format(time.RFC3339Nano) + "\n")
+		}
+		if r.Flags != 0 {
+			b.WriteString("  flags: " + strconv.FormatUint(uint64(r.Flags), 10) + "\n")
+		}
+		if r.Value != nil {
+			var v interface{}
+			if err := decodeJSON(r.Value, &v); err != nil {
+				return err
+			}
+			b.WriteString("  value:")
+			writeYAML(&b, v, 4)
+		}
+	}
+	_, err := io.WriteString(p.w, b.String())
+	return err
+}
+
+// writeYAML appends v after a "name:" or "-" prefix already in b
+func writeYAML(b *strings.Builder, v interface{}, indent int) {
+	pad := strings.Repeat(" ", indent)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+		b.WriteString("\n")
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			b.WriteString(pad + yamlString(k) + ":")
+			writeYAML(b, v[k], indent+2)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+		b.WriteString("\n")
+		for _, item := range v {
+			b.WriteString(pad + "-")
+			writeYAML(b, item, indent+2)
+		}
+	case string:
+		b.WriteString(" " + yamlString(v) + "\n")
+	case nil:
+		b.WriteString(" null\n")
+	default:
+		// json.Number and bool print the same in YAML
+		b.WriteString(" " + fmt.Sprint(v) + "\n")
+	}
+}
+
+// yamlString quotes s unless it reads back as the same plain string
+func yamlString(s string) string {
+	if s == "" || strings.TrimSpace(s) != s || strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") ||
+		strings.ContainsAny(s, "\"\\\n\t") || strings.Contains(s, ": ") || strings.Contains(s, " #") ||
+		strings.HasSuffix(s, ":") {
+		return strconv.Quote(s)
+	}
+	switch strings.ToLower(s) {
+	case "null", "~", "true", "false", "yes", "no", "on", "off":
+		return strconv.Quote(s)
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// tablePrinter aligns records into KEY and VALUE columns with compact values
+type tablePrinter struct {
+	tw     *tabwriter.Writer
+	header bool
+}
+
+func (p *tablePrinter) print(records ...record) error {
+	if !p.header {
+		p.header = true
+		fmt.Fprintln(p.tw, "KEY\tVALUE\tEVENT")
+	}
+	for _, r := range records {
+		var value bytes.Buffer
+		if r.Value != nil {
+			if err := json.Compact(&value, r.Value); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(p.tw, "%s\t%s\t%s\n", r.Key, value.String(), r.Event)
+	}
+	// watch prints as events happen, so rows are not held until exit
+	return p.tw.Flush()
+}
+
+// decodeJSON decodes data keeping numbers exact
+func decodeJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestYAMLPrinter(t *testing.T) {
+	var out bytes.Buffer
+	p, _ := newPrinter("yaml", &out)
+	err := p.print(record{
+		Key:   "cfg",
+		Value: json.RawMessage(`{"name":"a: b","port":"8080","tags":[],"nested":{"on":true,"ratio":0.5},"empty":null}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `- key: cfg
+  value:
+    empty: null
+    name: "a: b"
+    nested:
+      "on": true
+      ratio: 0.5
+    port: "8080"
+    tags: []
+`
+	if out.String() != want {
+		t.Errorf("yaml =\n%s\nwant\n%s", out.String(), want)
+	}
+}
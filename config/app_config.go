@@ -0,0 +1,327 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+)
+
+// AppConfig holds settings of the HTTP server and process lifecycle
+type AppConfig struct {
+	Port         string
+	GRPCPort     string // Port of the gRPC server, "0" disables it
+	RESPPort     string // Port of the Redis protocol server, "0" disables it
+	MemcachePort string // Port of the memcached protocol server, "0" disables it
+
+	ShutdownDelay   time.Duration // Time to report not ready before the listener is closed
+	ShutdownTimeout time.Duration // Deadline for draining in-flight requests
+
+	HealthTimeout time.Duration // Deadline for dependency checks in /readyz
+
+	Timeouts   RouteTimeouts
+	RateLimits RateLimits
+	Limits     Limits
+
+	LogFile    string       // Path of the log file, "-" writes to stdout
+	LogLevel   logger.Level // Initial log level, can be changed via /admin/loglevel
+	AdminToken string       // Bearer token protecting /admin routes
+
+	LogRotation LogRotation
+	LogAsync    LogAsync
+	LogRedact   LogRedact
+
+	Tracing Tracing
+
+	Cache Cache
+
+	Backup Backup
+
+	errs []error
+}
+
+// LogOverflow decides what an asynchronous logger does when its queue is full
+type LogOverflow string
+
+const (
+	LogOverflowBlock LogOverflow = "block" // Wait for room in the queue
+	LogOverflowDrop  LogOverflow = "drop"  // Drop the record and count it
+)
+
+// LogRedactMode decides how stored values appear in logs
+type LogRedactMode string
+
+const (
+	LogRedactHide    LogRedactMode = "hide"
+	LogRedactHash    LogRedactMode = "hash"
+	LogRedactPreview LogRedactMode = "preview"
+)
+
+// LogRotation configures rotation and retention of the log file
+type LogRotation struct {
+	MaxSizeMB  uint64
+	Interval   time.Duration
+	MaxBackups uint64
+	MaxAge     time.Duration
+	Compress   bool
+}
+
+// LogAsync configures buffered asynchronous log writing
+type LogAsync struct {
+	Enabled       bool
+	QueueSize     uint64
+	FlushInterval time.Duration
+	Overflow      LogOverflow
+}
+
+// LogRedact configures how stored values appear in logs
+type LogRedact struct {
+	Mode        LogRedactMode
+	PreviewLen  uint64   // Characters kept in preview mode
+	KeyPatterns []string // Keys whose values are always hidden, e.g. secret-*
+	JSONFields  []string // JSON fields removed from previews
+	HashKey     string   // HMAC key for hash mode, random per process when empty
+}
+
+// Tracing configures span export
+type Tracing struct {
+	Exporter    string // "none", "otlp", "stdout" or "file"
+	Endpoint    string
+	Insecure    bool
+	File        string
+	SampleRatio float64
+}
+
+// Limits bounds sizes of requests and stored data
+type Limits struct {
+	MaxBodyBytes  uint64 // Largest accepted request body
+	MaxKeyLen     uint64 // Longest key in bytes
+	MaxValueBytes uint64 // Largest value in bytes
+	KeyCharset    string // Characters allowed in keys, a regexp character class body
+}
+
+// KeyPattern compiles KeyCharset into a pattern matching whole keys
+func (l Limits) KeyPattern() (*regexp.Regexp, error) {
+	// unescaped brackets would let the charset break out of the class
+	escaped := false
+	for _, ch := range l.KeyCharset {
+		if !escaped && (ch == '[' || ch == ']') {
+			return nil, fmt.Errorf("unescaped %q", ch)
+		}
+		escaped = !escaped && ch == '\\'
+	}
+	return regexp.Compile("^[" + l.KeyCharset + "]*$")
+}
+
+// Cache configures the read-through cache of stored values
+type Cache struct {
+	MaxSizeMB   uint64        // Size bound of the cache, zero disables caching
+	TTL         time.Duration // How long a value is served from the cache
+	NegativeTTL time.Duration // How long a missing key is remembered, zero disables
+	LoadTimeout time.Duration // Deadline of a storage read shared by concurrent misses
+}
+
+// Backup configures archives made by /admin/backups
+type Backup struct {
+	Dir     string        // Directory of the archives, "-" disables backups
+	Timeout time.Duration // Deadline of one backup, verification or restore
+}
+
+// RouteTimeouts bounds processing time of each /kv route,
+// requests exceeding it are answered with 504
+type RouteTimeouts struct {
+	Get    time.Duration
+	Create time.Duration
+	Update time.Duration
+	Delete time.Duration
+}
+
+// NewAppConfig reads application settings from the environment.
+// Call Validate before using the result.
+func NewAppConfig() *AppConfig {
+	env := &envReader{}
+	cfg := &AppConfig{
+		Port:            env.string("HTTPPORT", "8080"),
+		GRPCPort:        env.string("GRPCPORT", "9090"),
+		RESPPort:        env.string("RESPPORT", "0"),
+		MemcachePort:    env.string("MEMCACHEPORT", "0"),
+		ShutdownDelay:   env.duration("SHUTDOWNDELAY", 0),
+		ShutdownTimeout: env.duration("SHUTDOWNTIMEOUT", 15*time.Second),
+		HealthTimeout:   env.duration("HEALTHTIMEOUT", 2*time.Second),
+		Timeouts: RouteTimeouts{
+			Get:    env.duration("KVGETTIMEOUT", 3*time.Second),
+			Create: env.duration("KVCREATETIMEOUT", 3*time.Second),
+			Update: env.duration("KVUPDATETIMEOUT", 3*time.Second),
+			Delete: env.duration("KVDELETETIMEOUT", 3*time.Second),
+		},
+		RateLimits: RateLimits{
+			Read:                env.rateLimit("RATELIMITREAD", RateLimit{}),
+			Write:               env.rateLimit("RATELIMITWRITE", RateLimit{}),
+			Get:                 env.rateLimit("KVGETRATELIMIT", RateLimit{}),
+			Create:              env.rateLimit("KVCREATERATELIMIT", RateLimit{}),
+			Update:              env.rateLimit("KVUPDATERATELIMIT", RateLimit{}),
+			Delete:              env.rateLimit("KVDELETERATELIMIT", RateLimit{}),
+			KeyBy:               env.string("RATELIMITKEY", "ip"),
+			APIKeys:             env.apiKeys("RATELIMITAPIKEYS"),
+			TrustedProxies:      env.prefixes("RATELIMITTRUSTEDPROXIES"),
+			MaxConcurrentReads:  env.uint("MAXCONCURRENTREADS", 0),
+			MaxConcurrentWrites: env.uint("MAXCONCURRENTWRITES", 0),
+		},
+		Limits: Limits{
+			MaxBodyBytes:  env.uint("MAXBODYBYTES", 1<<20+64<<10),
+			MaxKeyLen:     env.uint("MAXKEYLEN", 256),
+			MaxValueBytes: env.uint("MAXVALUEBYTES", 1<<20),
+			KeyCharset:    env.string("KEYCHARSET", "A-Za-z0-9._~:@-"),
+		},
+		LogFile:    env.string("LOGFILE", "application.log"),
+		LogLevel:   env.logLevel("LOGLEVEL"),
+		AdminToken: env.string("ADMINTOKEN", ""),
+		LogRotation: LogRotation{
+			MaxSizeMB:  env.uint("LOGMAXSIZEMB", 100),
+			Interval:   env.duration("LOGROTATEINTERVAL", 0),
+			MaxBackups: env.uint("LOGMAXBACKUPS", 10),
+			MaxAge:     env.duration("LOGMAXAGE", 0),
+			Compress:   env.bool("LOGCOMPRESS", true),
+		},
+		LogAsync: LogAsync{
+			Enabled:       env.bool("LOGASYNC", true),
+			QueueSize:     env.uint("LOGQUEUESIZE", 4096),
+			FlushInterval: env.duration("LOGFLUSHINTERVAL", time.Second),
+			Overflow:      LogOverflow(env.string("LOGOVERFLOW", "block")),
+		},
+		LogRedact: LogRedact{
+			Mode:        LogRedactMode(env.string("LOGVALUES", "hide")),
+			PreviewLen:  env.uint("LOGPREVIEWLEN", 16),
+			KeyPatterns: env.list("LOGREDACTKEYS", nil),
+			JSONFields:  env.list("LOGREDACTFIELDS", []string{"password", "passwd", "secret", "token", "api_key", "apikey", "private_key"}),
+			HashKey:     env.string("LOGHASHKEY", ""),
+		},
+		Tracing: Tracing{
+			Exporter:    env.string("TRACINGEXPORTER", "none"),
+			Endpoint:    env.string("OTLPENDPOINT", "localhost:4318"),
+			Insecure:    env.bool("OTLPINSECURE", true),
+			File:        env.string("TRACINGFILE", "traces.json"),
+			SampleRatio: env.float("TRACINGSAMPLERATIO", 1),
+		},
+		Cache: Cache{
+			MaxSizeMB:   env.uint("CACHEMAXSIZEMB", 64),
+			TTL:         env.duration("CACHETTL", 30*time.Second),
+			NegativeTTL: env.duration("CACHENEGATIVETTL", 5*time.Second),
+			LoadTimeout: env.duration("CACHELOADTIMEOUT", 5*time.Second),
+		},
+		Backup: Backup{
+			Dir:     env.string("BACKUPDIR", "backups"),
+			Timeout: env.duration("BACKUPTIMEOUT", 30*time.Minute),
+		},
+	}
+	// trusting any peer let clients pick their own address
+	env.removed("RATELIMITTRUSTPROXY", "list the proxies in RATELIMITTRUSTEDPROXIES instead")
+	cfg.errs = env.errs
+
+	return cfg
+}
+
+// Validate reports every malformed setting at once
+func (c *AppConfig) Validate() error {
+	errs := append([]error{}, c.errs...)
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("HTTPPORT must be a number in range 1-65535, got %q", c.Port))
+	}
+	// optional listeners, each needs a port of its own
+	used := map[string]string{c.Port: "HTTPPORT"}
+	for _, p := range []struct{ name, value string }{
+		{"GRPCPORT", c.GRPCPort}, {"RESPPORT", c.RESPPort}, {"MEMCACHEPORT", c.MemcachePort},
+	} {
+		if p.value == "0" {
+			continue
+		}
+		if port, err := strconv.Atoi(p.value); err != nil || port < 1 || port > 65535 {
+			errs = append(errs, fmt.Errorf("%s must be 0 or a number in range 1-65535, got %q", p.name, p.value))
+		} else if other, ok := used[p.value]; ok {
+			errs = append(errs, fmt.Errorf("%s must differ from %s", p.name, other))
+		} else {
+			used[p.value] = p.name
+		}
+	}
+	if c.ShutdownDelay < 0 || c.ShutdownTimeout < 0 {
+		errs = append(errs, errors.New("SHUTDOWNDELAY and SHUTDOWNTIMEOUT can not be negative"))
+	}
+	if c.HealthTimeout <= 0 {
+		errs = append(errs, errors.New("HEALTHTIMEOUT must be positive"))
+	}
+	t := c.Timeouts
+	if t.Get <= 0 || t.Create <= 0 || t.Update <= 0 || t.Delete <= 0 {
+		errs = append(errs, errors.New("KVGETTIMEOUT, KVCREATETIMEOUT, KVUPDATETIMEOUT and KVDELETETIMEOUT must be positive"))
+	}
+
+	if c.Limits.MaxBodyBytes == 0 || c.Limits.MaxKeyLen == 0 || c.Limits.MaxValueBytes == 0 {
+		errs = append(errs, errors.New("MAXBODYBYTES, MAXKEYLEN and MAXVALUEBYTES must be positive"))
+	}
+	if c.Limits.MaxBodyBytes < c.Limits.MaxValueBytes {
+		errs = append(errs, errors.New("MAXBODYBYTES can not be less than MAXVALUEBYTES"))
+	}
+	if c.Limits.KeyCharset == "" {
+		errs = append(errs, errors.New("KEYCHARSET can not be empty"))
+	} else if _, err := c.Limits.KeyPattern(); err != nil {
+		errs = append(errs, fmt.Errorf("KEYCHARSET is not a valid character class: %w", err))
+	}
+
+	if k := c.RateLimits.KeyBy; k != "ip" && k != "apikey" {
+		errs = append(errs, fmt.Errorf("RATELIMITKEY must be ip or apikey, got %q", k))
+	} else if k == "apikey" && len(c.RateLimits.APIKeys) == 0 {
+		errs = append(errs, errors.New("RATELIMITKEY=apikey needs client keys in RATELIMITAPIKEYS"))
+	}
+
+	if c.LogRotation.Interval < 0 || c.LogRotation.MaxAge < 0 {
+		errs = append(errs, errors.New("LOGROTATEINTERVAL and LOGMAXAGE can not be negative"))
+	}
+	switch c.LogAsync.Overflow {
+	case LogOverflowBlock, LogOverflowDrop:
+	default:
+		errs = append(errs, fmt.Errorf("LOGOVERFLOW must be block or drop, got %q", c.LogAsync.Overflow))
+	}
+	if c.LogAsync.FlushInterval < 0 {
+		errs = append(errs, errors.New("LOGFLUSHINTERVAL can not be negative"))
+	}
+	switch c.LogRedact.Mode {
+	case LogRedactHide, LogRedactHash, LogRedactPreview:
+	default:
+		errs = append(errs, fmt.Errorf("LOGVALUES must be one of hide, hash, preview, got %q", c.LogRedact.Mode))
+	}
+	switch c.Tracing.Exporter {
+	case "none", "otlp", "stdout", "file":
+	default:
+		errs = append(errs, fmt.Errorf("TRACINGEXPORTER must be one of none, otlp, stdout, file, got %q", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("TRACINGSAMPLERATIO must be in range 0-1"))
+	}
+	if c.Cache.MaxSizeMB > 0 && c.Cache.TTL <= 0 {
+		errs = append(errs, errors.New("CACHETTL must be positive when the cache is enabled"))
+	}
+	if c.Cache.NegativeTTL < 0 {
+		errs = append(errs, errors.New("CACHENEGATIVETTL can not be negative"))
+	}
+	if c.Cache.MaxSizeMB > 0 && c.Cache.LoadTimeout <= 0 {
+		errs = append(errs, errors.New("CACHELOADTIMEOUT must be positive when the cache is enabled"))
+	}
+	if c.Backup.Timeout <= 0 {
+		errs = append(errs, errors.New("BACKUPTIMEOUT must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// logLevel parses a level the way /admin/loglevel does, unset means info
+func (r *envReader) logLevel(key string) logger.Level {
+	raw := os.Getenv(key)
+	level, err := logger.ParseLevel(raw)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s must be one of debug, info, warn, error, got %q", key, raw))
+	}
+	return level
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+)
+
+func TestLogLevel(t *testing.T) {
+	cases := map[string]logger.Level{"": logger.LevelInfo, " WARNING ": logger.LevelWarn, "debug": logger.LevelDebug}
+	for raw, want := range cases {
+		t.Setenv("LOGLEVEL", raw)
+		env := &envReader{}
+		if got := env.logLevel("LOGLEVEL"); got != want || len(env.errs) != 0 {
+			t.Errorf("LOGLEVEL=%q gives %v, %v", raw, got, env.errs)
+		}
+	}
+
+	t.Setenv("LOGLEVEL", "verbose")
+	env := &envReader{}
+	if env.logLevel("LOGLEVEL"); len(env.errs) != 1 {
+		t.Errorf("unknown level accepted")
+	}
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envReader parses typed values from the environment and collects
+// parse errors so they can be reported together by Validate
+type envReader struct {
+	errs []error
+}
+
+func (r *envReader) string(key, def string) string {
+	if raw := os.Getenv(key); raw != "" {
+		return raw
+	}
+	return def
+}
+
+// list splits comma separated value dropping empty items
+func (r *envReader) list(key string, def []string) []string {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	var result []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func (r *envReader) duration(key string, def time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s must be a duration like 5s, got %q", key, raw))
+		return def
+	}
+	return d
+}
+
+func (r *envReader) uint(key string, def uint64) uint64 {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	n, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s must be a non-negative number, got %q", key, raw))
+		return def
+	}
+	return n
+}
+
+func (r *envReader) float(key string, def float64) float64 {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s must be a number, got %q", key, raw))
+		return def
+	}
+	return f
+}
+
+func (r *envReader) bool(key string, def bool) bool {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s must be true or false, got %q", key, raw))
+		return def
+	}
+	return b
+}
+
+// removed reports a setting that is no longer supported when it is set
+func (r *envReader) removed(key, hint string) {
+	if os.Getenv(key) != "" {
+		r.errs = append(r.errs, fmt.Errorf("%s is no longer supported, %s", key, hint))
+	}
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit is a token bucket budget: Rate tokens per second refill a
+// bucket of Burst tokens. Zero Rate means no limit.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// Enabled reports whether the budget limits anything
+func (l RateLimit) Enabled() bool {
+	return l.Rate > 0
+}
+
+// RateLimits configures per client request budgets of /kv routes, the
+// other front ends account their operations to the matching route.
+// Reads and writes have separate budgets, a route with its own limit
+// gets a separate budget too.
+type RateLimits struct {
+	Read  RateLimit // Budget of GET requests
+	Write RateLimit // Budget of POST, PUT and DELETE requests
+
+	// Per route overrides, zero uses Read or Write
+	Get    RateLimit
+	Create RateLimit
+	Update RateLimit
+	Delete RateLimit
+
+	// KeyBy selects how clients are told apart: "ip", or "apikey" using
+	// the credential verified by the service and falling back to the IP
+	// address for requests without one
+	KeyBy string
+	// APIKeys names the client of every X-API-Key the service accepts
+	APIKeys map[string]string
+	// TrustedProxies are peers whose X-Forwarded-For / X-Real-IP is
+	// believed, none means the headers are ignored
+	TrustedProxies []netip.Prefix
+
+	// Process wide limits of requests in flight, zero means unlimited
+	MaxConcurrentReads  uint64
+	MaxConcurrentWrites uint64
+}
+
+// ParseRateLimit parses "<count>/<unit>[:<burst>]" such as "100/s" or
+// "600/m:50". Unit is s, m or h. Burst defaults to one second of rate.
+// Empty string and "0" mean no limit.
+func ParseRateLimit(s string) (RateLimit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return RateLimit{}, nil
+	}
+
+	spec, burstRaw, hasBurst := strings.Cut(s, ":")
+	countRaw, unit, ok := strings.Cut(spec, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("rate limit must look like 100/s or 100/s:200, got %q", s)
+	}
+	count, err := strconv.ParseFloat(countRaw, 64)
+	if err != nil || count <= 0 {
+		return RateLimit{}, fmt.Errorf("rate limit count must be a positive number, got %q", countRaw)
+	}
+
+	var per time.Duration
+	switch unit {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return RateLimit{}, fmt.Errorf("rate limit unit must be s, m or h, got %q", unit)
+	}
+
+	limit := RateLimit{Rate: count / per.Seconds()}
+	limit.Burst = int(math.Max(1, math.Ceil(limit.Rate)))
+	if hasBurst {
+		burst, err := strconv.Atoi(burstRaw)
+		if err != nil || burst < 1 {
+			return RateLimit{}, fmt.Errorf("rate limit burst must be a positive integer, got %q", burstRaw)
+		}
+		limit.Burst = burst
+	}
+	return limit, nil
+}
+
+func (r *envReader) rateLimit(key string, def RateLimit) RateLimit {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	limit, err := ParseRateLimit(raw)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s: %w", key, err))
+		return def
+	}
+	return limit
+}
+
+// apiKeys parses a comma separated list of name:key pairs into names by key
+func (r *envReader) apiKeys(key string) map[string]string {
+	result := map[string]string{}
+	names := map[string]bool{}
+	for _, item := range r.list(key, nil) {
+		name, apiKey, ok := strings.Cut(item, ":")
+		switch {
+		case !ok || name == "" || apiKey == "":
+			r.errs = append(r.errs, fmt.Errorf("%s must list name:key pairs, got %q", key, item))
+		case names[name]:
+			r.errs = append(r.errs, fmt.Errorf("%s names client %q twice", key, name))
+		case result[apiKey] != "":
+			r.errs = append(r.errs, fmt.Errorf("%s gives clients %q and %q the same key", key, result[apiKey], name))
+		default:
+			result[apiKey] = name
+			names[name] = true
+		}
+	}
+	return result
+}
+
+// prefixes parses a comma separated list of addresses and CIDR ranges
+func (r *envReader) prefixes(key string) []netip.Prefix {
+	var result []netip.Prefix
+	for _, item := range r.list(key, nil) {
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(item)
+			if addrErr != nil {
+				r.errs = append(r.errs, fmt.Errorf("%s must list addresses or CIDR ranges, got %q", key, item))
+				continue
+			}
+			prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+		result = append(result, prefix.Masked())
+	}
+	return result
+}
@@ -0,0 +1,63 @@
+package config
+
+import "testing"
+
+func TestParseRateLimit(t *testing.T) {
+	valid := map[string]RateLimit{
+		"":         {},
+		"0":        {},
+		"100/s":    {Rate: 100, Burst: 100},
+		"60/m":     {Rate: 1, Burst: 1},
+		"600/m:50": {Rate: 10, Burst: 50},
+		"36/h":     {Rate: 0.01, Burst: 1},
+		"2.5/s:10": {Rate: 2.5, Burst: 10},
+	}
+	for raw, want := range valid {
+		got, err := ParseRateLimit(raw)
+		if err != nil || got != want {
+			t.Errorf("ParseRateLimit(%q) = %+v, %v, want %+v", raw, got, err, want)
+		}
+	}
+
+	for _, raw := range []string{"100", "100/d", "-1/s", "x/s", "10/s:0", "10/s:x"} {
+		if _, err := ParseRateLimit(raw); err == nil {
+			t.Errorf("ParseRateLimit(%q) accepted", raw)
+		}
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	t.Setenv("RATELIMITTRUSTEDPROXIES", "10.0.0.0/8, 192.168.1.7,::1, 10.1.2.3/8")
+	env := &envReader{}
+	got := env.prefixes("RATELIMITTRUSTEDPROXIES")
+	want := []string{"10.0.0.0/8", "192.168.1.7/32", "::1/128", "10.0.0.0/8"}
+	if len(env.errs) != 0 || len(got) != len(want) {
+		t.Fatalf("got %v, %v", got, env.errs)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Errorf("prefix %d is %s, want %s", i, got[i], want[i])
+		}
+	}
+
+	t.Setenv("RATELIMITTRUSTEDPROXIES", "10.0.0.0/8,proxy")
+	env = &envReader{}
+	if got := env.prefixes("RATELIMITTRUSTEDPROXIES"); len(env.errs) != 1 || len(got) != 1 {
+		t.Errorf("invalid entry: %v, %v", got, env.errs)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	t.Setenv("RATELIMITAPIKEYS", "billing:k1, reports:k2:with-colon")
+	env := &envReader{}
+	got := env.apiKeys("RATELIMITAPIKEYS")
+	if len(env.errs) != 0 || len(got) != 2 || got["k1"] != "billing" || got["k2:with-colon"] != "reports" {
+		t.Fatalf("got %v, %v", got, env.errs)
+	}
+
+	t.Setenv("RATELIMITAPIKEYS", "billing:k1,billing:k2,other:k1,nokey,:k3")
+	env = &envReader{}
+	if got := env.apiKeys("RATELIMITAPIKEYS"); len(env.errs) != 4 || len(got) != 1 {
+		t.Errorf("got %v, %v", got, env.errs)
+	}
+}
@@ -1,17 +1,198 @@
 package config
 
-import "os"
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
+// Authentication methods of the Tarantool user
+const (
+	TnAuthChapSha1  = "chap-sha1"
+	TnAuthPapSha256 = "pap-sha256" // Sends the password itself, only over TLS
+)
+
+// TnRepoConfig holds connection settings for the Tarantool repository
 type TnRepoConfig struct {
+	Host     string
+	Port     string
 	Username string
 	Pass     string
-	Port     string
+	Auth     string // TnAuthChapSha1 or TnAuthPapSha256
+
+	Timeout     time.Duration // Request timeout, zero means the connector default
+	Concurrency uint32        // Number of connection shards, zero means the connector default
+
+	Instances     []string      // host:port of every instance, overrides Host and Port when set
+	Reconnect     time.Duration // Pause between reconnect attempts, zero disables reconnecting
+	MaxReconnects uint          // Reconnect attempts before giving up, zero means unlimited
+	CheckInterval time.Duration // How often pool instances are checked for health and role
+
+	TLS TnTLSConfig
+
+	errs []error // Parse errors collected while reading the environment
 }
 
+// TnTLSConfig holds TLS settings for the Tarantool connection
+type TnTLSConfig struct {
+	Enabled            bool
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// NewTnConfig reads Tarantool repository settings from the environment.
+// Call Validate before using the result.
 func NewTnConfig() *TnRepoConfig {
-	return &TnRepoConfig{
+	cfg := &TnRepoConfig{
+		Host:     os.Getenv("TRNTLHOST"),
+		Port:     os.Getenv("TRNTLPORT"),
 		Username: os.Getenv("TRNTLUSER"),
 		Pass:     os.Getenv("TRNTLPASS"),
-		Port:     os.Getenv("TRNTLPORT"),
+		TLS: TnTLSConfig{
+			CAFile:     os.Getenv("TRNTLTLSCA"),
+			CertFile:   os.Getenv("TRNTLTLSCERT"),
+			KeyFile:    os.Getenv("TRNTLTLSKEY"),
+			ServerName: os.Getenv("TRNTLTLSSERVERNAME"),
+		},
+	}
+
+	env := &envReader{}
+	cfg.Auth = env.string("TRNTLAUTH", TnAuthChapSha1)
+	cfg.Timeout = env.duration("TRNTLTIMEOUT", 5*time.Second)
+	cfg.Concurrency = uint32(env.uint("TRNTLCONCURRENCY", 0))
+	cfg.Instances = env.list("TRNTLINSTANCES", nil)
+	cfg.Reconnect = env.duration("TRNTLRECONNECT", time.Second)
+	cfg.MaxReconnects = uint(env.uint("TRNTLMAXRECONNECTS", 0))
+	cfg.CheckInterval = env.duration("TRNTLCHECKINTERVAL", time.Second)
+	cfg.TLS.Enabled = env.bool("TRNTLTLS", false)
+	cfg.TLS.InsecureSkipVerify = env.bool("TRNTLTLSSKIPVERIFY", false)
+	cfg.errs = env.errs
+
+	return cfg
+}
+
+// Address returns host:port of the Tarantool instance
+func (c *TnRepoConfig) Address() string {
+	if len(c.Instances) > 0 {
+		return strings.Join(c.Instances, ",")
+	}
+	return net.JoinHostPort(c.Host, c.Port)
+}
+
+// Addresses returns host:port of every configured instance
+func (c *TnRepoConfig) Addresses() []string {
+	if len(c.Instances) > 0 {
+		return c.Instances
+	}
+	return []string{net.JoinHostPort(c.Host, c.Port)}
+}
+
+// Validate reports every missing or malformed setting at once
+func (c *TnRepoConfig) Validate() error {
+	errs := append([]error{}, c.errs...)
+
+	required := []struct{ key, value string }{
+		{"TRNTLUSER", c.Username},
+		{"TRNTLPASS", c.Pass},
+	}
+	if len(c.Instances) == 0 {
+		required = append(required,
+			struct{ key, value string }{"TRNTLHOST", c.Host},
+			struct{ key, value string }{"TRNTLPORT", c.Port})
+	}
+	for _, r := range required {
+		if r.value == "" {
+			errs = append(errs, fmt.Errorf("%s is required", r.key))
+		}
 	}
-}
\ No newline at end of file
+
+	if len(c.Instances) == 0 && c.Port != "" && !validPort(c.Port) {
+		errs = append(errs, fmt.Errorf("TRNTLPORT must be a number in range 1-65535, got %q", c.Port))
+	}
+	seen := make(map[string]bool, len(c.Instances))
+	for _, addr := range c.Instances {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || host == "" || !validPort(port) {
+			errs = append(errs, fmt.Errorf("TRNTLINSTANCES: %q is not a valid host:port", addr))
+		}
+		if seen[addr] {
+			errs = append(errs, fmt.Errorf("TRNTLINSTANCES: %q is listed twice", addr))
+		}
+		seen[addr] = true
+	}
+
+	if c.Reconnect < 0 {
+		errs = append(errs, errors.New("TRNTLRECONNECT can not be negative"))
+	}
+	if c.CheckInterval <= 0 {
+		errs = append(errs, errors.New("TRNTLCHECKINTERVAL must be positive"))
+	}
+
+	if c.Timeout < 0 {
+		errs = append(errs, errors.New("TRNTLTIMEOUT can not be negative"))
+	}
+
+	switch c.Auth {
+	case TnAuthChapSha1:
+	case TnAuthPapSha256:
+		if !c.TLS.Enabled {
+			errs = append(errs, errors.New("TRNTLAUTH=pap-sha256 sends the password as is and needs TRNTLTLS"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("TRNTLAUTH must be chap-sha1 or pap-sha256, got %q", c.Auth))
+	}
+
+	if c.TLS.Enabled && (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("TRNTLTLSCERT and TRNTLTLSKEY must be set together"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func validPort(s string) bool {
+	port, err := strconv.Atoi(s)
+	return err == nil && port >= 1 && port <= 65535
+}
+
+// TLSConfig builds crypto/tls settings from the configured files
+func (c *TnRepoConfig) TLSConfig() (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		ServerName:         c.TLS.ServerName,
+		InsecureSkipVerify: c.TLS.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if tlsCfg.ServerName == "" {
+		tlsCfg.ServerName = c.Host
+	}
+
+	if c.TLS.CAFile != "" {
+		pem, err := os.ReadFile(c.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.TLS.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if c.TLS.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLS.CertFile, c.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
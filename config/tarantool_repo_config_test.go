@@ -12,3 +12,70 @@ func TestNewTnConfig(t *testing.T) {
 		return
 	}
 }
+
+func TestTnConfigValidate(t *testing.T) {
+	t.Setenv("TRNTLHOST", "localhost")
+	t.Setenv("TRNTLPORT", "3301")
+	t.Setenv("TRNTLUSER", "admin")
+	t.Setenv("TRNTLPASS", "admin")
+
+	if err := NewTnConfig().Validate(); err != nil {
+		t.Errorf("valid config rejected: %v", err)
+		return
+	}
+
+	cases := map[string]string{
+		"TRNTLPORT":    "",
+		"TRNTLHOST":    "",
+		"TRNTLTIMEOUT": "five",
+		"TRNTLTLS":     "maybe",
+		"TRNTLAUTH":    "plain",
+	}
+	for key, value := range cases {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			if err := NewTnConfig().Validate(); err == nil {
+				t.Errorf("expected error for %s=%q", key, value)
+			}
+		})
+	}
+
+	// pap-sha256 sends the password itself, only TLS protects it
+	t.Setenv("TRNTLAUTH", "pap-sha256")
+	if err := NewTnConfig().Validate(); err == nil {
+		t.Errorf("pap-sha256 accepted without TLS")
+	}
+	t.Setenv("TRNTLTLS", "true")
+	if err := NewTnConfig().Validate(); err != nil {
+		t.Errorf("pap-sha256 over TLS rejected: %v", err)
+	}
+
+	t.Setenv("TRNTLPORT", "99999")
+	if err := NewTnConfig().Validate(); err == nil {
+		t.Errorf("expected error for out of range port")
+	}
+}
+
+func TestTnConfigInstances(t *testing.T) {
+	t.Setenv("TRNTLHOST", "")
+	t.Setenv("TRNTLPORT", "")
+	t.Setenv("TRNTLUSER", "admin")
+	t.Setenv("TRNTLPASS", "admin")
+	t.Setenv("TRNTLINSTANCES", "master:3301, replica:3301")
+
+	cfg := NewTnConfig()
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("valid config rejected: %v", err)
+		return
+	}
+	if got := cfg.Addresses(); len(got) != 2 || got[1] != "replica:3301" {
+		t.Errorf("unexpected addresses %v", got)
+	}
+
+	for _, value := range []string{"master", "master:0", "master:3301,master:3301"} {
+		t.Setenv("TRNTLINSTANCES", value)
+		if err := NewTnConfig().Validate(); err == nil {
+			t.Errorf("expected error for TRNTLINSTANCES=%q", value)
+		}
+	}
+}
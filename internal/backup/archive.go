@@ -0,0 +1,249 @@
+// Package backup writes consistent dumps of the vault to versioned,
+// checksummed archives and restores them with verification
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+
+	"github.com/vvjke314/vk-test-03-2025/internal/entities"
+)
+
+// Format names the archive layout in the header line
+const Format = "vault-backup"
+
+// Version is the archive format version written by this code. Version 1
+// archives have no expires_at and flags and are still read.
+const Version = 2
+
+// An archive is gzipped JSON lines: a header, one line per record in key
+// order and a trailer with the number of records and the SHA-256 of the
+// record lines including their newlines. Values are stored as JSON strings,
+// so their text is kept byte for byte. Records of keys that expire or have
+// memcached flags carry expires_at and flags.
+
+// Header is the first line of an archive
+type Header struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Summary is what the trailer of an archive records
+type Summary struct {
+	Records int    `json:"records"`
+	SHA256  string `json:"sha256"`
+}
+
+// archiveRecord is a record line
+type archiveRecord struct {
+	Key       string     `json:"key"`
+	Value     string     `json:"value"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Flags     uint32     `json:"flags,omitempty"`
+}
+
+// line is any line after the header, records have a key, the trailer has not
+type line struct {
+	Key       *string    `json:"key"`
+	Value     *string    `json:"value"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Flags     uint32     `json:"flags"`
+	Records   *int       `json:"records"`
+	SHA256    string     `json:"sha256"`
+}
+
+// UnsupportedVersionError is returned for archives of an unknown format version
+type UnsupportedVersionError struct {
+	Version int
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("unsupported archive version %d", e.Version)
+}
+
+// ErrCorrupt is matched by errors about damaged or truncated archives
+var ErrCorrupt = errors.New("archive is corrupt")
+
+func corrupt(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrCorrupt, fmt.Sprintf(format, args...))
+}
+
+// Writer writes an archive
+type Writer struct {
+	gz      *gzip.Writer
+	bw      *bufio.Writer
+	sum     hash.Hash
+	records int
+}
+
+// NewWriter writes the header of an archive created at createdAt to w
+func NewWriter(w io.Writer, createdAt time.Time) (*Writer, error) {
+	gz := gzip.NewWriter(w)
+	aw := &Writer{gz: gz, bw: bufio.NewWriter(gz), sum: sha256.New()}
+	header, err := json.Marshal(Header{Format: Format, Version: Version, CreatedAt: createdAt.UTC()})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := aw.bw.Write(append(header, '\n')); err != nil {
+		return nil, err
+	}
+	return aw, nil
+}
+
+// Write appends records, the caller keeps them in key order
+func (w *Writer) Write(items ...entities.VaultItem) error {
+	for _, item := range items {
+		rec := archiveRecord{Key: item.Key, Value: item.Value, Flags: item.Flags}
+		if !item.ExpiresAt.IsZero() {
+			at := item.ExpiresAt.UTC()
+			rec.ExpiresAt = &at
+		}
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+		w.sum.Write(data)
+		if _, err := w.bw.Write(data); err != nil {
+			return err
+		}
+		w.records++
+	}
+	return nil
+}
+
+// Close writes the trailer and finishes the gzip stream, w itself stays open
+func (w *Writer) Close() (Summary, error) {
+	summary := Summary{Records: w.records, SHA256: hex.EncodeToString(w.sum.Sum(nil))}
+	trailer, err := json.Marshal(summary)
+	if err != nil {
+		return Summary{}, err
+	}
+	if _, err := w.bw.Write(append(trailer, '\n')); err != nil {
+		return Summary{}, err
+	}
+	if err := w.bw.Flush(); err != nil {
+		return Summary{}, err
+	}
+	if err := w.gz.Close(); err != nil {
+		return Summary{}, err
+	}
+	return summary, nil
+}
+
+// Reader reads an archive verifying it on the way
+type Reader struct {
+	r       *bufio.Reader
+	header  Header
+	sum     hash.Hash
+	records int
+	summary *Summary // Set once the trailer is verified
+}
+
+// NewReader reads the header of an archive
+func NewReader(r io.Reader) (*Reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, corrupt("not a gzip stream: %v", err)
+	}
+	ar := &Reader{r: bufio.NewReader(gz), sum: sha256.New()}
+
+	data, err := ar.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &ar.header); err != nil || ar.header.Format != Format {
+		return nil, corrupt("no archive header")
+	}
+	if ar.header.Version < 1 || ar.header.Version > Version {
+		return nil, &UnsupportedVersionError{Version: ar.header.Version}
+	}
+	return ar, nil
+}
+
+// Header returns the header of the archive
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+// Next returns the next record. After the last one it verifies the trailer,
+// the checksum and the end of the stream and returns io.EOF.
+func (r *Reader) Next() (entities.VaultItem, error) {
+	if r.summary != nil {
+		return entities.VaultItem{}, io.EOF
+	}
+	data, err := r.readLine()
+	if err != nil {
+		return entities.VaultItem{}, err
+	}
+
+	var l line
+	if err := json.Unmarshal(data, &l); err != nil {
+		return entities.VaultItem{}, corrupt("line %d is not valid JSON", r.records+2)
+	}
+	switch {
+	case l.Key != nil && l.Value != nil:
+		r.sum.Write(data)
+		r.records++
+		item := entities.VaultItem{Key: *l.Key, Value: *l.Value, Flags: l.Flags}
+		if l.ExpiresAt != nil {
+			item.ExpiresAt = *l.ExpiresAt
+		}
+		return item, nil
+	case l.Key == nil && l.Records != nil:
+		if err := r.finish(Summary{Records: *l.Records, SHA256: l.SHA256}); err != nil {
+			return entities.VaultItem{}, err
+		}
+		return entities.VaultItem{}, io.EOF
+	}
+	return entities.VaultItem{}, corrupt("line %d is neither a record nor the trailer", r.records+2)
+}
+
+// Summary returns the verified trailer, it is set once Next returned io.EOF
+func (r *Reader) Summary() (Summary, bool) {
+	if r.summary == nil {
+		return Summary{}, false
+	}
+	return *r.summary, true
+}
+
+// finish checks the trailer against the records read and that the stream
+// ends right after it, reading to the end also checks the gzip CRC
+func (r *Reader) finish(trailer Summary) error {
+	if trailer.Records != r.records {
+		return corrupt("trailer counts %d records, archive has %d", trailer.Records, r.records)
+	}
+	if sum := hex.EncodeToString(r.sum.Sum(nil)); trailer.SHA256 != sum {
+		return corrupt("checksum mismatch")
+	}
+	if _, err := r.r.ReadByte(); err != io.EOF {
+		if err == nil {
+			return corrupt("data after the trailer")
+		}
+		return corrupt("%v", err)
+	}
+	r.summary = &trailer
+	return nil
+}
+
+// readLine returns the next line with its newline, a missing newline means
+// the archive was cut short
+func (r *Reader) readLine() ([]byte, error) {
+	data, err := r.r.ReadBytes('\n')
+	switch {
+	case err == io.EOF:
+		return nil, corrupt("archive is truncated")
+	case err != nil:
+		return nil, corrupt("%v", err)
+	}
+	return data, nil
+}
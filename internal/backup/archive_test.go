@@ -0,0 +1,144 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vvjke314/vk-test-03-2025/internal/entities"
+)
+
+// writeArchive returns an archive of items
+func writeArchive(t *testing.T, items ...entities.VaultItem) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(items...); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// readArchive reads all records of data
+func readArchive(data []byte) ([]entities.VaultItem, error) {
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var items []entities.VaultItem
+	for {
+		item, err := r.Next()
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return items, err
+		}
+		items = append(items, item)
+	}
+}
+
+// regzip compresses text as an archive would be
+func regzip(text string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	io.WriteString(gz, text)
+	gz.Close()
+	return buf.Bytes()
+}
+
+// gunzip returns the text of an archive
+func gunzip(t *testing.T, data []byte) string {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(text)
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	items := []entities.VaultItem{
+		{Key: "a", Value: `{"n": 1.0,  "s":"é"}`},
+		{Key: "b/ключ", Value: `[1,2]`},
+		{Key: "c", Value: `"x"`, ExpiresAt: time.UnixMilli(1740830400123), Flags: 7},
+	}
+	got, err := readArchive(writeArchive(t, items...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(items) {
+		t.Fatalf("read %d records, want %d", len(got), len(items))
+	}
+	for i := range items {
+		if got[i].Key != items[i].Key || got[i].Value != items[i].Value ||
+			!got[i].ExpiresAt.Equal(items[i].ExpiresAt) || got[i].Flags != items[i].Flags {
+			t.Errorf("record %d is %+v, want %+v", i, got[i], items[i])
+		}
+	}
+
+	// an empty vault makes a valid archive too
+	if got, err := readArchive(writeArchive(t)); err != nil || len(got) != 0 {
+		t.Errorf("empty archive: %v %v", got, err)
+	}
+}
+
+func TestArchiveCorruption(t *testing.T) {
+	data := writeArchive(t, entities.VaultItem{Key: "a", Value: "1"}, entities.VaultItem{Key: "b", Value: "2"})
+	text := gunzip(t, data)
+	lines := strings.SplitAfter(text, "\n")
+
+	cases := map[string][]byte{
+		"truncated gzip":    data[:len(data)-10],
+		"no trailer":        regzip(strings.Join(lines[:3], "")),
+		"cut trailer":       regzip(strings.TrimSuffix(text, "\n")),
+		"changed value":     regzip(strings.Replace(text, `"value":"2"`, `"value":"3"`, 1)),
+		"dropped record":    regzip(lines[0] + lines[2] + lines[3]),
+		"data after end":    regzip(text + `{"key":"c","value":"3"}` + "\n"),
+		"not gzip":          []byte(text),
+		"not an archive":    regzip("{\"key\":\"a\",\"value\":\"1\"}\n"),
+		"invalid json line": regzip(lines[0] + "{\n" + lines[3]),
+	}
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := readArchive(data); !errors.Is(err, ErrCorrupt) {
+				t.Errorf("got %v, want a corrupt archive error", err)
+			}
+		})
+	}
+
+	flipped := bytes.Clone(data)
+	flipped[len(flipped)-5] ^= 0xff // inside the gzip CRC and size
+	if _, err := readArchive(flipped); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("gzip trailer damage: %v", err)
+	}
+}
+
+func TestArchiveVersion(t *testing.T) {
+	data := regzip(`{"format":"vault-backup","version":3,"created_at":"2025-03-01T12:00:00Z"}` + "\n")
+	var version *UnsupportedVersionError
+	if _, err := readArchive(data); !errors.As(err, &version) || version.Version != 3 {
+		t.Errorf("got %v, want unsupported version 3", err)
+	}
+
+	// version 1 records have no expires_at and flags
+	text := gunzip(t, writeArchive(t, entities.VaultItem{Key: "a", Value: "1"}))
+	old := regzip(strings.Replace(text, `"version":2`, `"version":1`, 1))
+	if items, err := readArchive(old); err != nil || len(items) != 1 || items[0] != (entities.VaultItem{Key: "a", Value: "1"}) {
+		t.Errorf("version 1 archive: %v %v", items, err)
+	}
+}
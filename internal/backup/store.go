@@ -0,0 +1,405 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"github.com/vvjke314/vk-test-03-2025/internal/entities"
+	"github.com/vvjke314/vk-test-03-2025/internal/i18n"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+	"github.com/vvjke314/vk-test-03-2025/internal/usecases"
+)
+
+// restoreChunk is the number of records written to the storage at once
+const restoreChunk = 500
+
+// maxReportKeys bounds the keys listed in a restore report
+const maxReportKeys = 100
+
+// nameLayout is the time part of archive names, names sort by creation time
+const nameLayout = "20060102T150405.000Z"
+
+var namePattern = regexp.MustCompile(`^vault-(\d{8}T\d{6}\.\d{3}Z)\.backup\.gz$`)
+
+// Source is the storage backed up and restored
+type Source interface {
+	Dump(ctx context.Context, fn func([]entities.VaultItem) error) error
+	Import(ctx context.Context, items []entities.VaultItem, mode entities.ImportMode, dryRun bool) (entities.ImportResult, error)
+	List(ctx context.Context, prefix, after string, limit int) ([]entities.VaultItem, error)
+}
+
+// Info describes an archive, records and checksum are known once it is read
+type Info struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Bytes     int64     `json:"bytes"`
+	Records   *int      `json:"records,omitempty"`
+	SHA256    string    `json:"sha256,omitempty"`
+}
+
+// RestoreOptions configures Restore
+type RestoreOptions struct {
+	Mode   entities.ImportMode // What happens to keys that already exist
+	DryRun bool                // Only verify the archive and count, nothing is written
+}
+
+// RestoreReport tells what a restore did or, in a dry run, would do
+type RestoreReport struct {
+	Name         string              `json:"name"`
+	Mode         entities.ImportMode `json:"mode"`
+	DryRun       bool                `json:"dry_run"`
+	Records      int                 `json:"records"`
+	Created      int                 `json:"created"`
+	Updated      int                 `json:"updated"`
+	Skipped      int                 `json:"skipped"`
+	Conflicts    int                 `json:"conflicts"`
+	ConflictKeys []string            `json:"conflict_keys"`
+	Verified     bool                `json:"verified"`      // The storage holds every restored record
+	Mismatches   int                 `json:"mismatches"`    // Records missing or different after the restore
+	MismatchKeys []string            `json:"mismatch_keys"` // First of the mismatching keys
+	Committed    int                 `json:"-"`             // Records written, also when the restore failed
+}
+
+// Store keeps archives in a local directory. One backup or restore runs at
+// a time, another one started meanwhile fails as busy.
+type Store struct {
+	dir    string
+	src    Source
+	logger logger.Logger
+	mu     sync.Mutex
+	now    func() time.Time
+}
+
+// NewStore creates the directory if needed
+func NewStore(dir string, src Source, l logger.Logger) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	return &Store{dir: dir, src: src, logger: l, now: time.Now}, nil
+}
+
+// Create dumps the storage into a new archive. The archive is written to a
+// temporary file and renamed once synced, so a listed archive is complete.
+func (s *Store) Create(ctx context.Context) (_ Info, err error) {
+	if !s.mu.TryLock() {
+		return Info{}, custom_errors.NewBackupBusyError()
+	}
+	defer s.mu.Unlock()
+
+	start := s.now()
+	createdAt := start.UTC().Truncate(time.Millisecond)
+	name := archiveName(createdAt)
+	// names have millisecond precision, archives made within one get the next
+	for s.exists(name) {
+		createdAt = createdAt.Add(time.Millisecond)
+		name = archiveName(createdAt)
+	}
+	log := logger.FromContext(ctx, s.logger).With(logger.F("backup", name))
+	log.Info("backup started")
+
+	tmp, err := os.CreateTemp(s.dir, ".vault-*.tmp")
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			log.Error("backup failed", logger.Err(err))
+		}
+	}()
+
+	w, err := NewWriter(tmp, createdAt)
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to write backup: %w", err)
+	}
+	err = s.src.Dump(ctx, func(items []entities.VaultItem) error {
+		if err := w.Write(items...); err != nil {
+			return fmt.Errorf("failed to write backup: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return Info{}, err
+	}
+	summary, err := w.Close()
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to write backup: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return Info{}, fmt.Errorf("failed to sync backup: %w", err)
+	}
+	stat, err := tmp.Stat()
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to stat backup: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return Info{}, fmt.Errorf("failed to close backup: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, name)); err != nil {
+		return Info{}, fmt.Errorf("failed to store backup: %w", err)
+	}
+	if err := syncDir(s.dir); err != nil {
+		return Info{}, fmt.Errorf("failed to sync backup directory: %w", err)
+	}
+
+	log.Info("backup created", logger.F("records", summary.Records), logger.F("bytes", stat.Size()),
+		logger.F("duration_ms", time.Since(start).Milliseconds()))
+	return Info{Name: name, CreatedAt: createdAt, Bytes: stat.Size(), Records: &summary.Records, SHA256: summary.SHA256}, nil
+}
+
+// List returns the archives in the directory, oldest first. Archives are not
+// read, so records and checksum are left out.
+func (s *Store) List() ([]Info, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+	infos := []Info{}
+	for _, entry := range entries {
+		createdAt, ok := parseName(entry.Name())
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		stat, err := entry.Info()
+		if err != nil {
+			// removed meanwhile
+			continue
+		}
+		infos = append(infos, Info{Name: entry.Name(), CreatedAt: createdAt, Bytes: stat.Size()})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos, nil
+}
+
+// Verify reads the whole archive checking its format, count and checksum
+func (s *Store) Verify(ctx context.Context, name string) (Info, error) {
+	info, err := s.read(ctx, name, func(entities.VaultItem) error { return nil })
+	if err != nil {
+		return Info{}, err
+	}
+	return info, nil
+}
+
+// Restore loads an archive into the storage. The archive is verified in
+// full before anything is written, then records are written in chunks, each
+// chunk in one transaction, with the import semantics of opts.Mode. Finally
+// the storage is compared with the archive: keys must exist and, unless
+// existing keys were skipped, hold the archived values, expiry and flags.
+// Records that have expired by then are not compared. Writes of other
+// clients made meanwhile show up as mismatches.
+func (s *Store) Restore(ctx context.Context, name string, opts RestoreOptions) (RestoreReport, error) {
+	report := RestoreReport{Name: name, Mode: opts.Mode, DryRun: opts.DryRun, ConflictKeys: []string{}, MismatchKeys: []string{}}
+	if report.Mode == "" {
+		report.Mode = entities.ImportFailOnConflict
+	}
+	if err := usecases.ValidImportMode(report.Mode); err != nil {
+		return report, err
+	}
+	if !s.mu.TryLock() {
+		return report, custom_errors.NewBackupBusyError()
+	}
+	defer s.mu.Unlock()
+
+	start := s.now()
+	log := logger.FromContext(ctx, s.logger).With(logger.F("backup", name),
+		logger.F("mode", report.Mode), logger.F("dry_run", report.DryRun))
+	if _, err := s.read(ctx, name, func(entities.VaultItem) error { return nil }); err != nil {
+		return report, err
+	}
+	log.Info("restore started")
+
+	chunk := make([]entities.VaultItem, 0, restoreChunk)
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		result, err := s.src.Import(ctx, chunk, report.Mode, report.DryRun)
+		if err != nil {
+			return err
+		}
+		report.Records += len(chunk)
+		report.Created += result.Created
+		report.Updated += result.Updated
+		report.Skipped += result.Skipped
+		report.Conflicts += result.Conflicts
+		report.ConflictKeys = appendKeys(report.ConflictKeys, result.ConflictKeys...)
+		if !report.DryRun {
+			report.Committed += len(chunk)
+		}
+		chunk = chunk[:0]
+		return nil
+	}
+	_, err := s.read(ctx, name, func(item entities.VaultItem) error {
+		chunk = append(chunk, item)
+		if len(chunk) == restoreChunk {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		log.Warn("restore stopped", logger.F("records", report.Records), logger.F("committed", report.Committed), logger.Err(err))
+		return report, err
+	}
+
+	if !report.DryRun {
+		if err := s.compare(ctx, name, &report); err != nil {
+			log.Warn("restore verification failed", logger.Err(err))
+			return report, err
+		}
+		report.Verified = report.Mismatches == 0
+	}
+
+	log.Info("restore finished", logger.F("records", report.Records), logger.F("created", report.Created),
+		logger.F("updated", report.Updated), logger.F("skipped", report.Skipped),
+		logger.F("conflicts", report.Conflicts), logger.F("mismatches", report.Mismatches),
+		logger.F("duration_ms", time.Since(start).Milliseconds()))
+	return report, nil
+}
+
+// compare walks the archive and the storage side by side in key order
+func (s *Store) compare(ctx context.Context, name string, report *RestoreReport) error {
+	checkValues := report.Mode != entities.ImportSkipExisting
+	now := s.now()
+	var page []entities.VaultItem
+	after, done := "", false
+	_, err := s.read(ctx, name, func(item entities.VaultItem) error {
+		if item.Expired(now) {
+			return nil
+		}
+		for {
+			for len(page) > 0 && page[0].Key < item.Key {
+				page = page[1:]
+			}
+			if len(page) > 0 || done {
+				break
+			}
+			var err error
+			if page, err = s.src.List(ctx, "", after, usecases.MaxListLimit); err != nil {
+				return err
+			}
+			done = len(page) < usecases.MaxListLimit
+			if len(page) > 0 {
+				after = page[len(page)-1].Key
+			}
+		}
+		if len(page) == 0 || page[0].Key != item.Key || (checkValues && !sameItem(&page[0], &item)) {
+			report.Mismatches++
+			report.MismatchKeys = appendKeys(report.MismatchKeys, item.Key)
+		}
+		return nil
+	})
+	return err
+}
+
+// sameItem tells whether a stored item holds what the archive record does
+func sameItem(stored, archived *entities.VaultItem) bool {
+	return stored.Value == archived.Value && stored.ExpiresAt.Equal(archived.ExpiresAt) && stored.Flags == archived.Flags
+}
+
+// read opens and reads the archive name calling fn for every record, a
+// failing fn stops the read and its error is returned as is
+func (s *Store) read(ctx context.Context, name string, fn func(entities.VaultItem) error) (Info, error) {
+	createdAt, ok := parseName(name)
+	if !ok {
+		return Info{}, custom_errors.NewValidationError("name", i18n.MsgBackupName)
+	}
+	f, err := os.Open(filepath.Join(s.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return Info{}, custom_errors.NewBackupNotExistsError(name)
+	}
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to stat backup: %w", err)
+	}
+
+	r, err := NewReader(f)
+	if err != nil {
+		return Info{}, archiveError(name, err)
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return Info{}, err
+		}
+		item, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Info{}, archiveError(name, err)
+		}
+		if err := fn(item); err != nil {
+			return Info{}, err
+		}
+	}
+
+	summary, _ := r.Summary()
+	return Info{Name: name, CreatedAt: createdAt, Bytes: stat.Size(), Records: &summary.Records, SHA256: summary.SHA256}, nil
+}
+
+func (s *Store) exists(name string) bool {
+	_, err := os.Lstat(filepath.Join(s.dir, name))
+	return err == nil
+}
+
+// archiveError turns a reader error into the client error about name
+func archiveError(name string, err error) error {
+	var version *UnsupportedVersionError
+	switch {
+	case errors.As(err, &version):
+		return custom_errors.NewBackupVersionError(name, version.Version)
+	case errors.Is(err, ErrCorrupt):
+		return custom_errors.NewBackupCorruptError(name, err)
+	}
+	return fmt.Errorf("failed to read backup: %w", err)
+}
+
+func archiveName(createdAt time.Time) string {
+	return "vault-" + createdAt.UTC().Format(nameLayout) + ".backup.gz"
+}
+
+// parseName checks an archive name and returns its creation time, names
+// never contain path separators
+func parseName(name string) (time.Time, bool) {
+	m := namePattern.FindStringSubmatch(name)
+	if m == nil {
+		return time.Time{}, false
+	}
+	createdAt, err := time.Parse(nameLayout, m[1])
+	return createdAt, err == nil
+}
+
+func appendKeys(keys []string, more ...string) []string {
+	for _, key := range more {
+		if len(keys) < maxReportKeys {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// syncDir makes a rename in dir durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,254 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"github.com/vvjke314/vk-test-03-2025/internal/entities"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+	"github.com/vvjke314/vk-test-03-2025/internal/repository/repotest"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...logger.Field)        {}
+func (nopLogger) Info(string, ...logger.Field)         {}
+func (nopLogger) Warn(string, ...logger.Field)         {}
+func (nopLogger) Error(string, ...logger.Field)        {}
+func (l nopLogger) With(...logger.Field) logger.Logger { return l }
+
+// newMemSource returns a repository of n keys k0000, k0001...
+func newMemSource(n int) *repotest.Repo {
+	items := map[string]string{}
+	for i := 0; i < n; i++ {
+		items[fmt.Sprintf("k%04d", i)] = fmt.Sprintf(`{"n":%d}`, i)
+	}
+	return repotest.New(items)
+}
+
+func newTestStore(t *testing.T, src Source) *Store {
+	t.Helper()
+	store, err := NewStore(filepath.Join(t.TempDir(), "backups"), src, nopLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestStoreCreate(t *testing.T) {
+	src := newMemSource(2500)
+	store := newTestStore(t, src)
+
+	info, err := store.Create(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *info.Records != 2500 || len(info.SHA256) != 64 || info.Bytes == 0 {
+		t.Errorf("unexpected info %+v", info)
+	}
+
+	second, err := store.Create(context.Background())
+	if err != nil || second.Name == info.Name {
+		t.Fatalf("second backup: %+v %v", second, err)
+	}
+	list, err := store.List()
+	if err != nil || len(list) != 2 || list[0].Name != info.Name || list[1].Name != second.Name {
+		t.Fatalf("list: %+v %v", list, err)
+	}
+	if !list[0].CreatedAt.Equal(info.CreatedAt) || list[0].Bytes != info.Bytes || list[0].Records != nil {
+		t.Errorf("listed %+v, created %+v", list[0], info)
+	}
+
+	verified, err := store.Verify(context.Background(), info.Name)
+	if err != nil || *verified.Records != 2500 || verified.SHA256 != info.SHA256 {
+		t.Errorf("verify: %+v %v", verified, err)
+	}
+
+	// no temporary files are left
+	entries, _ := os.ReadDir(store.dir)
+	if len(entries) != 2 {
+		t.Errorf("directory holds %d files", len(entries))
+	}
+}
+
+func TestStoreRestore(t *testing.T) {
+	ctx := context.Background()
+	src := newMemSource(1200)
+	store := newTestStore(t, src)
+	info, err := store.Create(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// into an empty storage
+	empty := newMemSource(0)
+	store.src = empty
+	report, err := store.Restore(ctx, info.Name, RestoreOptions{})
+	if err != nil || report.Created != 1200 || !report.Verified || report.Committed != 1200 {
+		t.Fatalf("restore into empty: %+v %v", report, err)
+	}
+	if value, _ := empty.Value("k0042"); value != `{"n":42}` {
+		t.Errorf("restored value %q", value)
+	}
+
+	// existing keys with other values
+	empty.Set("k0001", `"local"`)
+	empty.Set("zzz", `"extra"`)
+
+	report, err = store.Restore(ctx, info.Name, RestoreOptions{DryRun: true})
+	if err != nil || report.Conflicts != 1200 || report.Committed != 0 || report.Verified {
+		t.Errorf("dry run: %+v %v", report, err)
+	}
+
+	report, err = store.Restore(ctx, info.Name, RestoreOptions{Mode: entities.ImportFailOnConflict})
+	if custom_errors.CodeOf(err) != custom_errors.CodeAlreadyExists || report.Committed != 0 {
+		t.Errorf("fail on conflict: %+v %v", report, err)
+	}
+
+	report, err = store.Restore(ctx, info.Name, RestoreOptions{Mode: entities.ImportSkipExisting})
+	if err != nil || report.Skipped != 1200 || !report.Verified {
+		t.Errorf("skip existing: %+v %v", report, err)
+	}
+
+	report, err = store.Restore(ctx, info.Name, RestoreOptions{Mode: entities.ImportOverwrite})
+	if value, _ := empty.Value("k0001"); err != nil || report.Updated != 1200 || !report.Verified || value != `{"n":1}` {
+		t.Errorf("overwrite: %+v %v", report, err)
+	}
+}
+
+func TestStoreVerifyMismatch(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t, newMemSource(10))
+	info, err := store.Create(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a storage dropping a write is caught by the comparison
+	lossy := &lossySource{Repo: newMemSource(0), drop: "k0003"}
+	store.src = lossy
+	report, err := store.Restore(ctx, info.Name, RestoreOptions{})
+	if err != nil || report.Verified || report.Mismatches != 1 || report.MismatchKeys[0] != "k0003" {
+		t.Errorf("got %+v %v, want one mismatch", report, err)
+	}
+}
+
+func TestStoreRestoreExpiryAndFlags(t *testing.T) {
+	ctx := context.Background()
+	src := newMemSource(3)
+	at := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	src.Import(ctx, []entities.VaultItem{
+		{Key: "k0000", Value: "1", ExpiresAt: at, Flags: 5},
+		{Key: "k0001", Value: "2", ExpiresAt: at},
+	}, entities.ImportOverwrite, false)
+	store := newTestStore(t, src)
+	info, err := store.Create(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	empty := newMemSource(0)
+	store.src = empty
+	report, err := store.Restore(ctx, info.Name, RestoreOptions{})
+	item, _ := empty.Get(ctx, "k0000")
+	if err != nil || !report.Verified || !item.ExpiresAt.Equal(at) || item.Flags != 5 {
+		t.Errorf("restore: %+v %+v %v", report, item, err)
+	}
+
+	// a storage losing flags is caught by the comparison
+	store.src = &flaglessSource{Repo: newMemSource(0)}
+	report, err = store.Restore(ctx, info.Name, RestoreOptions{})
+	if err != nil || report.Mismatches != 1 || report.MismatchKeys[0] != "k0000" {
+		t.Errorf("got %+v %v, want one mismatch", report, err)
+	}
+
+	// a record expired by the time of the restore is not compared
+	name := archiveName(time.Now())
+	expired := entities.VaultItem{Key: "gone", Value: "1", ExpiresAt: time.Now().Add(-time.Minute)}
+	if err := os.WriteFile(filepath.Join(store.dir, name), writeArchive(t, expired), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store.src = newMemSource(0)
+	report, err = store.Restore(ctx, name, RestoreOptions{})
+	if err != nil || !report.Verified || report.Records != 1 {
+		t.Errorf("restore of an expired record: %+v %v", report, err)
+	}
+}
+
+// flaglessSource writes items without their flags
+type flaglessSource struct {
+	*repotest.Repo
+}
+
+func (f *flaglessSource) Import(ctx context.Context, items []entities.VaultItem, mode entities.ImportMode, dryRun bool) (entities.ImportResult, error) {
+	stripped := make([]entities.VaultItem, len(items))
+	for i, item := range items {
+		item.Flags = 0
+		stripped[i] = item
+	}
+	return f.Repo.Import(ctx, stripped, mode, dryRun)
+}
+
+// lossySource loses the write of one key
+type lossySource struct {
+	*repotest.Repo
+	drop string
+}
+
+func (l *lossySource) Import(ctx context.Context, items []entities.VaultItem, mode entities.ImportMode, dryRun bool) (entities.ImportResult, error) {
+	result, err := l.Repo.Import(ctx, items, mode, dryRun)
+	l.Remove(l.drop)
+	return result, err
+}
+
+func TestStoreErrors(t *testing.T) {
+	ctx := context.Background()
+	src := newMemSource(3)
+	store := newTestStore(t, src)
+	info, err := store.Create(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"../secret", "vault-x.backup.gz", ""} {
+		if _, err := store.Verify(ctx, name); custom_errors.CodeOf(err) != custom_errors.CodeValidation {
+			t.Errorf("name %q: %v", name, err)
+		}
+	}
+	if _, err := store.Verify(ctx, "vault-20250301T120000.000Z.backup.gz"); custom_errors.CodeOf(err) != custom_errors.CodeNotFound {
+		t.Errorf("missing backup: %v", err)
+	}
+	if _, err := store.Restore(ctx, info.Name, RestoreOptions{Mode: "merge"}); custom_errors.CodeOf(err) != custom_errors.CodeValidation {
+		t.Errorf("invalid mode: %v", err)
+	}
+
+	// a damaged archive is refused before anything is written
+	path := filepath.Join(store.dir, info.Name)
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, data[:len(data)-4], 0o600)
+	empty := newMemSource(0)
+	store.src = empty
+	report, err := store.Restore(ctx, info.Name, RestoreOptions{})
+	if custom_errors.CodeOf(err) != custom_errors.CodeValidation || report.Committed != 0 || empty.Len() != 0 {
+		t.Errorf("damaged archive: %+v %v", report, err)
+	}
+
+	store.mu.Lock()
+	if _, err := store.Create(ctx); custom_errors.CodeOf(err) != custom_errors.CodeConflict {
+		t.Errorf("concurrent backup: %v", err)
+	}
+	store.mu.Unlock()
+}
+
+func TestParseName(t *testing.T) {
+	created := time.Date(2025, 3, 1, 12, 30, 5, 123e6, time.UTC)
+	got, ok := parseName(archiveName(created))
+	if !ok || !got.Equal(created) {
+		t.Errorf("parsed %v %v, want %v", got, ok, created)
+	}
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"container/list"
+	"time"
+
+	"github.com/vvjke314/vk-test-03-2025/internal/entities"
+)
+
+// entryOverhead approximates memory used by an entry besides key and value
+const entryOverhead = 128
+
+// entry is a cached value or a cached absence of the key
+type entry struct {
+	key     string
+	item    entities.VaultItem
+	missing bool
+	expires time.Time
+	size    int64
+}
+
+// lru is a least recently used set of entries bounded by their total size.
+// It is not safe for concurrent use.
+type lru struct {
+	maxBytes int64
+	bytes    int64
+	order    *list.List // front is the most recently used
+	entries  map[string]*list.Element
+
+	evictions uint64
+}
+
+func newLRU(maxBytes int64) *lru {
+	return &lru{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// get returns entry for key and marks it as recently used
+func (l *lru) get(key string) (*entry, bool) {
+	el, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+	l.order.MoveToFront(el)
+	return el.Value.(*entry), true
+}
+
+// add stores e replacing any entry for the same key and evicts least
+// recently used entries until the size bound holds. Entries larger than
+// the bound are not stored.
+func (l *lru) add(e *entry) {
+	e.size = int64(len(e.key)+len(e.item.Key)+len(e.item.Value)) + entryOverhead
+	l.remove(e.key)
+	if e.size > l.maxBytes {
+		return
+	}
+
+	l.entries[e.key] = l.order.PushFront(e)
+	l.bytes += e.size
+
+	for l.bytes > l.maxBytes {
+		oldest := l.order.Back()
+		l.removeElement(oldest)
+		l.evictions++
+	}
+}
+
+// remove deletes entry for key if present
+func (l *lru) remove(key string) {
+	if el, ok := l.entries[key]; ok {
+		l.removeElement(el)
+	}
+}
+
+// purge deletes every entry
+func (l *lru) purge() {
+	l.order.Init()
+	l.entries = make(map[string]*list.Element)
+	l.bytes = 0
+}
+
+func (l *lru) removeElement(el *list.Element) {
+	e := l.order.Remove(el).(*entry)
+	delete(l.entries, e.key)
+	l.bytes -= e.size
+}
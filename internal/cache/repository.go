@@ -0,0 +1,246 @@
+package cache
+
+import (
+	"context"
+	"hash/maphash"
+	"sync"
+	"time"
+
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"github.com/vvjke314/vk-test-03-2025/internal/entities"
+	"github.com/vvjke314/vk-test-03-2025/internal/metrics"
+	"golang.org/x/sync/singleflight"
+)
+
+// Repository is the storage wrapped by the cache, it matches the
+// repository expected by usecases.KeyValueUseCase
+type Repository interface {
+	Insert(ctx context.Context, item entities.VaultItem) error
+	Update(ctx context.Context, item entities.VaultItem) error
+	Expire(ctx context.Context, key string, at time.Time) error
+	Delete(ctx context.Context, key string) error
+	Get(ctx context.Context, key string) (entities.VaultItem, error)
+	List(ctx context.Context, prefix, after string, limit int) ([]entities.VaultItem, error)
+	GetVersioned(ctx context.Context, key string) (entities.VaultItem, uint64, error)
+	CompareAndSwap(ctx context.Context, item entities.VaultItem, version uint64) error
+	Import(ctx context.Context, items []entities.VaultItem, mode entities.ImportMode, dryRun bool) (entities.ImportResult, error)
+	Dump(ctx context.Context, fn func([]entities.VaultItem) error) error
+	Ping(ctx context.Context) error
+}
+
+// Options configures CachedRepository
+type Options struct {
+	MaxBytes    int64         // Upper bound of the estimated cache size
+	TTL         time.Duration // How long a value is served from the cache
+	NegativeTTL time.Duration // How long a missing key is remembered, zero disables
+	LoadTimeout time.Duration // Deadline of a shared storage read, zero means none
+}
+
+// generationShards is the number of invalidation counters, a write only
+// keeps loads of keys in its shard from being cached
+const generationShards = 256
+
+// CachedRepository is a read-through cache in front of Repository.
+// Concurrent misses of one key share a single storage call. Local writes
+// invalidate the key, writes of other clients are picked up through
+// Invalidate and Purge or after TTL.
+type CachedRepository struct {
+	repo Repository
+	opts Options
+
+	mu          sync.Mutex
+	lru         *lru
+	generations [generationShards]uint64 // incremented by invalidations of the shard
+	seed        maphash.Seed
+	hits        uint64
+	negHits     uint64
+	misses      uint64
+
+	group singleflight.Group
+	now   func() time.Time
+}
+
+// New wraps repo with a cache
+func New(repo Repository, opts Options) *CachedRepository {
+	return &CachedRepository{
+		repo: repo,
+		opts: opts,
+		lru:  newLRU(opts.MaxBytes),
+		seed: maphash.MakeSeed(),
+		now:  time.Now,
+	}
+}
+
+// Get returns cached value of key or loads it from the storage
+func (c *CachedRepository) Get(ctx context.Context, key string) (entities.VaultItem, error) {
+	c.mu.Lock()
+	if e, ok := c.lru.get(key); ok && c.now().Before(e.expires) {
+		if e.missing {
+			c.negHits++
+			c.mu.Unlock()
+			return entities.VaultItem{}, custom_errors.NewKeyNotExistsError(key)
+		}
+		c.hits++
+		c.mu.Unlock()
+		return e.item, nil
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	result := c.group.DoChan(key, func() (interface{}, error) {
+		return c.load(context.WithoutCancel(ctx), key)
+	})
+	select {
+	case r := <-result:
+		if r.Err != nil {
+			return entities.VaultItem{}, r.Err
+		}
+		return r.Val.(entities.VaultItem), nil
+	case <-ctx.Done():
+		return entities.VaultItem{}, ctx.Err()
+	}
+}
+
+// load reads key from the storage and caches the outcome unless the key
+// was invalidated meanwhile. It is shared by callers waiting for the same
+// key, so it is bounded by LoadTimeout rather than by the first caller's
+// context, which only lends its values.
+func (c *CachedRepository) load(ctx context.Context, key string) (entities.VaultItem, error) {
+	if c.opts.LoadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.LoadTimeout)
+		defer cancel()
+	}
+
+	shard := c.shard(key)
+	c.mu.Lock()
+	generation := c.generations[shard]
+	c.mu.Unlock()
+
+	item, err := c.repo.Get(ctx, key)
+	missing := custom_errors.CodeOf(err) == custom_errors.CodeNotFound
+	if err != nil && !missing {
+		return entities.VaultItem{}, err
+	}
+
+	ttl := c.opts.TTL
+	if missing {
+		ttl = c.opts.NegativeTTL
+	}
+	expires := c.now().Add(ttl)
+	// the key is not served after it expires in the storage
+	if !item.ExpiresAt.IsZero() && item.ExpiresAt.Before(expires) {
+		expires = item.ExpiresAt
+	}
+
+	c.mu.Lock()
+	if generation == c.generations[shard] && ttl > 0 {
+		c.lru.add(&entry{key: key, item: item, missing: missing, expires: expires})
+	}
+	c.mu.Unlock()
+
+	return item, err
+}
+
+// Insert stores item and invalidates its key
+func (c *CachedRepository) Insert(ctx context.Context, item entities.VaultItem) error {
+	defer c.Invalidate(item.Key)
+	return c.repo.Insert(ctx, item)
+}
+
+// Update stores new value and invalidates the key
+func (c *CachedRepository) Update(ctx context.Context, item entities.VaultItem) error {
+	defer c.Invalidate(item.Key)
+	return c.repo.Update(ctx, item)
+}
+
+// Expire sets the expiry of key and invalidates it
+func (c *CachedRepository) Expire(ctx context.Context, key string, at time.Time) error {
+	defer c.Invalidate(key)
+	return c.repo.Expire(ctx, key, at)
+}
+
+// Delete removes key from the storage and the cache
+func (c *CachedRepository) Delete(ctx context.Context, key string) error {
+	defer c.Invalidate(key)
+	return c.repo.Delete(ctx, key)
+}
+
+// List reads the storage, scans are not cached
+func (c *CachedRepository) List(ctx context.Context, prefix, after string, limit int) ([]entities.VaultItem, error) {
+	return c.repo.List(ctx, prefix, after, limit)
+}
+
+// GetVersioned reads the storage, versions must not be stale
+func (c *CachedRepository) GetVersioned(ctx context.Context, key string) (entities.VaultItem, uint64, error) {
+	return c.repo.GetVersioned(ctx, key)
+}
+
+// CompareAndSwap stores new value and invalidates the key
+func (c *CachedRepository) CompareAndSwap(ctx context.Context, item entities.VaultItem, version uint64) error {
+	defer c.Invalidate(item.Key)
+	return c.repo.CompareAndSwap(ctx, item, version)
+}
+
+// Import writes items to the storage and invalidates their keys
+func (c *CachedRepository) Import(ctx context.Context, items []entities.VaultItem, mode entities.ImportMode, dryRun bool) (entities.ImportResult, error) {
+	if !dryRun {
+		defer func() {
+			for _, item := range items {
+				c.Invalidate(item.Key)
+			}
+		}()
+	}
+	return c.repo.Import(ctx, items, mode, dryRun)
+}
+
+// Dump reads the storage, a cached value may be newer than the dump
+func (c *CachedRepository) Dump(ctx context.Context, fn func([]entities.VaultItem) error) error {
+	return c.repo.Dump(ctx, fn)
+}
+
+// Ping checks the storage, the cache is not involved
+func (c *CachedRepository) Ping(ctx context.Context) error {
+	return c.repo.Ping(ctx)
+}
+
+// Invalidate drops cached entry of key. Loads of keys sharing its
+// generation shard already in flight are not cached, callers arriving later
+// start a new load.
+func (c *CachedRepository) Invalidate(key string) {
+	c.mu.Lock()
+	c.lru.remove(key)
+	c.generations[c.shard(key)]++
+	c.mu.Unlock()
+	c.group.Forget(key)
+}
+
+// Purge drops every cached entry
+func (c *CachedRepository) Purge() {
+	c.mu.Lock()
+	c.lru.purge()
+	for i := range c.generations {
+		c.generations[i]++
+	}
+	c.mu.Unlock()
+}
+
+// shard returns the generation counter of key
+func (c *CachedRepository) shard(key string) int {
+	return int(maphash.String(c.seed, key) % generationShards)
+}
+
+// CacheCounts makes CachedRepository satisfy metrics.CacheStats
+func (c *CachedRepository) CacheCounts() metrics.CacheCounts {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return metrics.CacheCounts{
+		Hits:         c.hits,
+		NegativeHits: c.negHits,
+		Misses:       c.misses,
+		Evictions:    c.lru.evictions,
+		Entries:      len(c.lru.entries),
+		Bytes:        c.lru.bytes,
+	}
+}
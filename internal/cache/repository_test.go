@@ -0,0 +1,320 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"github.com/vvjke314/vk-test-03-2025/internal/entities"
+	"github.com/vvjke314/vk-test-03-2025/internal/repository/repotest"
+)
+
+// fakeRepo counts storage reads and optionally blocks them until release
+// is closed
+type fakeRepo struct {
+	*repotest.Repo
+	gets    atomic.Int32
+	release chan struct{}
+}
+
+func newFakeRepo() *fakeRepo {
+	f := &fakeRepo{Repo: repotest.New(nil)}
+	f.OnGet = func(context.Context, string) error {
+		f.gets.Add(1)
+		if f.release != nil {
+			<-f.release
+		}
+		return nil
+	}
+	return f
+}
+
+func newTestCache(repo Repository) *CachedRepository {
+	return New(repo, Options{MaxBytes: 1 << 20, TTL: time.Minute, NegativeTTL: time.Second})
+}
+
+func TestCacheHit(t *testing.T) {
+	repo := newFakeRepo()
+	repo.Set("a", "1")
+	c := newTestCache(repo)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		item, err := c.Get(ctx, "a")
+		if err != nil || item.Value != "1" {
+			t.Fatalf("unexpected result %v, %v", item, err)
+		}
+	}
+
+	if n := repo.gets.Load(); n != 1 {
+		t.Errorf("expected 1 storage call, got %d", n)
+	}
+	if s := c.CacheCounts(); s.Hits != 2 || s.Misses != 1 || s.Entries != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestCacheNegative(t *testing.T) {
+	repo := newFakeRepo()
+	c := newTestCache(repo)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Get(ctx, "a"); !errors.Is(err, custom_errors.ErrKeyNotExists) {
+			t.Fatalf("expected not found, got %v", err)
+		}
+	}
+	if n := repo.gets.Load(); n != 1 {
+		t.Errorf("missing key not cached, %d storage calls", n)
+	}
+
+	// negative entries expire sooner than values
+	repo.Set("a", "1")
+	now = now.Add(2 * time.Second)
+	if item, err := c.Get(ctx, "a"); err != nil || item.Value != "1" {
+		t.Errorf("expired negative entry served: %v, %v", item, err)
+	}
+	if s := c.CacheCounts(); s.NegativeHits != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	repo := newFakeRepo()
+	repo.Set("a", "1")
+	c := newTestCache(repo)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	c.Get(ctx, "a")
+	repo.Set("a", "2")
+
+	if item, _ := c.Get(ctx, "a"); item.Value != "1" {
+		t.Errorf("expected cached value, got %q", item.Value)
+	}
+	now = now.Add(time.Minute)
+	if item, _ := c.Get(ctx, "a"); item.Value != "2" {
+		t.Errorf("expected reloaded value, got %q", item.Value)
+	}
+}
+
+func TestCacheKeyExpiry(t *testing.T) {
+	repo := newFakeRepo()
+	expires := time.Now().Add(time.Hour)
+	repo.Insert(context.Background(), entities.VaultItem{Key: "a", Value: "1", ExpiresAt: expires})
+	c := newTestCache(repo)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	c.Get(ctx, "a")
+	// the entry lives no longer than the key, even when within the TTL
+	now = expires
+	c.Get(ctx, "a")
+	if n := repo.gets.Load(); n != 2 {
+		t.Errorf("value served after its key expired, %d storage calls", n)
+	}
+}
+
+func TestCacheInvalidateOnWrite(t *testing.T) {
+	repo := newFakeRepo()
+	c := newTestCache(repo)
+	ctx := context.Background()
+
+	c.Get(ctx, "a") // caches absence
+	c.Insert(ctx, entities.VaultItem{Key: "a", Value: "1"})
+	if item, err := c.Get(ctx, "a"); err != nil || item.Value != "1" {
+		t.Fatalf("stale after insert: %v, %v", item, err)
+	}
+
+	c.Update(ctx, entities.VaultItem{Key: "a", Value: "2"})
+	if item, _ := c.Get(ctx, "a"); item.Value != "2" {
+		t.Fatalf("stale after update: %q", item.Value)
+	}
+
+	_, version, _ := repo.GetVersioned(ctx, "a")
+	c.CompareAndSwap(ctx, entities.VaultItem{Key: "a", Value: "3"}, version)
+	if item, _ := c.Get(ctx, "a"); item.Value != "3" {
+		t.Fatalf("stale after compare and swap: %q", item.Value)
+	}
+
+	c.Delete(ctx, "a")
+	if _, err := c.Get(ctx, "a"); !errors.Is(err, custom_errors.ErrKeyNotExists) {
+		t.Fatalf("stale after delete: %v", err)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	repo := newFakeRepo()
+	value := strings.Repeat("x", 100)
+	for _, k := range []string{"a", "b", "c"} {
+		repo.Set(k, value)
+	}
+	// room for two entries only
+	entrySize := int64(2+len(value)) + entryOverhead
+	c := New(repo, Options{MaxBytes: 2 * entrySize, TTL: time.Minute})
+	ctx := context.Background()
+
+	c.Get(ctx, "a")
+	c.Get(ctx, "b")
+	c.Get(ctx, "a") // b is now least recently used
+	c.Get(ctx, "c")
+
+	s := c.CacheCounts()
+	if s.Entries != 2 || s.Evictions != 1 || s.Bytes != 2*entrySize {
+		t.Errorf("unexpected stats %+v", s)
+	}
+
+	calls := repo.gets.Load()
+	c.Get(ctx, "a")
+	if repo.gets.Load() != calls {
+		t.Errorf("recently used entry was evicted")
+	}
+	c.Get(ctx, "b")
+	if repo.gets.Load() != calls+1 {
+		t.Errorf("least recently used entry was not evicted")
+	}
+}
+
+func TestCacheSingleflight(t *testing.T) {
+	repo := newFakeRepo()
+	repo.Set("a", "1")
+	repo.release = make(chan struct{})
+	c := newTestCache(repo)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if item, err := c.Get(context.Background(), "a"); err != nil || item.Value != "1" {
+				t.Errorf("unexpected result %v, %v", item, err)
+			}
+		}()
+	}
+
+	// let every goroutine reach the cache before the load completes
+	for c.CacheCounts().Misses < 10 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(repo.release)
+	wg.Wait()
+
+	if n := repo.gets.Load(); n != 1 {
+		t.Errorf("expected 1 storage call, got %d", n)
+	}
+}
+
+func TestCacheInvalidateDuringLoad(t *testing.T) {
+	repo := newFakeRepo()
+	repo.Set("a", "1")
+	repo.release = make(chan struct{})
+	c := newTestCache(repo)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Get(context.Background(), "a")
+	}()
+	for repo.gets.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	c.Invalidate("a")
+	close(repo.release)
+	<-done
+
+	if s := c.CacheCounts(); s.Entries != 0 {
+		t.Errorf("value loaded before invalidation was cached")
+	}
+}
+
+func TestCacheLoadOutlivesFirstCaller(t *testing.T) {
+	repo := newFakeRepo()
+	repo.Set("a", "1")
+	repo.release = make(chan struct{})
+	c := newTestCache(repo)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := c.Get(ctx, "a")
+		first <- err
+	}()
+	for repo.gets.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	second := make(chan entities.VaultItem)
+	go func() {
+		item, _ := c.Get(context.Background(), "a")
+		second <- item
+	}()
+	for c.CacheCounts().Misses < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("first caller got %v", err)
+	}
+	close(repo.release)
+	if item := <-second; item.Value != "1" {
+		t.Errorf("second caller got %+v", item)
+	}
+	if n := repo.gets.Load(); n != 1 {
+		t.Errorf("expected 1 storage call, got %d", n)
+	}
+}
+
+func TestCacheLoadTimeout(t *testing.T) {
+	// the read blocks until the context is done
+	repo := repotest.New(nil)
+	repo.OnGet = func(ctx context.Context, _ string) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	c := New(repo, Options{MaxBytes: 1 << 20, TTL: time.Minute, LoadTimeout: 10 * time.Millisecond})
+
+	if _, err := c.Get(context.Background(), "a"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the load deadline", err)
+	}
+}
+
+func TestCacheInvalidateOtherKeyDuringLoad(t *testing.T) {
+	repo := newFakeRepo()
+	repo.Set("a", "1")
+	repo.release = make(chan struct{})
+	c := newTestCache(repo)
+
+	// a key of another generation shard
+	other := "b"
+	for i := 0; c.shard(other) == c.shard("a"); i++ {
+		other = "b" + strings.Repeat("x", i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Get(context.Background(), "a")
+	}()
+	for repo.gets.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	c.Invalidate(other)
+	close(repo.release)
+	<-done
+
+	if s := c.CacheCounts(); s.Entries != 1 {
+		t.Errorf("write of %q kept the load of a from being cached", other)
+	}
+}
@@ -1,28 +1,285 @@
 package custom_errors
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vvjke314/vk-test-03-2025/internal/i18n"
+)
+
+// Code is a stable machine-readable error class
+type Code string
+
+const (
+	CodeValidation      Code = "validation"
+	CodeUnauthenticated Code = "unauthenticated"
+	CodeNotFound        Code = "not_found"
+	CodeAlreadyExists   Code = "already_exists"
+	CodeConflict        Code = "conflict"
+	CodeTooLarge        Code = "too_large"
+	CodeRateLimited     Code = "rate_limited"
+	CodeCanceled        Code = "canceled"
+	CodeTimeout         Code = "timeout"
+	CodeUnavailable     Code = "unavailable"
+	CodeInternal        Code = "internal"
 )
 
-// define error to provide errors.Is()
-var ErrKeyNotExists = errors.New("ключ не существует")
+// Error is an application error carrying its class.
+// errors.Is matches any two errors of the same code.
+type Error struct {
+	Code    Code
+	Message string         // English text used in logs
+	MsgID   i18n.MessageID // Catalog message shown to clients
+	Key     string         // Key the error is about, if any
+	Field   string         // Request field that failed validation, if any
+	Params  i18n.Params    // Extra values of the message placeholders
+	Details []*Error       // Field errors collected into one validation error
+	Err     error          // Underlying cause
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+// Unwrap provides the cause to errors.Is and errors.As
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Localize renders the client message in lang
+func (e *Error) Localize(lang i18n.Lang) string {
+	id := e.MsgID
+	if id == "" {
+		id = e.Code.message()
+	}
+	return i18n.Translate(lang, id, e.params())
+}
 
-// KeyNotExistsError defines custom error
-type KeyNotExistsError struct {
-	Key string
+func (e *Error) params() i18n.Params {
+	params := i18n.Params{"key": e.Key}
+	for name, value := range e.Params {
+		params[name] = value
+	}
+	return params
 }
 
-func (e *KeyNotExistsError) Error() string {
-	return fmt.Sprintf("ключ '%s' не существует", e.Key)
+// Title returns the generic description of code in lang
+func (c Code) Title(lang i18n.Lang) string {
+	return i18n.Translate(lang, c.message(), nil)
 }
 
-// Unwrap provide to use errors.Is() method
-func (e *KeyNotExistsError) Unwrap() error {
-	return ErrKeyNotExists
+func (c Code) message() i18n.MessageID {
+	if id, ok := codeMessages[c]; ok {
+		return id
+	}
+	return i18n.MsgInternal
 }
 
-// NewKeyNotExistsError creates custom error instance
+var codeMessages = map[Code]i18n.MessageID{
+	CodeValidation:      i18n.MsgValidation,
+	CodeUnauthenticated: i18n.MsgUnauthenticated,
+	CodeNotFound:        i18n.MsgNotFound,
+	CodeAlreadyExists:   i18n.MsgAlreadyExists,
+	CodeConflict:        i18n.MsgConflict,
+	CodeTooLarge:        i18n.MsgTooLarge,
+	CodeRateLimited:     i18n.MsgRateLimited,
+	CodeCanceled:        i18n.MsgCanceled,
+	CodeTimeout:         i18n.MsgTimeout,
+	CodeUnavailable:     i18n.MsgUnavailable,
+	CodeInternal:        i18n.MsgInternal,
+}
+
+// Is makes errors.Is(err, ErrNotFound) true for every not found error
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code && t.Key == "" && t.Field == "" && t.Params == nil && t.Details == nil && t.Err == nil
+}
+
+// define errors to provide errors.Is()
+var (
+	ErrValidation      = &Error{Code: CodeValidation, Message: "invalid request"}
+	ErrUnauthenticated = &Error{Code: CodeUnauthenticated, Message: "authentication required"}
+	ErrNotFound        = &Error{Code: CodeNotFound, Message: "not found"}
+	ErrAlreadyExists   = &Error{Code: CodeAlreadyExists, Message: "already exists"}
+	ErrConflict        = &Error{Code: CodeConflict, Message: "conflict"}
+	ErrTooLarge        = &Error{Code: CodeTooLarge, Message: "payload too large"}
+	ErrRateLimited     = &Error{Code: CodeRateLimited, Message: "too many requests"}
+	ErrCanceled        = &Error{Code: CodeCanceled, Message: "request canceled"}
+	ErrTimeout         = &Error{Code: CodeTimeout, Message: "request timed out"}
+	ErrUnavailable     = &Error{Code: CodeUnavailable, Message: "storage unavailable"}
+	ErrInternal        = &Error{Code: CodeInternal, Message: "internal error"}
+
+	// ErrKeyNotExists is kept for callers matching missing keys
+	ErrKeyNotExists = ErrNotFound
+	// ErrKeyExists matches attempts to create an existing key
+	ErrKeyExists = ErrAlreadyExists
+)
+
+// NewKeyNotExistsError creates error about missing key
 func NewKeyNotExistsError(key string) error {
-	return &KeyNotExistsError{Key: key}
+	return newError(CodeNotFound, i18n.MsgKeyNotExists, key, "", nil)
+}
+
+// NewKeyExistsError creates error about already existing key
+func NewKeyExistsError(key string) error {
+	return newError(CodeAlreadyExists, i18n.MsgKeyExists, key, "", nil)
+}
+
+// NewVersionConflictError creates error about key changed since its version was read
+func NewVersionConflictError(key string) error {
+	return newError(CodeConflict, i18n.MsgVersionConflict, key, "", nil)
+}
+
+// NewValidationError creates error about invalid request field
+func NewValidationError(field string, id i18n.MessageID) error {
+	return newError(CodeValidation, id, "", field, nil)
+}
+
+// NewUnavailableError wraps a storage connectivity failure
+func NewUnavailableError(err error) error {
+	return newError(CodeUnavailable, i18n.MsgUnavailable, "", "", err)
+}
+
+// NewTimeoutError wraps a call that exceeded its deadline
+func NewTimeoutError(err error) error {
+	return newError(CodeTimeout, i18n.MsgTimeout, "", "", err)
+}
+
+// NewFieldError creates validation error with message placeholders filled from params
+func NewFieldError(field string, id i18n.MessageID, params i18n.Params) error {
+	e := newError(CodeValidation, id, "", field, nil)
+	e.setParams(params)
+	return e
+}
+
+// NewValidationErrors joins field errors. A single error is returned as is,
+// several are wrapped into one validation error listing them in Details.
+// Nil errors are skipped, nil is returned when nothing is left.
+func NewValidationErrors(errs ...error) error {
+	var details []*Error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		e, ok := As(err)
+		if !ok {
+			e = &Error{Code: CodeValidation, Message: err.Error(), Err: err}
+		}
+		details = append(details, e)
+	}
+
+	switch len(details) {
+	case 0:
+		return nil
+	case 1:
+		return details[0]
+	}
+
+	messages := make([]string, len(details))
+	for i, d := range details {
+		messages[i] = d.Field + ": " + d.Message
+	}
+	return &Error{
+		Code:    CodeValidation,
+		Message: i18n.Translate(i18n.English, i18n.MsgInvalidFields, nil) + ": " + strings.Join(messages, "; "),
+		MsgID:   i18n.MsgInvalidFields,
+		Details: details,
+	}
+}
+
+// NewRecordError reports an invalid record on line of a bulk request body,
+// the errors of its fields are listed in Details
+func NewRecordError(line int, err error) error {
+	cause, ok := As(err)
+	if !ok {
+		cause = &Error{Code: CodeValidation, Message: err.Error(), Err: err}
+	}
+	details := cause.Details
+	if details == nil {
+		details = []*Error{cause}
+	}
+	e := newError(cause.Code, i18n.MsgImportRecord, "", "body", nil)
+	e.Details = details
+	e.setParams(i18n.Params{"line": strconv.Itoa(line)})
+	e.Message += ": " + cause.Message
+	return e
+}
+
+// NewTooLargeError creates error about field exceeding limit bytes
+func NewTooLargeError(field string, id i18n.MessageID, limit int64) error {
+	e := newError(CodeTooLarge, id, "", field, nil)
+	e.setParams(i18n.Params{"limit": strconv.FormatInt(limit, 10)})
+	return e
+}
+
+// NewBackupNotExistsError creates error about missing backup archive
+func NewBackupNotExistsError(name string) error {
+	e := newError(CodeNotFound, i18n.MsgBackupNotExists, "", "name", nil)
+	e.setParams(i18n.Params{"name": name})
+	return e
+}
+
+// NewBackupCorruptError reports an archive failing verification, err tells why
+func NewBackupCorruptError(name string, err error) error {
+	e := newError(CodeValidation, i18n.MsgBackupCorrupt, "", "name", err)
+	e.setParams(i18n.Params{"name": name})
+	return e
+}
+
+// NewBackupVersionError reports an archive written in an unknown format version
+func NewBackupVersionError(name string, version int) error {
+	e := newError(CodeValidation, i18n.MsgBackupVersion, "", "name", nil)
+	e.setParams(i18n.Params{"name": name, "version": strconv.Itoa(version)})
+	return e
+}
+
+// NewBackupBusyError reports a backup operation started while another runs
+func NewBackupBusyError() error {
+	return newError(CodeConflict, i18n.MsgBackupBusy, "", "", nil)
+}
+
+func newError(code Code, id i18n.MessageID, key, field string, err error) *Error {
+	e := &Error{
+		Code:  code,
+		MsgID: id,
+		Key:   key,
+		Field: field,
+		Err:   err,
+	}
+	e.Message = i18n.Translate(i18n.English, id, e.params())
+	return e
+}
+
+func (e *Error) setParams(params i18n.Params) {
+	e.Params = params
+	e.Message = i18n.Translate(i18n.English, e.MsgID, e.params())
+}
+
+// CodeOf classifies any error, unknown errors are internal
+func CodeOf(err error) Code {
+	var e *Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &e):
+		return e.Code
+	case errors.Is(err, context.DeadlineExceeded):
+		return CodeTimeout
+	case errors.Is(err, context.Canceled):
+		return CodeCanceled
+	}
+	return CodeInternal
+}
+
+// As returns the first *Error in err's chain
+func As(err error) (*Error, bool) {
+	var e *Error
+	ok := errors.As(err, &e)
+	return e, ok
 }
@@ -0,0 +1,78 @@
+package custom_errors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/vvjke314/vk-test-03-2025/internal/i18n"
+)
+
+func TestCodeOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		want Code
+	}{
+		{nil, ""},
+		{NewKeyNotExistsError("a"), CodeNotFound},
+		{fmt.Errorf("wrapped: %w", NewKeyExistsError("a")), CodeAlreadyExists},
+		{NewValidationError("key", "bad"), CodeValidation},
+		{NewTimeoutError(context.DeadlineExceeded), CodeTimeout},
+		{fmt.Errorf("call: %w", context.DeadlineExceeded), CodeTimeout},
+		{context.Canceled, CodeCanceled},
+		{errors.New("boom"), CodeInternal},
+	}
+
+	for _, tt := range tests {
+		if got := CodeOf(tt.err); got != tt.want {
+			t.Errorf("CodeOf(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestIs(t *testing.T) {
+	err := fmt.Errorf("get: %w", NewKeyNotExistsError("a"))
+	if !errors.Is(err, ErrKeyNotExists) {
+		t.Error("not found error does not match ErrKeyNotExists")
+	}
+	if errors.Is(err, ErrKeyExists) {
+		t.Error("not found error matches ErrKeyExists")
+	}
+	if errors.Is(ErrNotFound, NewKeyNotExistsError("a")) {
+		t.Error("sentinel matches a specific key error")
+	}
+
+	cause := errors.New("connection refused")
+	if !errors.Is(NewUnavailableError(cause), cause) {
+		t.Error("cause is not unwrapped")
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	e, _ := As(NewKeyNotExistsError("a"))
+	if got := e.Localize(i18n.Russian); got != "ключ 'a' не существует" {
+		t.Errorf("unexpected ru message %q", got)
+	}
+	if e.Error() != "key 'a' does not exist" {
+		t.Errorf("log message is not English: %q", e.Error())
+	}
+	if got := ErrTimeout.Localize(i18n.Russian); got != CodeTimeout.Title(i18n.Russian) {
+		t.Errorf("sentinel does not use generic code message: %q", got)
+	}
+}
+
+func TestRecordError(t *testing.T) {
+	err := NewRecordError(3, NewTooLargeError("value", i18n.MsgValueTooLarge, 4))
+	e, _ := As(err)
+	if e.Code != CodeTooLarge || e.Field != "body" || len(e.Details) != 1 || e.Details[0].Field != "value" {
+		t.Fatalf("unexpected record error %+v", e)
+	}
+	if got := e.Localize(i18n.Russian); got != "некорректная запись в строке 3" {
+		t.Errorf("unexpected ru message %q", got)
+	}
+	if !strings.HasPrefix(e.Error(), "record on line 3 is invalid: ") {
+		t.Errorf("log message misses the cause: %q", e.Error())
+	}
+}
@@ -1,6 +1,33 @@
 package entities
 
+import "time"
+
 type VaultItem struct {
-	Key   string `msgpack:"key"`
-	Value string `msgpack:"value"`
+	Key       string    `msgpack:"key"`
+	Value     string    `msgpack:"value"`
+	ExpiresAt time.Time `msgpack:"-"` // Zero when the key never expires
+	Flags     uint32    `msgpack:"-"` // Opaque flags of memcached clients, zero for the rest
+}
+
+// Expired tells whether the item expired by now
+func (i VaultItem) Expired(now time.Time) bool {
+	return !i.ExpiresAt.IsZero() && !now.Before(i.ExpiresAt)
+}
+
+// ImportMode decides what an import does with keys that already exist
+type ImportMode string
+
+const (
+	ImportFailOnConflict ImportMode = "fail-on-conflict" // Stop at the first existing key
+	ImportOverwrite      ImportMode = "overwrite"        // Replace values of existing keys
+	ImportSkipExisting   ImportMode = "skip-existing"    // Keep existing keys as they are
+)
+
+// ImportResult counts what an import did or, in a dry run, would do
+type ImportResult struct {
+	Created      int
+	Updated      int
+	Skipped      int
+	Conflicts    int      // Existing keys met in fail-on-conflict mode
+	ConflictKeys []string // First of the conflicting keys
 }
@@ -0,0 +1,250 @@
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Lang is a supported response language
+type Lang string
+
+const (
+	English Lang = "en"
+	Russian Lang = "ru"
+
+	// Default is used when the client accepts none of the supported languages
+	Default = English
+)
+
+// MessageID identifies a message in the catalog
+type MessageID string
+
+const (
+	// Generic messages, one per error code
+	MsgValidation      MessageID = "validation"
+	MsgTooLarge        MessageID = "too_large"
+	MsgUnauthenticated MessageID = "unauthenticated"
+	MsgNotFound        MessageID = "not_found"
+	MsgAlreadyExists   MessageID = "already_exists"
+	MsgConflict        MessageID = "conflict"
+	MsgRateLimited     MessageID = "rate_limited"
+	MsgCanceled        MessageID = "canceled"
+	MsgTimeout         MessageID = "timeout"
+	MsgUnavailable     MessageID = "unavailable"
+	MsgInternal        MessageID = "internal"
+
+	MsgKeyNotExists     MessageID = "key_not_exists"
+	MsgKeyExists        MessageID = "key_exists"
+	MsgKeyEmpty         MessageID = "key_empty"
+	MsgValueEmpty       MessageID = "value_empty"
+	MsgValueInvalidJSON MessageID = "value_invalid_json"
+	MsgBodyInvalidJSON  MessageID = "body_invalid_json"
+	MsgLevelInvalid     MessageID = "level_invalid"
+	MsgKeyTooLong       MessageID = "key_too_long"
+	MsgKeyCharset       MessageID = "key_charset"
+	MsgValueTooLarge    MessageID = "value_too_large"
+	MsgBodyTooLarge     MessageID = "body_too_large"
+	MsgKeyWhitespace    MessageID = "key_whitespace"
+	MsgKeyControl       MessageID = "key_control"
+	MsgKeyNotURLSafe    MessageID = "key_not_url_safe"
+	MsgFieldUnknown     MessageID = "field_unknown"
+	MsgFieldRequired    MessageID = "field_required"
+	MsgFieldString      MessageID = "field_string"
+	MsgFieldUint32      MessageID = "field_uint32"
+	MsgFieldTime        MessageID = "field_time"
+	MsgBodyNotObject    MessageID = "body_not_object"
+	MsgInvalidFields    MessageID = "invalid_fields"
+	MsgLimitRange       MessageID = "limit_range"
+	MsgPageToken        MessageID = "page_token"
+	MsgBatchSize        MessageID = "batch_size"
+	MsgOperationEmpty   MessageID = "operation_empty"
+	MsgValueNotUTF8     MessageID = "value_not_utf8"
+	MsgVersionConflict  MessageID = "version_conflict"
+	MsgImportMode       MessageID = "import_mode"
+	MsgImportRecord     MessageID = "import_record"
+	MsgRecordTooLarge   MessageID = "record_too_large"
+	MsgQueryBool        MessageID = "query_bool"
+	MsgBodyGzip         MessageID = "body_gzip"
+	MsgBackupNotExists  MessageID = "backup_not_exists"
+	MsgBackupName       MessageID = "backup_name"
+	MsgBackupCorrupt    MessageID = "backup_corrupt"
+	MsgBackupVersion    MessageID = "backup_version"
+	MsgBackupBusy       MessageID = "backup_busy"
+)
+
+// Params are values substituted into {name} placeholders of a message
+type Params map[string]string
+
+// catalog holds message templates, {name} placeholders are filled from Params
+var catalog = map[MessageID]map[Lang]string{
+	MsgValidation:      {English: "Invalid request", Russian: "Некорректный запрос"},
+	MsgTooLarge:        {English: "Payload too large", Russian: "Слишком большой запрос"},
+	MsgUnauthenticated: {English: "Authentication required", Russian: "Требуется аутентификация"},
+	MsgNotFound:        {English: "Not found", Russian: "Не найдено"},
+	MsgAlreadyExists:   {English: "Already exists", Russian: "Уже существует"},
+	MsgConflict:        {English: "Conflict", Russian: "Конфликт"},
+	MsgRateLimited:     {English: "Too many requests", Russian: "Слишком много запросов"},
+	MsgCanceled:        {English: "Request canceled", Russian: "Запрос отменён"},
+	MsgTimeout:         {English: "Request timed out", Russian: "Превышено время ожидания"},
+	MsgUnavailable:     {English: "Storage unavailable", Russian: "Хранилище недоступно"},
+	MsgInternal:        {English: "Internal error", Russian: "Внутренняя ошибка"},
+
+	MsgKeyNotExists:     {English: "key '{key}' does not exist", Russian: "ключ '{key}' не существует"},
+	MsgKeyExists:        {English: "key '{key}' already exists", Russian: "ключ '{key}' уже существует"},
+	MsgKeyEmpty:         {English: "key cannot be empty", Russian: "ключ не может быть пустым"},
+	MsgValueEmpty:       {English: "value cannot be empty", Russian: "значение не может быть пустым"},
+	MsgValueInvalidJSON: {English: "value must be valid JSON", Russian: "значение должно быть корректным JSON"},
+	MsgBodyInvalidJSON:  {English: "request body is not valid JSON", Russian: "тело запроса не является корректным JSON"},
+	MsgLevelInvalid: {
+		English: "level must be one of debug, info, warn, error",
+		Russian: "уровень должен быть одним из debug, info, warn, error",
+	},
+	MsgKeyTooLong: {
+		English: "key must be at most {limit} bytes long",
+		Russian: "ключ не может быть длиннее {limit} байт",
+	},
+	MsgKeyCharset: {
+		English: "key may only contain characters [{charset}]",
+		Russian: "ключ может содержать только символы [{charset}]",
+	},
+	MsgValueTooLarge: {
+		English: "value must be at most {limit} bytes",
+		Russian: "значение не может быть больше {limit} байт",
+	},
+	MsgBodyTooLarge: {
+		English: "request body must be at most {limit} bytes",
+		Russian: "тело запроса не может быть больше {limit} байт",
+	},
+	MsgKeyWhitespace: {
+		English: "key must not start or end with whitespace",
+		Russian: "ключ не может начинаться или заканчиваться пробелом",
+	},
+	MsgKeyControl: {
+		English: "key must not contain control characters",
+		Russian: "ключ не может содержать управляющие символы",
+	},
+	MsgKeyNotURLSafe: {
+		English: "key may only contain characters allowed in a URL path segment and can not be '.' or '..'",
+		Russian: "ключ может содержать только символы, допустимые в сегменте пути URL, и не может быть '.' или '..'",
+	},
+	MsgFieldUnknown:  {English: "unknown field", Russian: "неизвестное поле"},
+	MsgFieldRequired: {English: "field is required", Russian: "обязательное поле"},
+	MsgFieldString:   {English: "must be a string", Russian: "должно быть строкой"},
+	MsgFieldUint32: {
+		English: "must be an integer from 0 to 4294967295",
+		Russian: "должно быть целым числом от 0 до 4294967295",
+	},
+	MsgFieldTime: {
+		English: "must be a time in RFC 3339 format",
+		Russian: "должно быть временем в формате RFC 3339",
+	},
+	MsgBodyNotObject: {
+		English: "request body must contain a single JSON object",
+		Russian: "тело запроса должно содержать один JSON-объект",
+	},
+	MsgInvalidFields: {
+		English: "request has invalid fields",
+		Russian: "запрос содержит некорректные поля",
+	},
+	MsgLimitRange: {
+		English: "limit must be between 1 and {max}",
+		Russian: "лимит должен быть от 1 до {max}",
+	},
+	MsgPageToken: {English: "page token is invalid", Russian: "некорректный токен страницы"},
+	MsgBatchSize: {
+		English: "batch must contain from 1 to {max} operations",
+		Russian: "пакет должен содержать от 1 до {max} операций",
+	},
+	MsgOperationEmpty: {English: "operation is not set", Russian: "операция не задана"},
+	MsgValueNotUTF8:   {English: "value must be UTF-8 text, binary values are not supported", Russian: "значение должно быть текстом в UTF-8, двоичные значения не поддерживаются"},
+	MsgVersionConflict: {
+		English: "key '{key}' was changed by another client",
+		Russian: "ключ '{key}' был изменён другим клиентом",
+	},
+	MsgImportMode: {
+		English: "mode must be fail-on-conflict, overwrite or skip-existing",
+		Russian: "режим должен быть fail-on-conflict, overwrite или skip-existing",
+	},
+	MsgImportRecord: {
+		English: "record on line {line} is invalid",
+		Russian: "некорректная запись в строке {line}",
+	},
+	MsgRecordTooLarge: {
+		English: "record must be at most {limit} bytes",
+		Russian: "запись не может быть больше {limit} байт",
+	},
+	MsgQueryBool: {English: "must be true or false", Russian: "должно быть true или false"},
+	MsgBodyGzip:  {English: "request body is not valid gzip", Russian: "тело запроса не является корректным gzip"},
+	MsgBackupNotExists: {
+		English: "backup '{name}' does not exist",
+		Russian: "резервная копия '{name}' не существует",
+	},
+	MsgBackupName: {English: "backup name is invalid", Russian: "некорректное имя резервной копии"},
+	MsgBackupCorrupt: {
+		English: "backup '{name}' is damaged or incomplete",
+		Russian: "резервная копия '{name}' повреждена или неполна",
+	},
+	MsgBackupVersion: {
+		English: "backup '{name}' has unsupported format version {version}",
+		Russian: "резервная копия '{name}' имеет неподдерживаемую версию формата {version}",
+	},
+	MsgBackupBusy: {
+		English: "another backup or restore is in progress",
+		Russian: "уже выполняется другое резервное копирование или восстановление",
+	},
+}
+
+// Translate renders message id in lang, falling back to the default language
+func Translate(lang Lang, id MessageID, params Params) string {
+	texts, ok := catalog[id]
+	if !ok {
+		return string(id)
+	}
+	text, ok := texts[lang]
+	if !ok {
+		text = texts[Default]
+	}
+	for name, value := range params {
+		text = strings.ReplaceAll(text, "{"+name+"}", value)
+	}
+	return text
+}
+
+// FromAcceptLanguage picks the supported language the client prefers most.
+// Regional variants match their base language (ru-RU is ru).
+func FromAcceptLanguage(header string) Lang {
+	type candidate struct {
+		lang Lang
+		q    float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+
+		base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		switch lang := Lang(base); lang {
+		case English, Russian:
+			candidates = append(candidates, candidate{lang, q})
+		}
+	}
+
+	if len(candidates) == 0 {
+		return Default
+	}
+	// stable sort keeps header order among equal weights
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].lang
+}
@@ -0,0 +1,43 @@
+package i18n
+
+import "testing"
+
+func TestCatalogComplete(t *testing.T) {
+	for id, texts := range catalog {
+		for _, lang := range []Lang{English, Russian} {
+			if texts[lang] == "" {
+				t.Errorf("message %q has no %q translation", id, lang)
+			}
+		}
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	if got := Translate(Russian, MsgKeyNotExists, Params{"key": "a"}); got != "ключ 'a' не существует" {
+		t.Errorf("unexpected ru message %q", got)
+	}
+	if got := Translate(Lang("de"), MsgKeyExists, Params{"key": "a"}); got != "key 'a' already exists" {
+		t.Errorf("unsupported language does not fall back to English: %q", got)
+	}
+}
+
+func TestFromAcceptLanguage(t *testing.T) {
+	tests := map[string]Lang{
+		"":                        English,
+		"ru":                      Russian,
+		"ru-RU,ru;q=0.9,en;q=0.8": Russian,
+		"en-US,en;q=0.9,ru;q=0.8": English,
+		"de-DE,ru;q=0.5,en;q=0.7": English,
+		"fr, ru;q=0.1":            Russian,
+		"ru;q=0, en":              English,
+		"*":                       English,
+		"RU":                      Russian,
+		"ru;q=bad, en;q=0.2":      English,
+	}
+
+	for header, want := range tests {
+		if got := FromAcceptLanguage(header); got != want {
+			t.Errorf("FromAcceptLanguage(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
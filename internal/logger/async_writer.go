@@ -0,0 +1,191 @@
+package logger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OverflowPolicy defines what AsyncWriter does when its queue is full
+type OverflowPolicy int
+
+const (
+	OverflowBlock OverflowPolicy = iota // Wait for free space in the queue
+	OverflowDrop                        // Discard the record and count it
+)
+
+// AsyncOptions configures AsyncWriter
+type AsyncOptions struct {
+	QueueSize     int            // Maximum number of records waiting to be written
+	BufferSize    int            // Size of the write buffer in bytes
+	FlushInterval time.Duration  // How often buffered data is flushed
+	Overflow      OverflowPolicy // Behaviour when the queue is full
+}
+
+// AsyncWriter moves writes of the underlying writer off the caller's
+// goroutine. Records are queued, written through a buffer by a single
+// goroutine and flushed periodically and on Close.
+type AsyncWriter struct {
+	out      io.Writer
+	queue    chan []byte
+	overflow OverflowPolicy
+
+	mu     sync.Mutex // guards buf and out
+	buf    *bufio.Writer
+	closed sync.RWMutex
+	done   chan struct{}
+	stop   bool
+
+	written atomic.Uint64
+	dropped atomic.Uint64
+}
+
+// NewAsyncWriter starts background writing to out
+func NewAsyncWriter(out io.Writer, opts AsyncOptions) *AsyncWriter {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 4096
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 64 * 1024
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+
+	w := &AsyncWriter{
+		out:      out,
+		queue:    make(chan []byte, opts.QueueSize),
+		overflow: opts.Overflow,
+		buf:      bufio.NewWriterSize(out, opts.BufferSize),
+		done:     make(chan struct{}),
+	}
+	go w.run(opts.FlushInterval)
+
+	return w
+}
+
+// Write queues a copy of p. It never reports errors of the underlying writer.
+func (w *AsyncWriter) Write(p []byte) (int, error) {
+	w.closed.RLock()
+	defer w.closed.RUnlock()
+
+	if w.stop {
+		return 0, os.ErrClosed
+	}
+
+	record := make([]byte, len(p))
+	copy(record, p)
+
+	if w.overflow == OverflowDrop {
+		select {
+		case w.queue <- record:
+		default:
+			w.dropped.Add(1)
+		}
+		return len(p), nil
+	}
+
+	w.queue <- record
+	return len(p), nil
+}
+
+// Dropped returns number of records discarded because the queue was full
+func (w *AsyncWriter) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// Written returns number of records passed to the underlying writer
+func (w *AsyncWriter) Written() uint64 {
+	return w.written.Load()
+}
+
+// Flush writes buffered data to the underlying writer. Records still in
+// the queue are not waited for.
+func (w *AsyncWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.buf.Flush()
+}
+
+// Reopen flushes buffered data and reopens the underlying writer if supported
+func (w *AsyncWriter) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if r, ok := w.out.(interface{ Reopen() error }); ok {
+		return r.Reopen()
+	}
+	return nil
+}
+
+// Close writes all queued records, flushes and closes the underlying writer
+func (w *AsyncWriter) Close() error {
+	w.closed.Lock()
+	if w.stop {
+		w.closed.Unlock()
+		return nil
+	}
+	w.stop = true
+	close(w.queue)
+	w.closed.Unlock()
+
+	<-w.done
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	return closeWriter(w.out)
+}
+
+func (w *AsyncWriter) run(flushInterval time.Duration) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case record, ok := <-w.queue:
+			if !ok {
+				return
+			}
+			w.write(record)
+		case <-ticker.C:
+			if err := w.Flush(); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to flush log: %v\n", err)
+			}
+		}
+	}
+}
+
+func (w *AsyncWriter) write(record []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.buf.Write(record); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write log: %v\n", err)
+		return
+	}
+	w.written.Add(1)
+}
+
+// closeWriter closes w unless it is a standard stream
+func closeWriter(w io.Writer) error {
+	if w == os.Stdout || w == os.Stderr {
+		return nil
+	}
+	if c, ok := w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingWriter blocks every write until release is closed
+type blockingWriter struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	release chan struct{}
+}
+
+func (b *blockingWriter) Write(p []byte) (int, error) {
+	<-b.release
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func TestAsyncWriterFlushOnClose(t *testing.T) {
+	out := &blockingWriter{release: make(chan struct{})}
+	close(out.release)
+
+	w := NewAsyncWriter(out, AsyncOptions{QueueSize: 16, FlushInterval: time.Hour})
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(w, "line %d\n", i)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	if lines := bytes.Count(out.buf.Bytes(), []byte("\n")); lines != 100 {
+		t.Errorf("expected 100 lines after close, got %d", lines)
+	}
+	if w.Written() != 100 || w.Dropped() != 0 {
+		t.Errorf("unexpected counters: written %d dropped %d", w.Written(), w.Dropped())
+	}
+	if _, err := w.Write([]byte("late\n")); err == nil {
+		t.Errorf("write after close succeeded")
+	}
+}
+
+func TestAsyncWriterDrop(t *testing.T) {
+	out := &blockingWriter{release: make(chan struct{})}
+
+	// buffer smaller than a record makes every write hit the blocked writer
+	w := NewAsyncWriter(out, AsyncOptions{QueueSize: 2, BufferSize: 1, Overflow: OverflowDrop})
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(w, "line %d\n", i)
+	}
+	if w.Dropped() == 0 {
+		t.Errorf("expected dropped records with full queue")
+	}
+
+	close(out.release)
+	w.Close()
+
+	if w.Written()+w.Dropped() != 50 {
+		t.Errorf("written %d + dropped %d != 50", w.Written(), w.Dropped())
+	}
+}
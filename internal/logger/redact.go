@@ -0,0 +1,143 @@
+package logger
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+const redacted = "[REDACTED]"
+
+// RedactMode defines how stored values appear in logs
+type RedactMode int
+
+const (
+	RedactHide    RedactMode = iota // Replace value with a placeholder
+	RedactHash                      // Log keyed hash of value, equal values give equal hashes
+	RedactPreview                   // Log a truncated preview with sensitive JSON fields removed
+)
+
+// RedactOptions configures Redactor
+type RedactOptions struct {
+	Mode        RedactMode
+	KeyPatterns []string // path.Match patterns of keys whose values are always hidden
+	JSONFields  []string // JSON object fields removed from previews, case-insensitive
+	PreviewLen  int      // Number of characters kept in preview mode
+	HashKey     []byte   // HMAC key for hash mode, random when empty
+}
+
+// Redactor renders stored values for logs according to configured rules
+type Redactor struct {
+	opts   RedactOptions
+	fields map[string]struct{}
+}
+
+// NewRedactor validates patterns and creates Redactor
+func NewRedactor(opts RedactOptions) (*Redactor, error) {
+	for _, p := range opts.KeyPatterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("bad key pattern %q: %w", p, err)
+		}
+	}
+	if opts.PreviewLen <= 0 {
+		opts.PreviewLen = 16
+	}
+	if len(opts.HashKey) == 0 {
+		opts.HashKey = make([]byte, 32)
+		rand.Read(opts.HashKey)
+	}
+
+	fields := make(map[string]struct{}, len(opts.JSONFields))
+	for _, f := range opts.JSONFields {
+		fields[strings.ToLower(f)] = struct{}{}
+	}
+
+	return &Redactor{opts: opts, fields: fields}, nil
+}
+
+// defaultRedactor hides every value
+var defaultRedactor = &Redactor{}
+
+// Redact returns loggable representation of value stored under key
+func (r *Redactor) Redact(key, value string) string {
+	if r.opts.Mode == RedactHide || r.keyMatches(key) {
+		return redacted
+	}
+
+	switch r.opts.Mode {
+	case RedactHash:
+		mac := hmac.New(sha256.New, r.opts.HashKey)
+		mac.Write([]byte(value))
+		return "hmac:" + hex.EncodeToString(mac.Sum(nil))[:16]
+	case RedactPreview:
+		return r.preview(value)
+	}
+	return redacted
+}
+
+func (r *Redactor) keyMatches(key string) bool {
+	for _, p := range r.opts.KeyPatterns {
+		if ok, _ := path.Match(p, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Redactor) preview(value string) string {
+	if len(r.fields) > 0 {
+		var doc interface{}
+		if err := json.Unmarshal([]byte(value), &doc); err == nil {
+			if clean, err := json.Marshal(r.redactJSON(doc)); err == nil {
+				value = string(clean)
+			}
+		}
+	}
+
+	if utf8.RuneCountInString(value) <= r.opts.PreviewLen {
+		return value
+	}
+	runes := []rune(value)
+	return fmt.Sprintf("%s...(%d bytes)", string(runes[:r.opts.PreviewLen]), len(value))
+}
+
+func (r *Redactor) redactJSON(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			if _, ok := r.fields[strings.ToLower(k)]; ok {
+				t[k] = redacted
+				continue
+			}
+			t[k] = r.redactJSON(child)
+		}
+	case []interface{}:
+		for i, child := range t {
+			t[i] = r.redactJSON(child)
+		}
+	}
+	return v
+}
+
+// sensitiveValue is a stored value rendered by the logger's Redactor
+type sensitiveValue struct {
+	key   string
+	value string
+}
+
+// String keeps the value hidden when printed by anything but SimpleLogger
+func (s sensitiveValue) String() string {
+	return redacted
+}
+
+// Sensitive creates a Field holding value stored under key. Loggers never
+// print it as is, SimpleLogger renders it with its Redactor.
+func Sensitive(name, key, value string) Field {
+	return Field{Key: name, Value: sensitiveValue{key: key, value: value}}
+}
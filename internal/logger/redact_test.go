@@ -0,0 +1,60 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSensitiveHiddenByDefault(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriterLogger(&buf)
+
+	l.Info("inserted", Sensitive("value", "db", `{"password":"qwerty"}`))
+	if strings.Contains(buf.String(), "qwerty") || !strings.Contains(buf.String(), redacted) {
+		t.Errorf("value leaked into log: %s", buf.String())
+	}
+
+	if s := fmt.Sprint(Sensitive("value", "db", "qwerty")); strings.Contains(s, "qwerty") {
+		t.Errorf("value leaked through fmt: %s", s)
+	}
+}
+
+func TestRedactorModes(t *testing.T) {
+	hash, _ := NewRedactor(RedactOptions{Mode: RedactHash, HashKey: []byte("k")})
+	if a, b := hash.Redact("x", "secret"), hash.Redact("y", "secret"); a != b || strings.Contains(a, "secret") {
+		t.Errorf("hash mode must be stable and hide value: %s %s", a, b)
+	}
+
+	preview, err := NewRedactor(RedactOptions{
+		Mode:        RedactPreview,
+		PreviewLen:  40,
+		KeyPatterns: []string{"secret-*"},
+		JSONFields:  []string{"Token"},
+	})
+	if err != nil {
+		t.Fatalf("can't create redactor: %v", err)
+	}
+
+	cases := []struct {
+		key, value string
+		contains   string
+		hidden     string
+	}{
+		{"cfg", `{"name":"app","token":"abc"}`, `"name":"app"`, "abc"},
+		{"cfg", `{"nested":[{"TOKEN":"abc"}]}`, "nested", "abc"},
+		{"secret-db", `"plain"`, redacted, "plain"},
+		{"cfg", strings.Repeat("a", 100), "...(100 bytes)", strings.Repeat("a", 41)},
+	}
+	for _, c := range cases {
+		got := preview.Redact(c.key, c.value)
+		if !strings.Contains(got, c.contains) || strings.Contains(got, c.hidden) {
+			t.Errorf("Redact(%q, %q) = %q", c.key, c.value, got)
+		}
+	}
+
+	if _, err := NewRedactor(RedactOptions{KeyPatterns: []string{"["}}); err == nil {
+		t.Errorf("bad pattern accepted")
+	}
+}
@@ -0,0 +1,271 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotateOptions configures RotatingFile
+type RotateOptions struct {
+	Path       string        // Path of the active log file
+	MaxSize    int64         // Rotate when the file would exceed this many bytes, 0 disables
+	Interval   time.Duration // Rotate when crossing an interval boundary (e.g. 24h), 0 disables
+	MaxBackups int           // Number of rotated files to keep, 0 keeps all
+	MaxAge     time.Duration // Remove rotated files older than this, 0 keeps all
+	Compress   bool          // Gzip rotated files
+}
+
+// RotatingFile is an io.WriteCloser appending to a file and rotating it by
+// size and time. Rotated files are named <name>-<timestamp><ext>[.gz] and
+// are compressed and cleaned up in background.
+type RotatingFile struct {
+	opts RotateOptions
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+
+	cleanupMu sync.Mutex
+	wg        sync.WaitGroup
+
+	now func() time.Time
+}
+
+// NewRotatingFile opens or creates the log file described by opts
+func NewRotatingFile(opts RotateOptions) (*RotatingFile, error) {
+	f := &RotatingFile{
+		opts: opts,
+		now:  time.Now,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write makes RotatingFile satisfy io.Writer
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if f.shouldRotate(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate forces rotation of the current file
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.rotate()
+}
+
+// Reopen closes and reopens the file at the configured path. Used after an
+// external tool such as logrotate has moved the file away (on SIGHUP).
+func (f *RotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return fmt.Errorf("failed to close log file: %w", err)
+		}
+		f.file = nil
+	}
+	return f.open()
+}
+
+// Close closes the file and waits for background compression and cleanup
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.mu.Unlock()
+
+	f.wg.Wait()
+	return err
+}
+
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.opts.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(f.opts.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.openedAt = f.now()
+	if f.size > 0 {
+		// continue the period the existing file was written in
+		f.openedAt = info.ModTime()
+	}
+	return nil
+}
+
+func (f *RotatingFile) shouldRotate(next int64) bool {
+	if f.opts.MaxSize > 0 && f.size > 0 && f.size+next > f.opts.MaxSize {
+		return true
+	}
+	if f.opts.Interval > 0 && f.size > 0 {
+		return !f.now().Truncate(f.opts.Interval).Equal(f.openedAt.Truncate(f.opts.Interval))
+	}
+	return false
+}
+
+func (f *RotatingFile) rotate() error {
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return fmt.Errorf("failed to close log file: %w", err)
+		}
+		f.file = nil
+	}
+
+	backup := f.backupName(f.now())
+	if err := os.Rename(f.opts.Path, backup); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rename log file: %w", err)
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.openedAt = f.now()
+
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.cleanupMu.Lock()
+		defer f.cleanupMu.Unlock()
+
+		if f.opts.Compress {
+			if err := compressFile(backup); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to compress log file %s: %v\n", backup, err)
+			}
+		}
+		f.removeOld()
+	}()
+
+	return nil
+}
+
+func (f *RotatingFile) backupName(t time.Time) string {
+	dir, name := filepath.Split(f.opts.Path)
+	ext := filepath.Ext(name)
+	prefix := strings.TrimSuffix(name, ext)
+	return filepath.Join(dir, fmt.Sprintf("%s-%s%s", prefix, t.Format(backupTimeFormat), ext))
+}
+
+type backupFile struct {
+	path string
+	time time.Time
+}
+
+// backups lists rotated files, newest first
+func (f *RotatingFile) backups() []backupFile {
+	dir, name := filepath.Split(f.opts.Path)
+	if dir == "" {
+		dir = "."
+	}
+	ext := filepath.Ext(name)
+	prefix := strings.TrimSuffix(name, ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var result []backupFile
+	for _, e := range entries {
+		stamp, ok := strings.CutPrefix(e.Name(), prefix)
+		if !ok || e.IsDir() {
+			continue
+		}
+		stamp = strings.TrimSuffix(strings.TrimSuffix(stamp, ".gz"), ext)
+		t, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		result = append(result, backupFile{path: filepath.Join(dir, e.Name()), time: t})
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].time.After(result[j].time) })
+	return result
+}
+
+func (f *RotatingFile) removeOld() {
+	cutoff := time.Time{}
+	if f.opts.MaxAge > 0 {
+		cutoff = f.now().Add(-f.opts.MaxAge)
+	}
+
+	for i, b := range f.backups() {
+		tooMany := f.opts.MaxBackups > 0 && i >= f.opts.MaxBackups
+		tooOld := !cutoff.IsZero() && b.time.Before(cutoff)
+		if tooMany || tooOld {
+			os.Remove(b.path)
+		}
+	}
+}
+
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	return os.Remove(path)
+}
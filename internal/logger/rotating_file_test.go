@@ -0,0 +1,112 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFileSize(t *testing.T) {
+	dir := t.TempDir()
+	f, err := NewRotatingFile(RotateOptions{
+		Path:       filepath.Join(dir, "app.log"),
+		MaxSize:    10,
+		MaxBackups: 2,
+	})
+	if err != nil {
+		t.Fatalf("can't open file: %v", err)
+	}
+
+	clock := time.Date(2025, 3, 20, 12, 0, 0, 0, time.Local)
+	f.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := f.Write([]byte("123456789\n")); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "app-*.log"))
+	if len(backups) != 2 {
+		t.Errorf("expected 2 backups to be kept, got %v", backups)
+	}
+}
+
+func TestRotatingFileInterval(t *testing.T) {
+	dir := t.TempDir()
+	f, err := NewRotatingFile(RotateOptions{
+		Path:     filepath.Join(dir, "app.log"),
+		Interval: time.Hour,
+		Compress: true,
+	})
+	if err != nil {
+		t.Fatalf("can't open file: %v", err)
+	}
+
+	clock := time.Date(2025, 3, 20, 12, 30, 0, 0, time.UTC)
+	f.now = func() time.Time { return clock }
+	f.openedAt = clock
+
+	f.Write([]byte("first\n"))
+	clock = clock.Add(time.Hour)
+	f.Write([]byte("second\n"))
+	f.Close()
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "app-*.log.gz"))
+	if len(backups) != 1 {
+		t.Fatalf("expected 1 compressed backup, got %v", backups)
+	}
+
+	gzFile, err := os.Open(backups[0])
+	if err != nil {
+		t.Fatalf("can't open backup: %v", err)
+	}
+	defer gzFile.Close()
+	gz, err := gzip.NewReader(gzFile)
+	if err != nil {
+		t.Fatalf("backup is not gzip: %v", err)
+	}
+	content, _ := io.ReadAll(gz)
+	if string(content) != "first\n" {
+		t.Errorf("unexpected backup content %q", content)
+	}
+
+	active, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if !strings.Contains(string(active), "second") || strings.Contains(string(active), "first") {
+		t.Errorf("unexpected active file content %q", active)
+	}
+}
+
+func TestRotatingFileReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f, err := NewRotatingFile(RotateOptions{Path: path})
+	if err != nil {
+		t.Fatalf("can't open file: %v", err)
+	}
+	defer f.Close()
+
+	f.Write([]byte("before\n"))
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatalf("rename failed: %v", err)
+	}
+	if err := f.Reopen(); err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	f.Write([]byte("after\n"))
+
+	content, _ := os.ReadFile(path)
+	if string(content) != "after\n" {
+		t.Errorf("unexpected content after reopen %q", content)
+	}
+}
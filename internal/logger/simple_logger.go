@@ -1,55 +1,123 @@
 package logger
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
-	"sync"
-	"time"
+	"sync/atomic"
 )
 
+// SimpleLogger writes JSON lines with timestamp, level, message and fields
 type SimpleLogger struct {
-	file   *os.File
-	mu     sync.Mutex
-	prefix string
+	slogLogger
+	out   io.Writer
+	level *slog.LevelVar
 }
 
+// NewSimpleLogger creates logger appending to file at filePath
 func NewSimpleLogger(filePath string) (*SimpleLogger, error) {
 	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open log file: %w", err)
 	}
 
+	return NewWriterLogger(file), nil
+}
+
+// NewWriterLogger creates logger writing to w, e.g. os.Stdout
+func NewWriterLogger(w io.Writer) *SimpleLogger {
+	level := &slog.LevelVar{}
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+
+	redactor := &atomic.Pointer[Redactor]{}
+	redactor.Store(defaultRedactor)
+
 	return &SimpleLogger{
-		file:   file,
-		prefix: "[%s][%s] - %s\n", // [LEVEL][DATE] - MESSAGE
-	}, nil
+		slogLogger: slogLogger{l: slog.New(handler), redactor: redactor},
+		out:        w,
+		level:      level,
+	}
 }
 
-func (sl *SimpleLogger) Close() error {
-	if sl.file != nil {
-		return sl.file.Close()
+// SetRedactor changes how Sensitive fields are rendered, by default values are hidden
+func (sl *SimpleLogger) SetRedactor(r *Redactor) {
+	sl.redactor.Store(r)
+}
+
+// SetLevel changes minimal level of written records at runtime
+func (sl *SimpleLogger) SetLevel(level Level) {
+	sl.level.Set(slog.Level(level))
+}
+
+// Level returns minimal level of written records
+func (sl *SimpleLogger) Level() Level {
+	return Level(sl.level.Level())
+}
+
+// Reopen reopens the underlying file if it supports it, e.g. on SIGHUP
+func (sl *SimpleLogger) Reopen() error {
+	if r, ok := sl.out.(interface{ Reopen() error }); ok {
+		return r.Reopen()
 	}
 	return nil
 }
 
-func (sl *SimpleLogger) log(level, message string) {
-	sl.mu.Lock()
-	defer sl.mu.Unlock()
+// Close flushes pending records and closes the underlying writer
+func (sl *SimpleLogger) Close() error {
+	return closeWriter(sl.out)
+}
 
-	now := time.Now().Format("2006-01-02")
-	logLine := fmt.Sprintf(sl.prefix, level, now, message)
+// slogLogger adapts slog.Logger to Logger interface
+type slogLogger struct {
+	l        *slog.Logger
+	redactor *atomic.Pointer[Redactor] // shared with loggers created by With
+}
 
-	if _, err := sl.file.WriteString(logLine); err != nil {
-		fmt.Printf("failed to write log: %v\n", err)
+func (s slogLogger) log(level slog.Level, message string, fields []Field) {
+	ctx := context.Background()
+	if !s.l.Enabled(ctx, level) {
 		return
 	}
-	fmt.Println(logLine)
+	s.l.LogAttrs(ctx, level, message, s.attrs(fields)...)
+}
+
+func (s slogLogger) Debug(message string, fields ...Field) {
+	s.log(slog.LevelDebug, message, fields)
+}
+
+func (s slogLogger) Info(message string, fields ...Field) {
+	s.log(slog.LevelInfo, message, fields)
 }
 
-func (sl *SimpleLogger) Error(message string) {
-	sl.log("ERR", message)
+func (s slogLogger) Warn(message string, fields ...Field) {
+	s.log(slog.LevelWarn, message, fields)
 }
 
-func (sl *SimpleLogger) Info(message string) {
-	sl.log("INFO", message)
+func (s slogLogger) Error(message string, fields ...Field) {
+	s.log(slog.LevelError, message, fields)
+}
+
+func (s slogLogger) With(fields ...Field) Logger {
+	args := make([]any, 0, len(fields))
+	for _, a := range s.attrs(fields) {
+		args = append(args, a)
+	}
+	return slogLogger{l: s.l.With(args...), redactor: s.redactor}
+}
+
+func (s slogLogger) attrs(fields []Field) []slog.Attr {
+	result := make([]slog.Attr, 0, len(fields))
+	for _, f := range fields {
+		switch v := f.Value.(type) {
+		case sensitiveValue:
+			result = append(result, slog.String(f.Key, s.redactor.Load().Redact(v.key, v.value)))
+		case error:
+			result = append(result, slog.String(f.Key, v.Error()))
+		default:
+			result = append(result, slog.Any(f.Key, v))
+		}
+	}
+	return result
 }
@@ -0,0 +1,60 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSimpleLoggerJSON(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriterLogger(&buf)
+
+	ctx := ContextWithRequestID(context.Background(), "req-1")
+	FromContext(ctx, l).Info("hello", F("key", "vova"))
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("log line is not json: %v (%s)", err, buf.String())
+	}
+
+	expected := map[string]interface{}{
+		"level":      "INFO",
+		"msg":        "hello",
+		"key":        "vova",
+		"request_id": "req-1",
+	}
+	for k, v := range expected {
+		if record[k] != v {
+			t.Errorf("field %s: expected %v got %v", k, v, record[k])
+		}
+	}
+	if _, ok := record["time"]; !ok {
+		t.Errorf("record has no timestamp")
+	}
+}
+
+func TestSimpleLoggerLevel(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriterLogger(&buf)
+
+	l.Debug("hidden")
+	if buf.Len() != 0 {
+		t.Errorf("debug record written at info level: %s", buf.String())
+	}
+
+	l.SetLevel(LevelDebug)
+	l.Debug("visible")
+	if !strings.Contains(buf.String(), "visible") {
+		t.Errorf("debug record not written after SetLevel")
+	}
+
+	l.SetLevel(LevelError)
+	buf.Reset()
+	l.Warn("hidden")
+	if buf.Len() != 0 {
+		t.Errorf("warn record written at error level: %s", buf.String())
+	}
+}
@@ -0,0 +1,257 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "vault"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	grpcRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_requests_total",
+		Help:      "Number of gRPC calls by method and status code.",
+	}, []string{"method", "code"})
+
+	grpcDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_request_duration_seconds",
+		Help:      "gRPC call latency by method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "code"})
+
+	respCommands = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "resp_commands_total",
+		Help:      "Number of Redis protocol commands by command and result.",
+	}, []string{"command", "result"})
+
+	respDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "resp_command_duration_seconds",
+		Help:      "Redis protocol command latency by command.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"command"})
+
+	memcacheCommands = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "memcache_commands_total",
+		Help:      "Number of memcached protocol commands by command and result.",
+	}, []string{"command", "result"})
+
+	memcacheDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "memcache_command_duration_seconds",
+		Help:      "Memcached protocol command latency by command.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"command"})
+
+	tarantoolDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "tarantool_request_duration_seconds",
+		Help:      "Tarantool call latency by operation.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation"})
+
+	tarantoolErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tarantool_request_errors_total",
+		Help:      "Number of failed Tarantool calls by operation.",
+	}, []string{"operation"})
+)
+
+// ObserveHTTP records a finished HTTP request
+func ObserveHTTP(route, method string, status int, duration time.Duration) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(route, method, code).Inc()
+	httpDuration.WithLabelValues(route, method, code).Observe(duration.Seconds())
+}
+
+// ObserveGRPC records a finished gRPC call, streams are observed when they end
+func ObserveGRPC(method, code string, duration time.Duration) {
+	grpcRequests.WithLabelValues(method, code).Inc()
+	grpcDuration.WithLabelValues(method, code).Observe(duration.Seconds())
+}
+
+// ObserveRESP records a finished Redis protocol command, result is "ok" or
+// the error code
+func ObserveRESP(command, result string, duration time.Duration) {
+	respCommands.WithLabelValues(command, result).Inc()
+	respDuration.WithLabelValues(command).Observe(duration.Seconds())
+}
+
+// ObserveMemcache records a finished memcached protocol command, result is
+// "ok" or the error code
+func ObserveMemcache(command, result string, duration time.Duration) {
+	memcacheCommands.WithLabelValues(command, result).Inc()
+	memcacheDuration.WithLabelValues(command).Observe(duration.Seconds())
+}
+
+// ObserveTarantool records a finished Tarantool call started at start
+func ObserveTarantool(operation string, start time.Time, err error) {
+	tarantoolDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		tarantoolErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// StorageStats is implemented by repositories able to report their state
+type StorageStats interface {
+	Connected() bool
+	Count(ctx context.Context) (uint64, error)
+}
+
+// storageCollector queries storage state on every scrape
+type storageCollector struct {
+	stats     StorageStats
+	timeout   time.Duration
+	connected *prometheus.Desc
+	keys      *prometheus.Desc
+}
+
+// RegisterStorage exposes connection state and key count of the storage
+func RegisterStorage(stats StorageStats, timeout time.Duration) error {
+	return prometheus.Register(&storageCollector{
+		stats:   stats,
+		timeout: timeout,
+		connected: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "tarantool", "connected"),
+			"Whether the Tarantool connection is established (1) or not (0).",
+			nil, nil),
+		keys: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "keys"),
+			"Number of keys stored in the vault.",
+			nil, nil),
+	})
+}
+
+func (c *storageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.connected
+	ch <- c.keys
+}
+
+func (c *storageCollector) Collect(ch chan<- prometheus.Metric) {
+	connected := 0.0
+	if c.stats.Connected() {
+		connected = 1
+	}
+	ch <- prometheus.MustNewConstMetric(c.connected, prometheus.GaugeValue, connected)
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	count, err := c.stats.Count(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.keys, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.keys, prometheus.GaugeValue, float64(count))
+}
+
+// LogWriterStats is implemented by asynchronous log writers
+type LogWriterStats interface {
+	Written() uint64
+	Dropped() uint64
+}
+
+// RegisterLogWriter exposes counters of written and dropped log records
+func RegisterLogWriter(stats LogWriterStats) error {
+	written := prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "log_records_written_total",
+		Help:      "Number of log records written by the asynchronous writer.",
+	}, func() float64 { return float64(stats.Written()) })
+
+	dropped := prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "log_records_dropped_total",
+		Help:      "Number of log records dropped because the queue was full.",
+	}, func() float64 { return float64(stats.Dropped()) })
+
+	if err := prometheus.Register(written); err != nil {
+		return err
+	}
+	return prometheus.Register(dropped)
+}
+
+// CacheCounts is a snapshot of cache statistics
+type CacheCounts struct {
+	Hits         uint64 // Lookups served with a cached value
+	NegativeHits uint64 // Lookups served with a cached absence of the key
+	Misses       uint64 // Lookups passed to the storage
+	Evictions    uint64 // Entries removed to stay within the size bound
+	Entries      int
+	Bytes        int64
+}
+
+// CacheStats is implemented by caches able to report their statistics
+type CacheStats interface {
+	CacheCounts() CacheCounts
+}
+
+// cacheCollector reads cache statistics on every scrape
+type cacheCollector struct {
+	stats     CacheStats
+	lookups   *prometheus.Desc
+	evictions *prometheus.Desc
+	entries   *prometheus.Desc
+	bytes     *prometheus.Desc
+}
+
+// RegisterCache exposes hit/miss counters and size of the cache
+func RegisterCache(stats CacheStats) error {
+	return prometheus.Register(&cacheCollector{
+		stats: stats,
+		lookups: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "cache", "lookups_total"),
+			"Number of cache lookups by result: hit, negative_hit or miss.",
+			[]string{"result"}, nil),
+		evictions: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "cache", "evictions_total"),
+			"Number of entries evicted to stay within the size bound.",
+			nil, nil),
+		entries: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "cache", "entries"),
+			"Number of cached entries.",
+			nil, nil),
+		bytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "cache", "bytes"),
+			"Estimated size of cached entries in bytes.",
+			nil, nil),
+	})
+}
+
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.lookups
+	ch <- c.evictions
+	ch <- c.entries
+	ch <- c.bytes
+}
+
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stats.CacheCounts()
+	ch <- prometheus.MustNewConstMetric(c.lookups, prometheus.CounterValue, float64(s.Hits), "hit")
+	ch <- prometheus.MustNewConstMetric(c.lookups, prometheus.CounterValue, float64(s.NegativeHits), "negative_hit")
+	ch <- prometheus.MustNewConstMetric(c.lookups, prometheus.CounterValue, float64(s.Misses), "miss")
+	ch <- prometheus.MustNewConstMetric(c.evictions, prometheus.CounterValue, float64(s.Evictions))
+	ch <- prometheus.MustNewConstMetric(c.entries, prometheus.GaugeValue, float64(s.Entries))
+	ch <- prometheus.MustNewConstMetric(c.bytes, prometheus.GaugeValue, float64(s.Bytes))
+}
@@ -0,0 +1,239 @@
+// Package ratelimit keeps request budgets and concurrency limits shared by
+// every front end, so a client is accounted the same whether it speaks HTTP,
+// gRPC, the Redis or the memcached protocol
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/vvjke314/vk-test-03-2025/config"
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// sweepInterval is how often idle client buckets are dropped
+	sweepInterval = time.Minute
+	// maxBuckets bounds the number of clients tracked by a budget, clients
+	// beyond it share one bucket until idle ones are swept
+	maxBuckets = 100_000
+	// overflowClient is the bucket shared by clients beyond maxBuckets, it
+	// can not collide with keys made by IPKey and PrincipalKey
+	overflowClient = "overflow"
+)
+
+// Op is the kind of operation a request is accounted as, it selects the
+// budget like the matching /kv route does
+type Op int
+
+const (
+	None   Op = iota // Not limited, e.g. PING
+	Get              // Reads
+	Create           // Writes of new keys
+	Update           // Writes of existing or any keys
+	Delete           // Deletes
+)
+
+// IPKey identifies a client by its address
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// AddrKey identifies a client by the host of addr
+func AddrKey(addr net.Addr) string {
+	if addr == nil {
+		return IPKey("")
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return IPKey(addr.String())
+	}
+	return IPKey(host)
+}
+
+// PrincipalKey identifies a client by a verified credential
+func PrincipalKey(principal string) string {
+	return "principal:" + principal
+}
+
+// Decision is the outcome of taking a token from a budget
+type Decision struct {
+	Limited    bool          // A budget applies to the operation
+	Allowed    bool          // The token was taken
+	Limit      int           // Size of the budget
+	Remaining  int           // Tokens left
+	Reset      time.Duration // Time until the budget is full again
+	RetryAfter time.Duration // Time until a token is available, when denied
+}
+
+// Limits holds the budgets and the concurrency limits of one process.
+// A nil Limits limits nothing.
+type Limits struct {
+	read   *bucketLimiter
+	write  *bucketLimiter
+	own    map[Op]*bucketLimiter // Operations with their own budget
+	reads  chan struct{}
+	writes chan struct{}
+}
+
+// New builds limits of cfg. Operations without their own limit share the
+// read or write budget of a client.
+func New(cfg config.RateLimits) *Limits {
+	l := &Limits{own: make(map[Op]*bucketLimiter)}
+	if cfg.Read.Enabled() {
+		l.read = newBucketLimiter(cfg.Read)
+	}
+	if cfg.Write.Enabled() {
+		l.write = newBucketLimiter(cfg.Write)
+	}
+	for op, override := range map[Op]config.RateLimit{Get: cfg.Get, Create: cfg.Create, Update: cfg.Update, Delete: cfg.Delete} {
+		if override.Enabled() {
+			l.own[op] = newBucketLimiter(override)
+		}
+	}
+	if cfg.MaxConcurrentReads > 0 {
+		l.reads = make(chan struct{}, cfg.MaxConcurrentReads)
+	}
+	if cfg.MaxConcurrentWrites > 0 {
+		l.writes = make(chan struct{}, cfg.MaxConcurrentWrites)
+	}
+	return l
+}
+
+// Allow takes a token of op's budget of client
+func (l *Limits) Allow(client string, op Op) Decision {
+	if l == nil || op == None {
+		return Decision{Allowed: true}
+	}
+	limiter := l.read
+	if op != Get {
+		limiter = l.write
+	}
+	if own, ok := l.own[op]; ok {
+		limiter = own
+	}
+	if limiter == nil {
+		return Decision{Allowed: true}
+	}
+	return limiter.allow(client)
+}
+
+// Acquire takes a slot of requests in flight across all clients, it
+// reports false when every slot is busy. release frees a taken slot.
+func (l *Limits) Acquire(op Op) (release func(), ok bool) {
+	if l == nil || op == None {
+		return func() {}, true
+	}
+	slots := l.reads
+	if op != Get {
+		slots = l.writes
+	}
+	if slots == nil {
+		return func() {}, true
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, true
+	default:
+		return nil, false
+	}
+}
+
+// Take applies both the budget and the concurrency limit of op, for front
+// ends that do not report the budget to clients. release must be called
+// once the operation is done.
+func (l *Limits) Take(client string, op Op) (release func(), err error) {
+	if d := l.Allow(client, op); !d.Allowed {
+		return nil, custom_errors.ErrRateLimited
+	}
+	release, ok := l.Acquire(op)
+	if !ok {
+		return nil, custom_errors.ErrRateLimited
+	}
+	return release, nil
+}
+
+// bucketLimiter keeps a token bucket per client
+type bucketLimiter struct {
+	limit rate.Limit
+	burst int
+	idle  time.Duration // time to refill an empty bucket, idle buckets are equal to new ones
+	max   int           // clients tracked before they share the overflow bucket
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+
+	now func() time.Time
+}
+
+type bucket struct {
+	limiter *rate.Limiter
+	seen    time.Time
+}
+
+func newBucketLimiter(cfg config.RateLimit) *bucketLimiter {
+	return &bucketLimiter{
+		limit:   rate.Limit(cfg.Rate),
+		burst:   cfg.Burst,
+		idle:    time.Duration(float64(cfg.Burst) / cfg.Rate * float64(time.Second)),
+		max:     maxBuckets,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// allow takes a token from client's bucket
+func (b *bucketLimiter) allow(client string) Decision {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if now.Sub(b.swept) >= sweepInterval {
+		b.sweep(now)
+	}
+
+	bk, found := b.buckets[client]
+	if !found {
+		// a full map is swept early, but not on every new client
+		if len(b.buckets) >= b.max && now.Sub(b.swept) >= b.idle {
+			b.sweep(now)
+		}
+		if len(b.buckets) >= b.max {
+			client = overflowClient
+			bk, found = b.buckets[client]
+		}
+	}
+	if !found {
+		bk = &bucket{limiter: rate.NewLimiter(b.limit, b.burst)}
+		b.buckets[client] = bk
+	}
+	bk.seen = now
+
+	d := Decision{Limited: true, Limit: b.burst}
+	r := bk.limiter.ReserveN(now, 1)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		d.RetryAfter = delay
+	} else {
+		d.Allowed = true
+	}
+
+	tokens := bk.limiter.TokensAt(now)
+	d.Remaining = int(math.Max(0, math.Floor(tokens)))
+	d.Reset = time.Duration((float64(b.burst) - tokens) / float64(b.limit) * float64(time.Second))
+	return d
+}
+
+// sweep drops buckets idle long enough to be full again
+func (b *bucketLimiter) sweep(now time.Time) {
+	b.swept = now
+	for client, bk := range b.buckets {
+		if now.Sub(bk.seen) > b.idle {
+			delete(b.buckets, client)
+		}
+	}
+}
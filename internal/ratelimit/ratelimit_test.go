@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/vvjke314/vk-test-03-2025/config"
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+)
+
+func TestBucketLimiter(t *testing.T) {
+	b := newBucketLimiter(config.RateLimit{Rate: 1, Burst: 2})
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	for i, wantRemaining := range []int{1, 0} {
+		if d := b.allow("a"); !d.Allowed || d.Remaining != wantRemaining {
+			t.Fatalf("request %d: %+v", i, d)
+		}
+	}
+
+	if d := b.allow("a"); d.Allowed || d.RetryAfter != time.Second || d.Reset != 2*time.Second {
+		t.Errorf("expected denial, got %+v", d)
+	}
+	if d := b.allow("b"); !d.Allowed {
+		t.Errorf("clients share a bucket")
+	}
+
+	now = now.Add(time.Second)
+	if d := b.allow("a"); !d.Allowed {
+		t.Errorf("bucket not refilled")
+	}
+
+	now = now.Add(sweepInterval)
+	b.allow("c")
+	if _, found := b.buckets["a"]; found {
+		t.Errorf("idle bucket not swept")
+	}
+}
+
+func TestBucketLimiterCap(t *testing.T) {
+	b := newBucketLimiter(config.RateLimit{Rate: 1, Burst: 1})
+	b.max = 2
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	b.allow("a")
+	b.allow("b")
+	// clients beyond the cap share one bucket
+	if d := b.allow("c"); !d.Allowed {
+		t.Errorf("first overflow client denied")
+	}
+	if d := b.allow("d"); d.Allowed {
+		t.Errorf("overflow clients got separate buckets")
+	}
+	if len(b.buckets) != 2+1 {
+		t.Errorf("tracking %d buckets", len(b.buckets))
+	}
+
+	// idle buckets are swept to make room at once
+	now = now.Add(2 * time.Second)
+	if d := b.allow("e"); !d.Allowed {
+		t.Errorf("new client denied after idle buckets expired")
+	}
+	if _, found := b.buckets["e"]; !found {
+		t.Errorf("new client not tracked after sweep")
+	}
+}
+
+func TestLimits(t *testing.T) {
+	l := New(config.RateLimits{
+		Read:               config.RateLimit{Rate: 1, Burst: 1},
+		Delete:             config.RateLimit{Rate: 1, Burst: 1},
+		MaxConcurrentReads: 1,
+	})
+	client := AddrKey(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5000})
+	if client != "ip:127.0.0.1" {
+		t.Fatalf("client key %q", client)
+	}
+
+	if d := l.Allow(client, Get); !d.Allowed || !d.Limited {
+		t.Errorf("first read: %+v", d)
+	}
+	if d := l.Allow(client, Get); d.Allowed {
+		t.Errorf("second read allowed")
+	}
+	// writes have no budget, deletes have their own
+	if d := l.Allow(client, Update); !d.Allowed || d.Limited {
+		t.Errorf("update: %+v", d)
+	}
+	if d := l.Allow(client, Delete); !d.Allowed {
+		t.Errorf("delete shares the read budget")
+	}
+	if d := l.Allow(client, None); !d.Allowed {
+		t.Errorf("unlimited operation denied")
+	}
+
+	release, err := l.Take("other", Get)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Take("third", Get); custom_errors.CodeOf(err) != custom_errors.CodeRateLimited {
+		t.Errorf("read beyond concurrency limit: %v", err)
+	}
+	release()
+	if _, ok := l.Acquire(Get); !ok {
+		t.Errorf("slot not released")
+	}
+
+	var none *Limits
+	if _, err := none.Take(client, Delete); err != nil {
+		t.Errorf("nil limits: %v", err)
+	}
+}
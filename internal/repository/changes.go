@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/tarantool/go-tarantool/v2"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+)
+
+// changesKey is the box.broadcast key updated by the vault on_replace trigger
+const changesKey = "vault.changes"
+
+// WatchChanges subscribes to changes committed by any client. onKey is
+// called with a single changed key; onReset is called when events may have
+// been missed (first event, reconnect, instance restart) and every key must
+// be considered stale. Watchers follow the master when a pool is used.
+// Call the returned stop function to unsubscribe.
+func (trepo *TnRepository) WatchChanges(onKey func(key string), onReset func()) (func(), error) {
+	var (
+		mu      sync.Mutex
+		lastSeq uint64
+		synced  bool
+	)
+
+	watcher, err := trepo.exec.NewWatcher(changesKey, func(event tarantool.WatchEvent) {
+		seq, key, ok := parseChange(event.Value)
+
+		mu.Lock()
+		inOrder := ok && synced && seq == lastSeq+1
+		lastSeq, synced = seq, ok
+		mu.Unlock()
+
+		if inOrder {
+			onKey(key)
+			return
+		}
+		trepo.logger.Debug("change stream out of sync, resetting", logger.F("seq", seq))
+		onReset()
+	}, writeMode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch %s: %w", changesKey, err)
+	}
+
+	return watcher.Unregister, nil
+}
+
+// parseChange decodes {seq = N, key = K} broadcast by the trigger
+func parseChange(value interface{}) (seq uint64, key string, ok bool) {
+	m, isMap := value.(map[interface{}]interface{})
+	if !isMap {
+		return 0, "", false
+	}
+	key, isString := m["key"].(string)
+	if !isString {
+		return 0, "", false
+	}
+
+	switch v := m["seq"].(type) {
+	case int8:
+		seq = uint64(v)
+	case int16:
+		seq = uint64(v)
+	case int32:
+		seq = uint64(v)
+	case int64:
+		seq = uint64(v)
+	case uint8:
+		seq = uint64(v)
+	case uint16:
+		seq = uint64(v)
+	case uint32:
+		seq = uint64(v)
+	case uint64:
+		seq = v
+	default:
+		return 0, "", false
+	}
+	return seq, key, true
+}
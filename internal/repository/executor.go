@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/tarantool/go-tarantool/v2"
+	"github.com/tarantool/go-tarantool/v2/pool"
+)
+
+// Request routing: reads go to replicas when there are any, writes always
+// go to the master.
+const (
+	readMode  = pool.PreferRO
+	writeMode = pool.RW
+	anyMode   = pool.ANY
+)
+
+// executor sends requests to Tarantool. It is implemented by
+// *pool.ConnectionPool for several instances and by singleConn for one.
+type executor interface {
+	Do(req tarantool.Request, mode pool.Mode) *tarantool.Future
+	ConnectedNow(mode pool.Mode) (bool, error)
+	NewWatcher(key string, callback tarantool.WatchCallback, mode pool.Mode) (tarantool.Watcher, error)
+	NewStream(mode pool.Mode) (*tarantool.Stream, error)
+	CloseGraceful() []error
+}
+
+// singleConn adapts one connection to executor, every mode routes to it
+type singleConn struct {
+	conn *tarantool.Connection
+}
+
+func (s singleConn) Do(req tarantool.Request, _ pool.Mode) *tarantool.Future {
+	return s.conn.Do(req)
+}
+
+func (s singleConn) ConnectedNow(_ pool.Mode) (bool, error) {
+	return s.conn.ConnectedNow(), nil
+}
+
+func (s singleConn) NewWatcher(key string, callback tarantool.WatchCallback, _ pool.Mode) (tarantool.Watcher, error) {
+	return s.conn.NewWatcher(key, callback)
+}
+
+func (s singleConn) NewStream(_ pool.Mode) (*tarantool.Stream, error) {
+	return s.conn.NewStream()
+}
+
+func (s singleConn) CloseGraceful() []error {
+	if err := s.conn.CloseGraceful(); err != nil {
+		return []error{err}
+	}
+	return nil
+}
+
+// isPoolUnavailable reports pool errors meaning no suitable instance is up
+func isPoolUnavailable(err error) bool {
+	return errors.Is(err, pool.ErrNoRwInstance) ||
+		errors.Is(err, pool.ErrNoRoInstance) ||
+		errors.Is(err, pool.ErrNoHealthyInstance) ||
+		errors.Is(err, pool.ErrClosed)
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"github.com/tarantool/go-tarantool/v2"
+	"github.com/tarantool/go-tarantool/v2/pool"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+)
+
+// poolHandler logs instances joining and leaving the pool. The pool only
+// routes requests to instances it has discovered as running.
+type poolHandler struct {
+	logger logger.Logger
+}
+
+// Discovered is called when an instance becomes usable in role
+func (h poolHandler) Discovered(name string, _ *tarantool.Connection, role pool.Role) error {
+	h.logger.Info("tarantool instance available", logger.F("instance", name), logger.F("role", role.String()))
+	return nil
+}
+
+// Deactivated is called when an instance stops being usable in role
+func (h poolHandler) Deactivated(name string, _ *tarantool.Connection, role pool.Role) error {
+	h.logger.Warn("tarantool instance unavailable", logger.F("instance", name), logger.F("role", role.String()))
+	return nil
+}
@@ -0,0 +1,248 @@
+// Package repotest provides an in-memory repository for tests of the layers
+// above the storage
+package repotest
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"github.com/vvjke314/vk-test-03-2025/internal/entities"
+)
+
+// DumpPage is the number of records Dump passes at once
+const DumpPage = 1000
+
+// Repo is an in-memory repository with the error contract of the Tarantool
+// one. Versions come from one counter like the vault_version sequence,
+// Import applies a chunk in order as one transaction. Expired keys are
+// missing at once.
+type Repo struct {
+	// OnGet, when set, runs before Get and GetVersioned read, an error it
+	// returns is returned instead. Set it before the repository is shared.
+	OnGet func(ctx context.Context, key string) error
+
+	mu       sync.Mutex
+	items    map[string]entities.VaultItem
+	versions map[string]uint64
+	last     uint64
+}
+
+// New returns a repository holding items, none of them expires
+func New(items map[string]string) *Repo {
+	r := &Repo{items: map[string]entities.VaultItem{}, versions: map[string]uint64{}}
+	for key, value := range items {
+		r.put(entities.VaultItem{Key: key, Value: value})
+	}
+	return r
+}
+
+// Set stores value of key without any checks, like a write of another client
+func (r *Repo) Set(key, value string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.put(entities.VaultItem{Key: key, Value: value})
+}
+
+// Remove drops key if it exists, like a delete of another client
+func (r *Repo) Remove(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.items, key)
+	delete(r.versions, key)
+}
+
+// Value returns the stored value of key
+func (r *Repo) Value(key string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	item, ok := r.live(key)
+	return item.Value, ok
+}
+
+// Len returns the number of stored keys
+func (r *Repo) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.list("", "", len(r.items)))
+}
+
+// put stores item and bumps the version, r.mu must be held
+func (r *Repo) put(item entities.VaultItem) {
+	r.last++
+	r.items[item.Key], r.versions[item.Key] = item, r.last
+}
+
+// live returns the item of key unless it is missing or expired, r.mu must
+// be held
+func (r *Repo) live(key string) (entities.VaultItem, bool) {
+	item, ok := r.items[key]
+	if !ok || item.Expired(time.Now()) {
+		return entities.VaultItem{}, false
+	}
+	return item, true
+}
+
+func (r *Repo) Insert(_ context.Context, item entities.VaultItem) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.live(item.Key); ok {
+		return custom_errors.NewKeyExistsError(item.Key)
+	}
+	r.put(item)
+	return nil
+}
+
+func (r *Repo) Update(_ context.Context, item entities.VaultItem) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.live(item.Key); !ok {
+		return custom_errors.NewKeyNotExistsError(item.Key)
+	}
+	r.put(item)
+	return nil
+}
+
+func (r *Repo) Expire(_ context.Context, key string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	item, ok := r.live(key)
+	if !ok {
+		return custom_errors.NewKeyNotExistsError(key)
+	}
+	item.ExpiresAt = at
+	r.put(item)
+	return nil
+}
+
+func (r *Repo) Delete(_ context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.live(key)
+	delete(r.items, key)
+	delete(r.versions, key)
+	if !ok {
+		return custom_errors.NewKeyNotExistsError(key)
+	}
+	return nil
+}
+
+func (r *Repo) Get(ctx context.Context, key string) (entities.VaultItem, error) {
+	item, _, err := r.GetVersioned(ctx, key)
+	return item, err
+}
+
+func (r *Repo) GetVersioned(ctx context.Context, key string) (entities.VaultItem, uint64, error) {
+	if r.OnGet != nil {
+		if err := r.OnGet(ctx, key); err != nil {
+			return entities.VaultItem{}, 0, err
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	item, ok := r.live(key)
+	if !ok {
+		return entities.VaultItem{}, 0, custom_errors.NewKeyNotExistsError(key)
+	}
+	return item, r.versions[key], nil
+}
+
+func (r *Repo) CompareAndSwap(_ context.Context, item entities.VaultItem, version uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.live(item.Key); !ok {
+		return custom_errors.NewKeyNotExistsError(item.Key)
+	}
+	if r.versions[item.Key] != version {
+		return custom_errors.NewVersionConflictError(item.Key)
+	}
+	r.put(item)
+	return nil
+}
+
+func (r *Repo) List(_ context.Context, prefix, after string, limit int) ([]entities.VaultItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.list(prefix, after, limit), nil
+}
+
+// list returns live items in key order, r.mu must be held
+func (r *Repo) list(prefix, after string, limit int) []entities.VaultItem {
+	var items []entities.VaultItem
+	now := time.Now()
+	for key, item := range r.items {
+		if strings.HasPrefix(key, prefix) && key > after && !item.Expired(now) {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
+
+// Import applies items in order, so a key repeated in the chunk exists for
+// its later rows in a dry run too. A conflict in fail-on-conflict mode
+// writes nothing.
+func (r *Repo) Import(_ context.Context, items []entities.VaultItem, mode entities.ImportMode, dryRun bool) (entities.ImportResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var result entities.ImportResult
+	written := map[string]entities.VaultItem{}
+	for _, item := range items {
+		_, exists := r.live(item.Key)
+		if _, ok := written[item.Key]; ok {
+			exists = true
+		}
+		switch {
+		case !exists:
+			result.Created++
+			written[item.Key] = item
+		case mode == entities.ImportOverwrite:
+			result.Updated++
+			written[item.Key] = item
+		case mode == entities.ImportSkipExisting:
+			result.Skipped++
+		default:
+			result.Conflicts++
+			result.ConflictKeys = append(result.ConflictKeys, item.Key)
+			if !dryRun {
+				return entities.ImportResult{}, custom_errors.NewKeyExistsError(item.Key)
+			}
+		}
+	}
+	if !dryRun {
+		for _, item := range items {
+			if last, ok := written[item.Key]; ok {
+				r.put(last)
+				delete(written, item.Key)
+			}
+		}
+	}
+	return result, nil
+}
+
+// Dump pages a copy taken at once, like the read view of the transaction
+func (r *Repo) Dump(_ context.Context, fn func([]entities.VaultItem) error) error {
+	r.mu.Lock()
+	items := r.list("", "", len(r.items))
+	r.mu.Unlock()
+
+	for len(items) > 0 {
+		n := min(len(items), DumpPage)
+		if err := fn(items[:n]); err != nil {
+			return err
+		}
+		items = items[n:]
+	}
+	return nil
+}
+
+func (r *Repo) Ping(context.Context) error {
+	return nil
+}
@@ -2,25 +2,33 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"strings"
 	"time"
 
+	"github.com/tarantool/go-iproto"
 	"github.com/tarantool/go-tarantool/v2"
 	_ "github.com/tarantool/go-tarantool/v2/datetime"
 	_ "github.com/tarantool/go-tarantool/v2/decimal"
+	"github.com/tarantool/go-tarantool/v2/pool"
 	_ "github.com/tarantool/go-tarantool/v2/uuid"
 	"github.com/vvjke314/vk-test-03-2025/config"
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
 	"github.com/vvjke314/vk-test-03-2025/internal/entities"
 	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+	"github.com/vvjke314/vk-test-03-2025/internal/metrics"
+	"github.com/vvjke314/vk-test-03-2025/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // TnRepository represents Tarantool database repository
 type TnRepository struct {
-	dialer tarantool.NetDialer   // Connection dialer configuration
-	opts   tarantool.Opts        // Connection options
-	conn   *tarantool.Connection // Active connection
-	config *config.TnRepoConfig  // Repository configuration
-	logger logger.Logger         // Logger instance
+	exec   executor             // Single connection or pool of instances
+	config *config.TnRepoConfig // Repository configuration
+	logger logger.Logger        // Logger instance
 }
 
 // NewTnRepository creates new Tarantool repository instance
@@ -28,155 +36,613 @@ func NewTnRepository() *TnRepository {
 	return &TnRepository{}
 }
 
-// Init initializes Tarantool connection with provided configuration
+// Init initializes Tarantool connection with provided configuration.
+// A single instance gets one reconnecting connection, several instances
+// are served by a pool routing reads to replicas and writes to the master.
 func (trepo *TnRepository) Init(ctx context.Context, cfg *config.TnRepoConfig, l logger.Logger) error {
 	trepo.logger = l
-	trepo.logger.Info("establishing connection with tarantool")
 	trepo.config = cfg
-	trepo.dialer = tarantool.NetDialer{
-		Address:  fmt.Sprintf("tarantool:%s", trepo.config.Port),
-		User:     cfg.Username,
-		Password: cfg.Pass,
+	if err := cfg.Validate(); err != nil {
+		err = fmt.Errorf("invalid tarantool configuration: %w", err)
+		trepo.logger.Error(err.Error())
+		return err
 	}
 
-	fmt.Println(trepo.dialer)
+	trepo.logger.Info("establishing connection with tarantool",
+		logger.F("address", cfg.Address()), logger.F("tls", cfg.TLS.Enabled))
 
 	// Set connection timeout
-	var timeout time.Duration = time.Second * 5
-	if deadline, ok := ctx.Deadline(); !ok {
-		timeout = time.Second * 5 // Default timeout if not set in context
-	} else {
+	timeout := cfg.Timeout
+	if deadline, ok := ctx.Deadline(); ok {
 		timeout = time.Until(deadline) // Use context deadline
 	}
 
-	trepo.opts = tarantool.Opts{
-		Timeout: timeout,
+	opts := tarantool.Opts{
+		Timeout:       timeout,
+		Concurrency:   cfg.Concurrency,
+		Reconnect:     cfg.Reconnect,
+		MaxReconnects: cfg.MaxReconnects,
 	}
 
-	// Establish connection
 	var err error
-	trepo.conn, err = tarantool.Connect(ctx, trepo.dialer, trepo.opts)
+	addresses := cfg.Addresses()
+	if len(addresses) == 1 {
+		err = trepo.connect(ctx, addresses[0], opts)
+	} else {
+		err = trepo.connectPool(ctx, addresses, opts)
+	}
 	if err != nil {
 		err = fmt.Errorf("error occured while connecting to the tarantool instance: %w", err)
 		trepo.logger.Error(err.Error())
 		return err
 	}
 
-	trepo.logger.Info("tarantool client successfuly initialized")
+	trepo.logger.Info("tarantool client successfuly initialized", logger.F("instances", len(addresses)))
 	return nil
 }
 
-// Close terminates Tarantool connection
-func (trepo *TnRepository) Close() {
-	trepo.logger.Info("connection successfully closed")
-	trepo.conn.Close()
+func (trepo *TnRepository) connect(ctx context.Context, address string, opts tarantool.Opts) error {
+	dialer, err := trepo.newDialer(address)
+	if err != nil {
+		return err
+	}
+	conn, err := tarantool.Connect(ctx, dialer, opts)
+	if err != nil {
+		return err
+	}
+	trepo.exec = singleConn{conn: conn}
+	return nil
 }
 
-// InsertData inserts new key-value pair into vault space
-func (trepo *TnRepository) Insert(i entities.VaultItem) error {
-	tuple := []interface{}{i.Key, i.Value}
-	future := trepo.conn.Do(tarantool.NewInsertRequest("vault").Tuple(tuple))
-	_, err := future.Get()
+// connectPool connects to every instance. The pool reconnects on its own
+// and checks instance health and role every CheckInterval, so connector
+// reconnects are disabled for pooled connections.
+func (trepo *TnRepository) connectPool(ctx context.Context, addresses []string, opts tarantool.Opts) error {
+	opts.Reconnect = 0
+	opts.MaxReconnects = 0
+
+	instances := make([]pool.Instance, 0, len(addresses))
+	for _, address := range addresses {
+		dialer, err := trepo.newDialer(address)
+		if err != nil {
+			return err
+		}
+		instances = append(instances, pool.Instance{Name: address, Dialer: dialer, Opts: opts})
+	}
+
+	p, err := pool.ConnectWithOpts(ctx, instances, pool.Opts{
+		CheckTimeout:      trepo.config.CheckInterval,
+		ConnectionHandler: poolHandler{logger: trepo.logger},
+	})
 	if err != nil {
-		trepo.logger.Error(err.Error())
 		return err
 	}
-	trepo.logger.Info(fmt.Sprintf("inserted value with %s: %s values", i.Key, i.Value))
+	if ok, _ := p.ConnectedNow(anyMode); !ok {
+		p.Close()
+		return errors.New("no instance is reachable")
+	}
+	trepo.exec = p
 	return nil
 }
 
-// GetAllData retrieves all records from vault space
-func (trepo *TnRepository) GetAllData() ([]entities.VaultItem, error) {
-	trepo.logger.Info("scanning all data")
-	future := trepo.conn.Do(tarantool.NewSelectRequest("vault").Index("primary").Iterator(tarantool.IterAll).Limit(10000))
+// newDialer creates dialer for instance at address
+func (trepo *TnRepository) newDialer(address string) (tarantool.Dialer, error) {
+	cfg := trepo.config
+	if !cfg.TLS.Enabled {
+		// NetDialer authenticates with chap-sha1, Validate allows
+		// pap-sha256 only over TLS
+		return tarantool.NetDialer{
+			Address:  address,
+			User:     cfg.Username,
+			Password: cfg.Pass,
+		}, nil
+	}
 
-	resp, err := future.Get()
+	tlsCfg, err := cfg.TLSConfig()
 	if err != nil {
-		err = fmt.Errorf("failed to select data: %w", err)
+		return nil, fmt.Errorf("error occured while preparing tls: %w", err)
+	}
+	if cfg.TLS.ServerName == "" {
+		// verify every instance against its own host name
+		host, _, _ := net.SplitHostPort(address)
+		tlsCfg.ServerName = host
+	}
+	auth := tarantool.ChapSha1Auth
+	if cfg.Auth == config.TnAuthPapSha256 {
+		auth = tarantool.PapSha256Auth
+	}
+	return tlsDialer{
+		address:  address,
+		user:     cfg.Username,
+		password: cfg.Pass,
+		auth:     auth,
+		config:   tlsCfg,
+	}, nil
+}
+
+// Close waits for pending requests to complete and terminates Tarantool connection
+func (trepo *TnRepository) Close() error {
+	if err := errors.Join(trepo.exec.CloseGraceful()...); err != nil {
+		err = fmt.Errorf("error occured while closing connection: %w", err)
 		trepo.logger.Error(err.Error())
-		return nil, err
+		return err
 	}
 
-	// Convert response to VaultItem slice
-	var results []entities.VaultItem
-	for _, tuple := range resp {
-		tupleSlice, ok := tuple.([]interface{})
-		if !ok || len(tupleSlice) < 2 {
-			err = fmt.Errorf("invalid tuple format")
-			trepo.logger.Error(err.Error())
-			return nil, err
+	trepo.logger.Info("connection successfully closed")
+	return nil
+}
+
+// get executes request recording its latency and failure under operation name.
+// Request must be bound to ctx so that cancellation reaches the connector.
+func (trepo *TnRepository) get(ctx context.Context, operation string, mode pool.Mode, req tarantool.Request) ([]interface{}, error) {
+	_, span := trepo.startSpan(ctx, operation)
+	start := time.Now()
+	resp, err := trepo.exec.Do(req, mode).Get()
+	err = classifyErr(ctx, err)
+	metrics.ObserveTarantool(operation, start, err)
+	tracing.End(span, err)
+	return resp, err
+}
+
+// getTyped is get decoding response into result
+func (trepo *TnRepository) getTyped(ctx context.Context, operation string, mode pool.Mode, req tarantool.Request, result interface{}) error {
+	_, span := trepo.startSpan(ctx, operation)
+	start := time.Now()
+	err := classifyErr(ctx, trepo.exec.Do(req, mode).GetTyped(result))
+	metrics.ObserveTarantool(operation, start, err)
+	tracing.End(span, err)
+	return err
+}
+
+// streamTyped is getTyped sending req over stream
+func (trepo *TnRepository) streamTyped(ctx context.Context, operation string, stream *tarantool.Stream, req tarantool.Request, result interface{}) error {
+	_, span := trepo.startSpan(ctx, operation)
+	start := time.Now()
+	err := classifyErr(ctx, stream.Do(req).GetTyped(result))
+	metrics.ObserveTarantool(operation, start, err)
+	tracing.End(span, err)
+	return err
+}
+
+// startSpan starts a client span describing a single Tarantool call
+func (trepo *TnRepository) startSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return tracing.StartClient(ctx, "tarantool "+operation,
+		attribute.String("db.system", "tarantool"),
+		attribute.String("db.operation.name", operation),
+		attribute.String("server.address", trepo.config.Address()),
+	)
+}
+
+// classifyErr converts connector failures into typed errors: exceeded
+// deadlines become timeouts, connectivity problems become unavailability.
+// The connector reports cancellation as plain text, so the context error
+// is wrapped to keep errors.Is(err, context.Canceled) working.
+func classifyErr(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		if !errors.Is(err, ctxErr) {
+			err = fmt.Errorf("%w: %v", ctxErr, err)
+		}
+		if errors.Is(ctxErr, context.DeadlineExceeded) {
+			return custom_errors.NewTimeoutError(err)
 		}
+		return err
+	}
+
+	if isPoolUnavailable(err) {
+		return custom_errors.NewUnavailableError(err)
+	}
 
-		item := entities.VaultItem{
-			Key:   fmt.Sprintf("%v", tupleSlice[0]),
-			Value: fmt.Sprintf("%v", tupleSlice[1]),
+	var clientErr tarantool.ClientError
+	if errors.As(err, &clientErr) {
+		switch clientErr.Code {
+		case tarantool.ErrTimeouted:
+			return custom_errors.NewTimeoutError(err)
+		case tarantool.ErrConnectionNotReady, tarantool.ErrConnectionClosed,
+			tarantool.ErrConnectionShutdown, tarantool.ErrIoError, tarantool.ErrRateLimited:
+			return custom_errors.NewUnavailableError(err)
 		}
-		results = append(results, item)
 	}
+	return err
+}
 
-	trepo.logger.Info("scanned all data")
-	return results, nil
+// serverCode returns error code reported by Tarantool, 0 for other errors
+func serverCode(err error) iproto.Error {
+	var tnErr tarantool.Error
+	if errors.As(err, &tnErr) {
+		return tnErr.Code
+	}
+	return 0
 }
 
-// KeyExists checks if key exists in vault space
-func (trepo *TnRepository) KeyExists(key string) (bool, error) {
-	trepo.logger.Info(fmt.Sprintf("checking for key (%s) existence", key))
-	var resp []entities.VaultItem
-	err := trepo.conn.Do(tarantool.NewCallRequest(
-		"key_check").Args([]interface{}{key})).GetTyped(&resp)
+// Connected reports whether connection to Tarantool is currently established
+func (trepo *TnRepository) Connected() bool {
+	if trepo.exec == nil {
+		return false
+	}
+	ok, _ := trepo.exec.ConnectedNow(anyMode)
+	return ok
+}
 
+// Count returns number of records in vault space
+func (trepo *TnRepository) Count(ctx context.Context) (uint64, error) {
+	log := logger.FromContext(ctx, trepo.logger)
+	var resp []uint64
+	err := trepo.getTyped(ctx, "count", readMode, tarantool.NewCallRequest("key_count").Context(ctx), &resp)
 	if err != nil {
 		err = fmt.Errorf("count failed: %w", err)
-		trepo.logger.Error(err.Error())
+		log.Error(err.Error())
+		return 0, err
+	}
+	if len(resp) == 0 {
+		return 0, fmt.Errorf("count failed: empty response")
+	}
+	return resp[0], nil
+}
+
+// Ping checks that Tarantool instance responds within ctx deadline
+func (trepo *TnRepository) Ping(ctx context.Context) error {
+	log := logger.FromContext(ctx, trepo.logger)
+	if _, err := trepo.get(ctx, "ping", anyMode, tarantool.NewPingRequest().Context(ctx)); err != nil {
+		err = fmt.Errorf("ping failed: %w", err)
+		log.Error(err.Error())
+		return err
+	}
+	return nil
+}
+
+// Insert atomically adds new key-value pair with its expiry into vault
+// space. Returns custom_errors.ErrKeyExists when key is already stored.
+func (trepo *TnRepository) Insert(ctx context.Context, i entities.VaultItem) error {
+	log := logger.FromContext(ctx, trepo.logger)
+	result, err := trepo.call(ctx, "insert", "key_insert", i.Key, i.Value, toMillis(i.ExpiresAt), toFlags(i.Flags))
+	if err != nil {
+		err = fmt.Errorf("insert failed: %w", err)
+		log.Error(err.Error())
+		return err
+	}
+	switch result {
+	case "ok":
+		log.Debug("inserted value", logger.F("key", i.Key), logger.Sensitive("value", i.Key, i.Value))
+		return nil
+	case "exists":
+		log.Debug("key already exists", logger.F("key", i.Key))
+		return custom_errors.NewKeyExistsError(i.Key)
+	}
+	err = fmt.Errorf("insert failed: unexpected result %q", result)
+	log.Error(err.Error())
+	return err
+}
+
+// call executes Lua function fn answering with a single string
+func (trepo *TnRepository) call(ctx context.Context, operation, fn string, args ...interface{}) (string, error) {
+	var resp []string
+	err := trepo.getTyped(ctx, operation, writeMode,
+		tarantool.NewCallRequest(fn).Args(args).Context(ctx), &resp)
+	if err != nil || len(resp) == 0 {
+		return "", err
+	}
+	return resp[0], nil
+}
+
+// KeyExists checks if key exists in vault space.
+// Write operations do not need it, they report missing or duplicate keys themselves.
+func (trepo *TnRepository) KeyExists(ctx context.Context, key string) (bool, error) {
+	log := logger.FromContext(ctx, trepo.logger)
+	log.Debug("checking key existence", logger.F("key", key))
+	var resp []vaultTuple
+	err := trepo.getTyped(ctx, "key_exists", readMode, tarantool.NewCallRequest(
+		"key_check").Args([]interface{}{key}).Context(ctx), &resp)
+
+	if err != nil {
+		err = fmt.Errorf("count failed: %w", err)
+		log.Error(err.Error())
 		return false, err
 	}
-	trepo.logger.Info(fmt.Sprintf("key %s existence is %v", key, len(resp) > 0))
-	return len(resp) > 0, nil
+	exists := len(liveItems(resp, time.Now())) > 0
+	log.Debug("checked key existence", logger.F("key", key), logger.F("exists", exists))
+	return exists, nil
 }
 
-// Delete removes record with specified key from vault space
-func (trepo *TnRepository) Delete(key string) error {
-	trepo.logger.Info(fmt.Sprintf("deleting row with %s key", key))
-	resp, err := trepo.conn.Do(tarantool.NewDeleteRequest("vault").Key([]interface{}{key})).Get()
+// Delete atomically removes record with specified key from vault space.
+// Returns custom_errors.ErrKeyNotExists when there was nothing to delete.
+func (trepo *TnRepository) Delete(ctx context.Context, key string) error {
+	log := logger.FromContext(ctx, trepo.logger)
+	log.Debug("deleting row", logger.F("key", key))
+	var resp []vaultTuple
+	err := trepo.getTyped(ctx, "delete", writeMode, tarantool.NewDeleteRequest("vault").Key([]interface{}{key}).Context(ctx), &resp)
 	if err != nil {
 		err = fmt.Errorf("delete failed: %w", err)
-		trepo.logger.Error(err.Error())
+		log.Error(err.Error())
 		return err
 	}
+	// an expired key is deleted before its time, but it was missing already
+	if len(liveItems(resp, time.Now())) == 0 {
+		return custom_errors.NewKeyNotExistsError(key)
+	}
 
-	trepo.logger.Info(fmt.Sprintf("successfully deleted %v", resp))
+	log.Debug("successfully deleted", logger.F("key", key))
 	return nil
 }
 
-// Update modifies value for existing key in vault space
-func (trepo *TnRepository) Update(key string, value string) error {
-	resp, err := trepo.conn.Do(
-		tarantool.NewUpdateRequest("vault").
-			Key([]interface{}{key}).
-			Operations(tarantool.NewOperations().Assign(1, value))).Get()
+// Update atomically replaces value, expiry and flags of existing key in vault
+// space. Returns custom_errors.ErrKeyNotExists when key is not stored.
+func (trepo *TnRepository) Update(ctx context.Context, i entities.VaultItem) error {
+	log := logger.FromContext(ctx, trepo.logger)
+	result, err := trepo.call(ctx, "update", "key_update", i.Key, i.Value, toMillis(i.ExpiresAt), toFlags(i.Flags))
 	if err != nil {
 		err = fmt.Errorf("update failed: %w", err)
-		trepo.logger.Error(err.Error())
+		log.Error(err.Error())
 		return err
 	}
-
-	trepo.logger.Info(fmt.Sprintf("successfully updated %v", resp))
-	return nil
+	switch result {
+	case "ok":
+		log.Debug("successfully updated", logger.F("key", i.Key), logger.Sensitive("value", i.Key, i.Value))
+		return nil
+	case "not_found":
+		return custom_errors.NewKeyNotExistsError(i.Key)
+	}
+	err = fmt.Errorf("update failed: unexpected result %q", result)
+	log.Error(err.Error())
+	return err
 }
 
-// Get retrieves single record by key from vault space
-func (trepo *TnRepository) Get(key string) (entities.VaultItem, error) {
-	trepo.logger.Info(fmt.Sprintf("searching for row with %s key", key))
-	var resp []entities.VaultItem
-	err := trepo.conn.Do(tarantool.NewCallRequest(
-		"key_check").Args([]interface{}{key})).GetTyped(&resp)
+// Expire atomically sets the time key expires at, zero time makes it
+// persistent. Returns custom_errors.ErrKeyNotExists when key is not stored.
+func (trepo *TnRepository) Expire(ctx context.Context, key string, at time.Time) error {
+	log := logger.FromContext(ctx, trepo.logger)
+	result, err := trepo.call(ctx, "expire", "key_expire", key, toMillis(at))
+	if err != nil {
+		err = fmt.Errorf("expire failed: %w", err)
+		log.Error(err.Error())
+		return err
+	}
+	switch result {
+	case "ok":
+		log.Debug("successfully set expiry", logger.F("key", key), logger.F("expires_at", at))
+		return nil
+	case "not_found":
+		return custom_errors.NewKeyNotExistsError(key)
+	}
+	err = fmt.Errorf("expire failed: unexpected result %q", result)
+	log.Error(err.Error())
+	return err
+}
 
+// Get retrieves single record by key from vault space.
+// Returns custom_errors.ErrKeyNotExists when key is not stored.
+func (trepo *TnRepository) Get(ctx context.Context, key string) (entities.VaultItem, error) {
+	log := logger.FromContext(ctx, trepo.logger)
+	log.Debug("searching for row", logger.F("key", key))
+	var resp []vaultTuple
+	err := trepo.getTyped(ctx, "get", readMode,
+		tarantool.NewSelectRequest("vault").
+			Index("primary").
+			Iterator(tarantool.IterEq).
+			Key([]interface{}{key}).
+			Limit(1).
+			Context(ctx), &resp)
 	if err != nil {
-		err = fmt.Errorf("count failed: %w", err)
-		trepo.logger.Error(err.Error())
+		err = fmt.Errorf("get failed: %w", err)
+		log.Error(err.Error())
 		return entities.VaultItem{}, err
 	}
+	items := liveItems(resp, time.Now())
+	if len(items) == 0 {
+		return entities.VaultItem{}, custom_errors.NewKeyNotExistsError(key)
+	}
 
-	trepo.logger.Info(fmt.Sprintf("successfully got row %v with %s key", resp[0], key))
-	return resp[0], nil
+	log.Debug("successfully got row", logger.F("key", key), logger.Sensitive("value", key, items[0].Value))
+	return items[0], nil
+}
+
+// GetVersioned retrieves record by key with its version, which changes on
+// every write of the key. Returns custom_errors.ErrKeyNotExists when key is
+// not stored.
+func (trepo *TnRepository) GetVersioned(ctx context.Context, key string) (entities.VaultItem, uint64, error) {
+	log := logger.FromContext(ctx, trepo.logger)
+	log.Debug("searching for row with version", logger.F("key", key))
+	var resp []versionedItem
+	err := trepo.getTyped(ctx, "get_versioned", readMode,
+		tarantool.NewCallRequest("key_gets").Args([]interface{}{key}).Context(ctx), &resp)
+	if err != nil {
+		err = fmt.Errorf("get versioned failed: %w", err)
+		log.Error(err.Error())
+		return entities.VaultItem{}, 0, err
+	}
+	if len(resp) == 0 {
+		return entities.VaultItem{}, 0, custom_errors.NewKeyNotExistsError(key)
+	}
+
+	log.Debug("successfully got row", logger.F("key", key), logger.F("version", resp[0].Version))
+	return resp[0].Item, resp[0].Version, nil
+}
+
+// CompareAndSwap atomically replaces value, expiry and flags of key if its version
+// is still version. Returns custom_errors.ErrKeyNotExists when key is not
+// stored and custom_errors.ErrConflict when it was written since.
+func (trepo *TnRepository) CompareAndSwap(ctx context.Context, i entities.VaultItem, version uint64) error {
+	log := logger.FromContext(ctx, trepo.logger)
+	result, err := trepo.call(ctx, "compare_and_swap", "key_cas", i.Key, i.Value, version, toMillis(i.ExpiresAt), toFlags(i.Flags))
+	if err != nil {
+		err = fmt.Errorf("compare and swap failed: %w", err)
+		log.Error(err.Error())
+		return err
+	}
+
+	switch result {
+	case "ok":
+		log.Debug("successfully swapped", logger.F("key", i.Key), logger.Sensitive("value", i.Key, i.Value))
+		return nil
+	case "not_found":
+		return custom_errors.NewKeyNotExistsError(i.Key)
+	case "conflict":
+		log.Debug("version changed", logger.F("key", i.Key), logger.F("version", version))
+		return custom_errors.NewVersionConflictError(i.Key)
+	}
+	err = fmt.Errorf("compare and swap failed: unexpected result %q", result)
+	log.Error(err.Error())
+	return err
+}
+
+// importResult is the result of key_import
+type importResult struct {
+	Created      int
+	Updated      int
+	Skipped      int
+	Conflicts    int
+	ConflictKeys []string
+}
+
+// Import writes items in one transaction according to mode, a dry run
+// only counts what would be written. In fail-on-conflict mode nothing is
+// written when a key exists, custom_errors.ErrKeyExists names the first one.
+func (trepo *TnRepository) Import(ctx context.Context, items []entities.VaultItem, mode entities.ImportMode, dryRun bool) (entities.ImportResult, error) {
+	log := logger.FromContext(ctx, trepo.logger)
+	records := make([][]interface{}, len(items))
+	for i, item := range items {
+		records[i] = []interface{}{item.Key, item.Value, toMillis(item.ExpiresAt), toFlags(item.Flags)}
+	}
+
+	var resp []importResult
+	err := trepo.getTyped(ctx, "import", writeMode,
+		tarantool.NewCallRequest("key_import").Args([]interface{}{records, string(mode), dryRun}).Context(ctx), &resp)
+	if err != nil {
+		err = fmt.Errorf("import failed: %w", err)
+		log.Error(err.Error())
+		return entities.ImportResult{}, err
+	}
+	if len(resp) == 0 {
+		err = fmt.Errorf("import failed: empty result")
+		log.Error(err.Error())
+		return entities.ImportResult{}, err
+	}
+
+	result := entities.ImportResult(resp[0])
+	if !dryRun && mode == entities.ImportFailOnConflict && result.Conflicts > 0 {
+		return entities.ImportResult{}, custom_errors.NewKeyExistsError(result.ConflictKeys[0])
+	}
+	log.Debug("imported chunk", logger.F("records", len(items)), logger.F("dry_run", dryRun),
+		logger.F("created", result.Created), logger.F("updated", result.Updated), logger.F("skipped", result.Skipped))
+	return result, nil
+}
+
+// dumpPage is the number of records Dump reads at once
+const dumpPage = 1000
+
+// minRollbackTimeout bounds the rollback of Dump when the request timeout is
+// shorter or unset, the rollback must not wait forever nor fail at once
+const minRollbackTimeout = 5 * time.Second
+
+// Dump calls fn with all records of vault space page by page in key order.
+// The pages are read in one read-only transaction over a stream, so they
+// make up a consistent state of the space however long fn takes. Interactive
+// transactions need memtx.use_mvcc_engine enabled in Tarantool.
+// Errors of fn are returned as is.
+func (trepo *TnRepository) Dump(ctx context.Context, fn func([]entities.VaultItem) error) error {
+	log := logger.FromContext(ctx, trepo.logger)
+	fail := func(err error) error {
+		err = fmt.Errorf("dump failed: %w", err)
+		log.Error(err.Error())
+		return err
+	}
+
+	stream, err := trepo.exec.NewStream(readMode)
+	if err != nil {
+		return fail(classifyErr(ctx, err))
+	}
+	var none []interface{}
+	begin := tarantool.NewBeginRequest().TxnIsolation(tarantool.ReadConfirmedLevel).Context(ctx)
+	if err := trepo.streamTyped(ctx, "dump_begin", stream, begin, &none); err != nil {
+		return fail(err)
+	}
+	defer func() {
+		// the transaction only read, there is nothing to commit
+		rctx, cancel := context.WithTimeout(context.Background(), max(trepo.config.Timeout, minRollbackTimeout))
+		defer cancel()
+		trepo.streamTyped(rctx, "dump_rollback", stream, tarantool.NewRollbackRequest().Context(rctx), &none)
+	}()
+
+	// every string follows the empty one
+	after, iterator, total := "", tarantool.IterGe, 0
+	for {
+		var page []vaultTuple
+		err := trepo.streamTyped(ctx, "dump", stream,
+			tarantool.NewSelectRequest("vault").
+				Index("primary").
+				Iterator(iterator).
+				Key([]interface{}{after}).
+				Limit(dumpPage).
+				Context(ctx), &page)
+		if err != nil {
+			return fail(err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		// keys expired but not deleted yet are left out like by Get
+		if items := liveItems(page, time.Now()); len(items) > 0 {
+			if err := fn(items); err != nil {
+				return err
+			}
+			total += len(items)
+		}
+		if len(page) < dumpPage {
+			break
+		}
+		after, iterator = page[len(page)-1].Key, tarantool.IterGt
+	}
+
+	log.Debug("dumped records", logger.F("count", total))
+	return nil
+}
+
+// List returns up to limit records ordered by key whose keys start with
+// prefix and follow after, an empty after starts from the first such key
+func (trepo *TnRepository) List(ctx context.Context, prefix, after string, limit int) ([]entities.VaultItem, error) {
+	log := logger.FromContext(ctx, trepo.logger)
+	log.Debug("listing keys", logger.F("prefix", prefix), logger.F("after", after), logger.F("limit", limit))
+
+	// keys sharing a prefix are contiguous in the tree index
+	start, iterator := prefix, tarantool.IterGe
+	if after != "" && after >= prefix {
+		start, iterator = after, tarantool.IterGt
+	}
+
+	// expired keys not deleted yet are skipped, reading on keeps the page
+	// full so that a short page still means the end
+	var items []entities.VaultItem
+	for len(items) < limit {
+		n := limit - len(items)
+		var resp []vaultTuple
+		err := trepo.getTyped(ctx, "list", readMode,
+			tarantool.NewSelectRequest("vault").
+				Index("primary").
+				Iterator(iterator).
+				Key([]interface{}{start}).
+				Limit(uint32(n)).
+				Context(ctx), &resp)
+		if err != nil {
+			err = fmt.Errorf("list failed: %w", err)
+			log.Error(err.Error())
+			return nil, err
+		}
+
+		for i, t := range resp {
+			if !strings.HasPrefix(t.Key, prefix) {
+				resp = resp[:i]
+				break
+			}
+		}
+		items = append(items, liveItems(resp, time.Now())...)
+		// the space or the prefix ended
+		if len(resp) < n {
+			break
+		}
+		start, iterator = resp[len(resp)-1].Key, tarantool.IterGt
+	}
+	log.Debug("listed keys", logger.F("count", len(items)))
+	return items, nil
 }
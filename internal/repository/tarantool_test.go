@@ -2,22 +2,38 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/vvjke314/vk-test-03-2025/config"
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
 	"github.com/vvjke314/vk-test-03-2025/internal/entities"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
 )
 
 type MockLogger struct {
 }
 
-func (m MockLogger) Error(message string) {
-	fmt.Println(message)
+func (m MockLogger) Debug(message string, fields ...logger.Field) {
+	fmt.Println(message, fields)
 }
 
-func (m MockLogger) Info(message string) {
-	fmt.Println(message)
+func (m MockLogger) Info(message string, fields ...logger.Field) {
+	fmt.Println(message, fields)
+}
+
+func (m MockLogger) Warn(message string, fields ...logger.Field) {
+	fmt.Println(message, fields)
+}
+
+func (m MockLogger) Error(message string, fields ...logger.Field) {
+	fmt.Println(message, fields)
+}
+
+func (m MockLogger) With(fields ...logger.Field) logger.Logger {
+	return m
 }
 
 func initRepository() *TnRepository {
@@ -52,19 +68,63 @@ func TestConnect(t *testing.T) {
 	defer repo.Close()
 }
 
+func TestPing(t *testing.T) {
+	repo := initRepository()
+	defer repo.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := repo.Ping(ctx); err != nil {
+		t.Errorf("ping failed: %v", err)
+		return
+	}
+}
+
+func TestCount(t *testing.T) {
+	repo := initRepository()
+	defer repo.Close()
+
+	if !repo.Connected() {
+		t.Errorf("repository is not connected")
+		return
+	}
+
+	count, err := repo.Count(context.Background())
+	if err != nil {
+		t.Errorf("count failed: %v", err)
+		return
+	}
+	t.Logf("Got %d keys", count)
+}
+
+func TestGetDeadline(t *testing.T) {
+	repo := initRepository()
+	defer repo.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := repo.Get(ctx, "vova")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+		return
+	}
+}
+
 func TestInsert(t *testing.T) {
 	repo := initRepository()
 	defer repo.Close()
 
 	inserts := []entities.VaultItem{
-		{"vova", "petya"},
-		{"petya", "12"},
-		{"13", "vova"},
+		{Key: "vova", Value: "petya"},
+		{Key: "petya", Value: "12"},
+		{Key: "13", Value: "vova"},
 	}
 
 	var err error
 	for i := range inserts {
-		err = repo.Insert(inserts[i])
+		err = repo.Insert(context.Background(), inserts[i])
 		if err != nil {
 			t.Errorf("error occured while inserting: %v", err)
 			return
@@ -72,18 +132,25 @@ func TestInsert(t *testing.T) {
 	}
 }
 
-func TestGetAllData(t *testing.T) {
+func TestDumpAll(t *testing.T) {
 	repo := initRepository()
 	defer repo.Close()
-	items, err := repo.GetAllData()
-	if err != nil {
-		t.Fatalf("GetAllData failed: %v", err)
-	}
 
-	t.Logf("Got %d items", len(items))
-	for _, item := range items {
-		t.Logf("Key: %s, Value: %s", item.Key, item.Value)
+	now := time.Now()
+	var items int
+	err := repo.Dump(context.Background(), func(page []entities.VaultItem) error {
+		for _, item := range page {
+			if item.Expired(now) {
+				t.Errorf("expired key %s was dumped", item.Key)
+			}
+		}
+		items += len(page)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("dump failed: %v", err)
 	}
+	t.Logf("Got %d items", items)
 }
 
 type KeyExistenceCase struct {
@@ -104,7 +171,7 @@ func TestKeyExistence(t *testing.T) {
 	}
 
 	for _, k := range keys {
-		exist, err := repo.KeyExists(k.key)
+		exist, err := repo.KeyExists(context.Background(), k.key)
 		if err != nil {
 			t.Errorf("error occured. %v", err)
 			return
@@ -120,15 +187,15 @@ func TestDelete(t *testing.T) {
 	repo := initRepository()
 	defer repo.Close()
 
-	data := entities.VaultItem{"hello", "world"}
+	data := entities.VaultItem{Key: "hello", Value: "world"}
 
-	err := repo.Insert(data)
+	err := repo.Insert(context.Background(), data)
 	if err != nil {
 		t.Errorf("failed while inserting data: %v", err)
 		return
 	}
 
-	err = repo.Delete(data.Key)
+	err = repo.Delete(context.Background(), data.Key)
 	if err != nil {
 		t.Errorf("failed while deleting data: %v", err)
 		return
@@ -139,16 +206,16 @@ func TestUpdate(t *testing.T) {
 	repo := initRepository()
 	defer repo.Close()
 
-	data := entities.VaultItem{"hello", "world"}
+	data := entities.VaultItem{Key: "hello", Value: "world"}
 
-	err := repo.Insert(data)
+	err := repo.Insert(context.Background(), data)
 	if err != nil {
 		t.Errorf("failed while inserting data: %v", err)
 		return
 	}
-	defer repo.Delete(data.Key)
+	defer repo.Delete(context.Background(), data.Key)
 
-	err = repo.Update(data.Key, "tarantool!")
+	err = repo.Update(context.Background(), entities.VaultItem{Key: data.Key, Value: "tarantool!"})
 	if err != nil {
 		t.Errorf("failed while deleting data: %v", err)
 		return
@@ -164,14 +231,14 @@ func TestGet(t *testing.T) {
 		Value: "world",
 	}
 
-	err := repo.Insert(data)
+	err := repo.Insert(context.Background(), data)
 	if err != nil {
 		t.Errorf("failed while inserting data: %v", err)
 		return
 	}
-	defer repo.Delete(data.Key)
+	defer repo.Delete(context.Background(), data.Key)
 
-	result, err := repo.Get(data.Key)
+	result, err := repo.Get(context.Background(), data.Key)
 	if err != nil {
 		t.Errorf("failed while deleting data: %v", err)
 		return
@@ -182,3 +249,177 @@ func TestGet(t *testing.T) {
 		return
 	}
 }
+
+func TestInsertDuplicate(t *testing.T) {
+	repo := initRepository()
+	defer repo.Close()
+
+	data := entities.VaultItem{Key: "hello", Value: "world"}
+
+	err := repo.Insert(context.Background(), data)
+	if err != nil {
+		t.Errorf("failed while inserting data: %v", err)
+		return
+	}
+	defer repo.Delete(context.Background(), data.Key)
+
+	err = repo.Insert(context.Background(), data)
+	if !errors.Is(err, custom_errors.ErrKeyExists) {
+		t.Errorf("expected key exists error, got %v", err)
+	}
+}
+
+func TestMissingKey(t *testing.T) {
+	repo := initRepository()
+	defer repo.Close()
+
+	ctx := context.Background()
+	key := "missing-key"
+
+	if _, err := repo.Get(ctx, key); !errors.Is(err, custom_errors.ErrKeyNotExists) {
+		t.Errorf("get: expected key not exists error, got %v", err)
+	}
+	if err := repo.Update(ctx, entities.VaultItem{Key: key, Value: "value"}); !errors.Is(err, custom_errors.ErrKeyNotExists) {
+		t.Errorf("update: expected key not exists error, got %v", err)
+	}
+	if err := repo.Delete(ctx, key); !errors.Is(err, custom_errors.ErrKeyNotExists) {
+		t.Errorf("delete: expected key not exists error, got %v", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	repo := initRepository()
+	defer repo.Close()
+
+	ctx := context.Background()
+	for _, key := range []string{"list:a", "list:b", "list:c", "listing"} {
+		if err := repo.Insert(ctx, entities.VaultItem{Key: key, Value: "1"}); err != nil {
+			t.Errorf("failed while inserting data: %v", err)
+			return
+		}
+		defer repo.Delete(ctx, key)
+	}
+
+	page, err := repo.List(ctx, "list:", "", 2)
+	if err != nil || len(page) != 2 || page[0].Key != "list:a" || page[1].Key != "list:b" {
+		t.Errorf("first page: %v, %v", page, err)
+		return
+	}
+	page, err = repo.List(ctx, "list:", page[1].Key, 2)
+	if err != nil || len(page) != 1 || page[0].Key != "list:c" {
+		t.Errorf("second page must stop at the prefix end: %v, %v", page, err)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	repo := initRepository()
+	defer repo.Close()
+
+	ctx := context.Background()
+	key := "cas:key"
+	if err := repo.Insert(ctx, entities.VaultItem{Key: key, Value: "1"}); err != nil {
+		t.Errorf("failed while inserting data: %v", err)
+		return
+	}
+	defer repo.Delete(ctx, key)
+
+	item, version, err := repo.GetVersioned(ctx, key)
+	if err != nil || item.Value != "1" {
+		t.Errorf("get versioned: %v, %v", item, err)
+		return
+	}
+	if err := repo.CompareAndSwap(ctx, entities.VaultItem{Key: key, Value: "2"}, version); err != nil {
+		t.Errorf("swap with current version: %v", err)
+	}
+	if err := repo.CompareAndSwap(ctx, entities.VaultItem{Key: key, Value: "3"}, version); !errors.Is(err, custom_errors.ErrConflict) {
+		t.Errorf("swap with stale version must conflict: %v", err)
+	}
+	if err := repo.CompareAndSwap(ctx, entities.VaultItem{Key: "cas:missing", Value: "1"}, version); !errors.Is(err, custom_errors.ErrKeyNotExists) {
+		t.Errorf("swap of missing key: %v", err)
+	}
+}
+
+func TestImport(t *testing.T) {
+	repo := initRepository()
+	defer repo.Close()
+
+	ctx := context.Background()
+	if err := repo.Insert(ctx, entities.VaultItem{Key: "import:a", Value: "1"}); err != nil {
+		t.Errorf("failed while inserting data: %v", err)
+		return
+	}
+	defer repo.Delete(ctx, "import:a")
+	defer repo.Delete(ctx, "import:b")
+
+	items := []entities.VaultItem{{Key: "import:a", Value: "2"}, {Key: "import:b", Value: "3"}}
+	result, err := repo.Import(ctx, items, entities.ImportFailOnConflict, true)
+	if err != nil || result.Created != 1 || result.Conflicts != 1 || result.ConflictKeys[0] != "import:a" {
+		t.Errorf("dry run: %+v, %v", result, err)
+	}
+	if _, err := repo.Import(ctx, items, entities.ImportFailOnConflict, false); !errors.Is(err, custom_errors.ErrKeyExists) {
+		t.Errorf("conflict must fail the chunk: %v", err)
+	}
+	if _, err := repo.Get(ctx, "import:b"); !errors.Is(err, custom_errors.ErrKeyNotExists) {
+		t.Errorf("failed chunk must be rolled back: %v", err)
+	}
+
+	result, err = repo.Import(ctx, items, entities.ImportOverwrite, false)
+	if err != nil || result.Created != 1 || result.Updated != 1 {
+		t.Errorf("overwrite: %+v, %v", result, err)
+	}
+	if item, err := repo.Get(ctx, "import:a"); err != nil || item.Value != "2" {
+		t.Errorf("overwritten value: %v, %v", item, err)
+	}
+
+	// imported records keep their expiry and flags
+	expiring := entities.VaultItem{Key: "import:b", Value: "4", ExpiresAt: time.Now().Add(time.Hour).Truncate(time.Millisecond), Flags: 3}
+	if _, err := repo.Import(ctx, []entities.VaultItem{expiring}, entities.ImportOverwrite, false); err != nil {
+		t.Errorf("import with expiry: %v", err)
+	}
+	if item, err := repo.Get(ctx, "import:b"); err != nil || !item.ExpiresAt.Equal(expiring.ExpiresAt) || item.Flags != 3 {
+		t.Errorf("imported expiry and flags: %+v, %v", item, err)
+	}
+
+	// a dry run counts a repeated key as existing, as the import would
+	repeated := []entities.VaultItem{{Key: "import:c", Value: "1"}, {Key: "import:c", Value: "2"}}
+	result, err = repo.Import(ctx, repeated, entities.ImportSkipExisting, true)
+	if err != nil || result.Created != 1 || result.Skipped != 1 {
+		t.Errorf("dry run of repeated key: %+v, %v", result, err)
+	}
+}
+
+func TestDump(t *testing.T) {
+	repo := initRepository()
+	defer repo.Close()
+
+	ctx := context.Background()
+	for i := 0; i < dumpPage+1; i++ {
+		key := fmt.Sprintf("dump:%04d", i)
+		if err := repo.Insert(ctx, entities.VaultItem{Key: key, Value: "1"}); err != nil {
+			t.Fatalf("failed while inserting data: %v", err)
+		}
+		defer repo.Delete(ctx, key)
+	}
+
+	// writes made while the dump runs are not seen by it
+	var keys []string
+	err := repo.Dump(ctx, func(page []entities.VaultItem) error {
+		repo.Insert(ctx, entities.VaultItem{Key: "dump:new", Value: "1"})
+		for _, item := range page {
+			keys = append(keys, item.Key)
+		}
+		return nil
+	})
+	defer repo.Delete(ctx, "dump:new")
+	if err != nil {
+		t.Fatalf("dump failed: %v", err)
+	}
+	for i, key := range keys {
+		if key == "dump:new" {
+			t.Errorf("key written during the dump was dumped")
+		}
+		if i > 0 && keys[i-1] >= key {
+			t.Errorf("keys are not ordered: %s, %s", keys[i-1], key)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/tarantool/go-tarantool/v2"
+)
+
+const tlsBufSize = 128 * 1024
+
+// tlsDialer connects to Tarantool over TLS. Only the transport differs from
+// tarantool.NetDialer: the connector's own GreetingDialer, ProtocolDialer and
+// AuthDialer read the greeting, negotiate protocol features (IPROTO_ID, which
+// streams and watchers depend on) and authenticate over the encrypted stream.
+type tlsDialer struct {
+	address  string
+	user     string
+	password string
+	auth     tarantool.Auth
+	config   *tls.Config
+}
+
+// Dial makes tlsDialer satisfy the tarantool.Dialer interface
+func (d tlsDialer) Dial(ctx context.Context, opts tarantool.DialOpts) (tarantool.Conn, error) {
+	dialer := tarantool.AuthDialer{
+		Dialer: tarantool.ProtocolDialer{
+			Dialer: tarantool.GreetingDialer{
+				Dialer: tlsTransportDialer{address: d.address, config: d.config},
+			},
+		},
+		Auth:     d.auth,
+		Username: d.user,
+		Password: d.password,
+	}
+
+	return dialer.Dial(ctx, opts)
+}
+
+// tlsTransportDialer establishes the encrypted transport, the base of the
+// dialer chain like the connector's unexported netDialer
+type tlsTransportDialer struct {
+	address string
+	config  *tls.Config
+}
+
+func (d tlsTransportDialer) Dial(ctx context.Context, opts tarantool.DialOpts) (tarantool.Conn, error) {
+	dialer := tls.Dialer{Config: d.config}
+	conn, err := dialer.DialContext(ctx, "tcp", d.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial: %w", err)
+	}
+
+	dc := &deadlineConn{Conn: conn, timeout: opts.IoTimeout}
+	return &tlsConn{
+		net:    conn,
+		reader: bufio.NewReaderSize(dc, tlsBufSize),
+		writer: bufio.NewWriterSize(dc, tlsBufSize),
+	}, nil
+}
+
+// tlsConn implements tarantool.Conn on top of a TLS connection. Greeting and
+// ProtocolInfo are not known at this level, GreetingDialer and
+// ProtocolDialer wrap the connection and answer them from the handshake.
+type tlsConn struct {
+	net    net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+func (c *tlsConn) Read(p []byte) (int, error)  { return c.reader.Read(p) }
+func (c *tlsConn) Write(p []byte) (int, error) { return c.writer.Write(p) }
+func (c *tlsConn) Flush() error                { return c.writer.Flush() }
+func (c *tlsConn) Close() error                { return c.net.Close() }
+func (c *tlsConn) Addr() net.Addr              { return c.net.RemoteAddr() }
+
+func (c *tlsConn) Greeting() tarantool.Greeting         { return tarantool.Greeting{} }
+func (c *tlsConn) ProtocolInfo() tarantool.ProtocolInfo { return tarantool.ProtocolInfo{} }
+
+// deadlineConn applies per-operation IO timeout like the connector's net dialer does
+type deadlineConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (d *deadlineConn) Read(b []byte) (int, error) {
+	if d.timeout > 0 {
+		d.Conn.SetReadDeadline(time.Now().Add(d.timeout))
+	}
+	return d.Conn.Read(b)
+}
+
+func (d *deadlineConn) Write(b []byte) (int, error) {
+	if d.timeout > 0 {
+		d.Conn.SetWriteDeadline(time.Now().Add(d.timeout))
+	}
+	return d.Conn.Write(b)
+}
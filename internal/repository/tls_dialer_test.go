@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tarantool/go-iproto"
+	"github.com/tarantool/go-tarantool/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// serverFeatures are announced by the fake server in its IPROTO_ID response
+var serverFeatures = []iproto.Feature{
+	iproto.IPROTO_FEATURE_STREAMS,
+	iproto.IPROTO_FEATURE_TRANSACTIONS,
+	iproto.IPROTO_FEATURE_ERROR_EXTENSION,
+	iproto.IPROTO_FEATURE_WATCHERS,
+}
+
+// selfSigned returns a certificate for 127.0.0.1 and a pool trusting it
+func selfSigned(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "tarantool"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, roots
+}
+
+// serveIproto speaks enough of the binary protocol for a client to connect:
+// the greeting, IPROTO_ID announcing serverFeatures and authentication.
+// Methods of IPROTO_AUTH requests are sent to auths, keys of IPROTO_WATCH
+// requests to watched.
+func serveIproto(conn net.Conn, auths, watched chan<- string) {
+	defer conn.Close()
+	greeting := make([]byte, 128)
+	copy(greeting, "Tarantool 3.1.0 (Binary) 00000000-0000-0000-0000-000000000000")
+	greeting[63] = '\n'
+	copy(greeting[64:], "c2FsdHNhbHRzYWx0c2FsdHNhbHRzYWx0c2FsdHNhbHQ=")
+	greeting[127] = '\n'
+	if _, err := conn.Write(greeting); err != nil {
+		return
+	}
+
+	for {
+		var size [5]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		packet := make([]byte, binary.BigEndian.Uint32(size[1:]))
+		if _, err := io.ReadFull(conn, packet); err != nil {
+			return
+		}
+		dec := msgpack.NewDecoder(bytes.NewReader(packet))
+		var header map[iproto.Key]uint64
+		if err := dec.Decode(&header); err != nil {
+			return
+		}
+		if iproto.Type(header[iproto.IPROTO_REQUEST_TYPE]) == iproto.IPROTO_AUTH {
+			var req map[iproto.Key]interface{}
+			dec.Decode(&req)
+			if tuple, ok := req[iproto.IPROTO_TUPLE].([]interface{}); ok && len(tuple) > 0 {
+				method, _ := tuple[0].(string)
+				auths <- method
+			}
+		}
+		if iproto.Type(header[iproto.IPROTO_REQUEST_TYPE]) == iproto.IPROTO_WATCH {
+			// watch requests are answered with events, not responses
+			var req map[iproto.Key]interface{}
+			dec.Decode(&req)
+			key, _ := req[iproto.IPROTO_EVENT_KEY].(string)
+			watched <- key
+			continue
+		}
+
+		body := map[iproto.Key]interface{}{}
+		if iproto.Type(header[iproto.IPROTO_REQUEST_TYPE]) == iproto.IPROTO_ID {
+			body[iproto.IPROTO_VERSION] = 6
+			body[iproto.IPROTO_FEATURES] = serverFeatures
+		}
+		var resp bytes.Buffer
+		enc := msgpack.NewEncoder(&resp)
+		enc.Encode(map[iproto.Key]uint64{
+			iproto.IPROTO_REQUEST_TYPE: uint64(iproto.IPROTO_OK),
+			iproto.IPROTO_SYNC:         header[iproto.IPROTO_SYNC],
+		})
+		enc.Encode(body)
+
+		frame := []byte{0xce, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(frame[1:], uint32(resp.Len()))
+		if _, err := conn.Write(append(frame, resp.Bytes()...)); err != nil {
+			return
+		}
+	}
+}
+
+func TestTLSDialerNegotiatesFeatures(t *testing.T) {
+	cert, roots := selfSigned(t)
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	auths, watched := make(chan string, 1), make(chan string, 1)
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go serveIproto(conn, auths, watched)
+		}
+	}()
+
+	dialer := tlsDialer{
+		address:  lis.Addr().String(),
+		user:     "vault",
+		password: "secret",
+		auth:     tarantool.PapSha256Auth,
+		config:   &tls.Config{RootCAs: roots, ServerName: "127.0.0.1", MinVersion: tls.VersionTLS12},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := tarantool.Connect(ctx, dialer, tarantool.Opts{Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	info := conn.ProtocolInfo()
+	if info.Version != 6 || !slices.Contains(info.Features, iproto.IPROTO_FEATURE_WATCHERS) {
+		t.Errorf("negotiated %+v, want version 6 with watchers", info)
+	}
+	if !strings.HasPrefix(conn.Greeting.Version, "Tarantool 3.1.0") {
+		t.Errorf("greeting %q was not read", conn.Greeting.Version)
+	}
+	if method := <-auths; method != "pap-sha256" {
+		t.Errorf("authenticated with %q, want the configured pap-sha256", method)
+	}
+
+	// the connector only subscribes to box.shutdown when watchers were negotiated
+	select {
+	case key := <-watched:
+		if key != "box.shutdown" {
+			t.Errorf("watched %q", key)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("no watcher was registered over TLS")
+	}
+}
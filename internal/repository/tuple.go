@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vvjke314/vk-test-03-2025/internal/entities"
+)
+
+// vaultTuple is a tuple of vault space. Tuples written before keys could
+// expire have two fields, the third one is the expiry time in milliseconds
+// since the Unix epoch or null for keys that never expire, the fourth one
+// holds memcached flags or null for none.
+type vaultTuple entities.VaultItem
+
+func (t *vaultTuple) DecodeMsgpack(d *msgpack.Decoder) error {
+	n, err := d.DecodeArrayLen()
+	if err != nil {
+		return err
+	}
+	if n < 2 {
+		return fmt.Errorf("vault tuple has %d fields", n)
+	}
+	if t.Key, err = d.DecodeString(); err != nil {
+		return err
+	}
+	if t.Value, err = d.DecodeString(); err != nil {
+		return err
+	}
+	return decodeOptional((*entities.VaultItem)(t), d, n-2)
+}
+
+// versionedItem is a record returned by key_gets: key, value, version and
+// the optional fields of the tuple
+type versionedItem struct {
+	Item    entities.VaultItem
+	Version uint64
+}
+
+func (v *versionedItem) DecodeMsgpack(d *msgpack.Decoder) error {
+	n, err := d.DecodeArrayLen()
+	if err != nil {
+		return err
+	}
+	if n < 3 {
+		return fmt.Errorf("versioned record has %d fields", n)
+	}
+	if v.Item.Key, err = d.DecodeString(); err != nil {
+		return err
+	}
+	if v.Item.Value, err = d.DecodeString(); err != nil {
+		return err
+	}
+	if v.Version, err = d.DecodeUint64(); err != nil {
+		return err
+	}
+	return decodeOptional(&v.Item, d, n-3)
+}
+
+// decodeOptional decodes n fields following the value, expires_at and
+// flags which may be missing or null, and skips the rest
+func decodeOptional(item *entities.VaultItem, d *msgpack.Decoder, n int) error {
+	item.ExpiresAt, item.Flags = time.Time{}, 0
+	if n > 0 {
+		var ms *uint64
+		if err := d.Decode(&ms); err != nil {
+			return err
+		}
+		item.ExpiresAt = fromMillis(ms)
+	}
+	if n > 1 {
+		var flags *uint32
+		if err := d.Decode(&flags); err != nil {
+			return err
+		}
+		if flags != nil {
+			item.Flags = *flags
+		}
+	}
+	for i := 2; i < n; i++ {
+		if err := d.Skip(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// liveItems converts tuples to items leaving out the expired ones, which
+// the expiry fiber did not delete yet
+func liveItems(tuples []vaultTuple, now time.Time) []entities.VaultItem {
+	items := make([]entities.VaultItem, 0, len(tuples))
+	for _, t := range tuples {
+		if item := entities.VaultItem(t); !item.Expired(now) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// toMillis returns the expires_at field of t, nil when the key never expires
+func toMillis(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return uint64(max(t.UnixMilli(), 0))
+}
+
+// fromMillis converts the expires_at field back
+func fromMillis(ms *uint64) time.Time {
+	if ms == nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(int64(*ms))
+}
+
+// toFlags returns the flags field of an item, nil when it has none
+func toFlags(flags uint32) interface{} {
+	if flags == 0 {
+		return nil
+	}
+	return flags
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vvjke314/vk-test-03-2025/internal/entities"
+)
+
+func TestDecodeTuples(t *testing.T) {
+	at := time.UnixMilli(1700000000000)
+	tuples := []struct {
+		fields []interface{}
+		want   entities.VaultItem
+	}{
+		{[]interface{}{"a", "1"}, entities.VaultItem{Key: "a", Value: "1"}},
+		{[]interface{}{"a", "1", nil}, entities.VaultItem{Key: "a", Value: "1"}},
+		{[]interface{}{"a", "1", toMillis(at)}, entities.VaultItem{Key: "a", Value: "1", ExpiresAt: at}},
+		{[]interface{}{"a", "1", nil, 7}, entities.VaultItem{Key: "a", Value: "1", Flags: 7}},
+		{[]interface{}{"a", "1", toMillis(at), 7, "extra"}, entities.VaultItem{Key: "a", Value: "1", ExpiresAt: at, Flags: 7}},
+	}
+	for _, tt := range tuples {
+		b, err := msgpack.Marshal(tt.fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got vaultTuple
+		if err := msgpack.Unmarshal(b, &got); err != nil {
+			t.Errorf("decode %v: %v", tt.fields, err)
+			continue
+		}
+		if item := entities.VaultItem(got); !item.ExpiresAt.Equal(tt.want.ExpiresAt) ||
+			item.Key != tt.want.Key || item.Value != tt.want.Value || item.Flags != tt.want.Flags {
+			t.Errorf("decode %v = %+v, want %+v", tt.fields, item, tt.want)
+		}
+
+		// key_gets returns the version after the value
+		versioned := append([]interface{}{tt.fields[0], tt.fields[1], 5}, tt.fields[2:]...)
+		if b, err = msgpack.Marshal(versioned); err != nil {
+			t.Fatal(err)
+		}
+		var v versionedItem
+		if err := msgpack.Unmarshal(b, &v); err != nil || v.Version != 5 || v.Item.Flags != tt.want.Flags {
+			t.Errorf("decode %v = %+v, %v", versioned, v, err)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+// Package textvalue maps text values of the Redis and memcached front ends
+// to stored JSON values
+package textvalue
+
+import (
+	"bytes"
+	"encoding/json"
+	"unicode/utf8"
+
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"github.com/vvjke314/vk-test-03-2025/internal/i18n"
+)
+
+// Encode stores text as a JSON string, so it reads as text over HTTP and
+// gRPC as well. JSON strings can not hold arbitrary bytes, so binary values
+// are rejected rather than changed.
+func Encode(b []byte) (string, error) {
+	if !utf8.Valid(b) {
+		return "", custom_errors.NewValidationError("value", i18n.MsgValueNotUTF8)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(string(b)); err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSuffix(buf.Bytes(), []byte("\n"))), nil
+}
+
+// Decode returns the text of a JSON string value and other JSON values as
+// they are stored
+func Decode(value string) []byte {
+	var s string
+	if len(value) > 0 && value[0] == '"' && json.Unmarshal([]byte(value), &s) == nil {
+		return []byte(s)
+	}
+	return []byte(value)
+}
@@ -0,0 +1,38 @@
+package textvalue
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+)
+
+func TestEncode(t *testing.T) {
+	tests := map[string]string{
+		"":          `""`,
+		"hello":     `"hello"`,
+		`<a & "b">`: `"<a & \"b\">"`,
+		"строка\n":  `"строка\n"`,
+	}
+	for in, want := range tests {
+		got, err := Encode([]byte(in))
+		if err != nil || got != want {
+			t.Errorf("Encode(%q) = %s, %v, want %s", in, got, err, want)
+		}
+		if back := string(Decode(got)); back != in {
+			t.Errorf("Decode(%s) = %q", got, back)
+		}
+	}
+
+	if _, err := Encode([]byte{0xff}); !errors.Is(err, custom_errors.ErrValidation) {
+		t.Errorf("Encode of invalid UTF-8: %v", err)
+	}
+}
+
+func TestDecodeRawJSON(t *testing.T) {
+	for _, value := range []string{`{"a":1}`, `42`, `"unterminated`, `[1,2]`} {
+		if got := string(Decode(value)); got != value {
+			t.Errorf("Decode(%s) = %s", value, got)
+		}
+	}
+}
@@ -1,128 +1,311 @@
 package usecases
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
 	"github.com/vvjke314/vk-test-03-2025/internal/entities"
+	"github.com/vvjke314/vk-test-03-2025/internal/i18n"
+	"github.com/vvjke314/vk-test-03-2025/internal/tracing"
+	"github.com/vvjke314/vk-test-03-2025/internal/validation"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-// repository defines the interface for data access operations
+// repository defines the interface for data access operations.
+// Every operation is a single atomic call: Insert fails with
+// custom_errors.ErrKeyExists, Update, Expire, Delete and Get fail with
+// custom_errors.ErrKeyNotExists instead of requiring a prior existence check.
+// CompareAndSwap fails with custom_errors.ErrConflict when the version of the
+// key changed since GetVersioned. Dump reads all records as of one moment.
+// Writes store the expiry of the item, expired keys are missing.
 type repository interface {
-	Insert(entities.VaultItem) error
-	Update(key string, value string) error
-	Delete(key string) error
-	Get(key string) (entities.VaultItem, error)
-	KeyExists(key string) (bool, error)
+	Insert(ctx context.Context, item entities.VaultItem) error
+	Update(ctx context.Context, item entities.VaultItem) error
+	Expire(ctx context.Context, key string, at time.Time) error
+	Delete(ctx context.Context, key string) error
+	Get(ctx context.Context, key string) (entities.VaultItem, error)
+	List(ctx context.Context, prefix, after string, limit int) ([]entities.VaultItem, error)
+	GetVersioned(ctx context.Context, key string) (entities.VaultItem, uint64, error)
+	CompareAndSwap(ctx context.Context, item entities.VaultItem, version uint64) error
+	Import(ctx context.Context, items []entities.VaultItem, mode entities.ImportMode, dryRun bool) (entities.ImportResult, error)
+	Dump(ctx context.Context, fn func([]entities.VaultItem) error) error
+	Ping(ctx context.Context) error
 }
 
+const (
+	// DefaultListLimit is the page size of List when none is requested
+	DefaultListLimit = 100
+	// MaxListLimit bounds the page size of List
+	MaxListLimit = 1000
+	// MaxImportChunk bounds the number of items written by one Import
+	MaxImportChunk = 1000
+)
+
 // KeyValueUseCase implements business logic for key-value operations
 type KeyValueUseCase struct {
-	repo repository
+	repo  repository
+	rules validation.Rules
 }
 
 // NewKeyValueUseCase creates a new instance of KeyValueUseCase
-func NewKeyValueUseCase(r repository) *KeyValueUseCase {
+func NewKeyValueUseCase(r repository, rules validation.Rules) *KeyValueUseCase {
 	return &KeyValueUseCase{
-		repo: r,
+		repo:  r,
+		rules: rules,
 	}
 }
 
+// Rules returns key and value rules enforced by the use case
+func (uc *KeyValueUseCase) Rules() validation.Rules {
+	return uc.rules
+}
+
 // InsertValue adds a new key-value pair after validation
-func (uc *KeyValueUseCase) InsertValue(item entities.VaultItem) error {
-	if item.Key == "" {
-		return errors.New("key cannot be empty")
-	}
-	if item.Value == "" {
-		return errors.New("value cannot be empty")
-	}
+func (uc *KeyValueUseCase) InsertValue(ctx context.Context, item entities.VaultItem) (err error) {
+	ctx, span := tracing.Start(ctx, "KeyValueUseCase.InsertValue", attribute.String("vault.key", item.Key))
+	defer func() { tracing.End(span, err) }()
 
-	// Check if key exists
-	exists, err := uc.repo.KeyExists(item.Key)
-	if err != nil {
-		return custom_errors.ErrKeyNotExists
+	if err := uc.rules.Key(item.Key); err != nil {
+		return err
 	}
-	if exists {
-		return fmt.Errorf("key '%s' already exists", item.Key)
+	if err := uc.rules.Value(item.Value); err != nil {
+		return err
 	}
 
 	// Insert new record
-	if err := uc.repo.Insert(item); err != nil {
+	if err := uc.repo.Insert(ctx, item); err != nil {
 		return fmt.Errorf("failed to insert value: %w", err)
 	}
 
 	return nil
 }
 
-// UpdateValue modifies an existing key-value pair
-func (uc *KeyValueUseCase) UpdateValue(key, value string) error {
-	if key == "" {
-		return errors.New("key cannot be empty")
-	}
-	if value == "" {
-		return errors.New("value cannot be empty")
-	}
+// UpdateValue replaces value and expiry of an existing key
+func (uc *KeyValueUseCase) UpdateValue(ctx context.Context, item entities.VaultItem) (err error) {
+	ctx, span := tracing.Start(ctx, "KeyValueUseCase.UpdateValue", attribute.String("vault.key", item.Key))
+	defer func() { tracing.End(span, err) }()
 
-	// Check if key exists
-	exists, err := uc.repo.KeyExists(key)
-	if err != nil {
-		return fmt.Errorf("failed to check key existence: %w", err)
+	if err := uc.rules.Key(item.Key); err != nil {
+		return err
 	}
-	if !exists {
-		return custom_errors.NewKeyNotExistsError(key)
+	if err := uc.rules.Value(item.Value); err != nil {
+		return err
 	}
 
 	// Update the record
-	if err := uc.repo.Update(key, value); err != nil {
+	if err := uc.repo.Update(ctx, item); err != nil {
 		return fmt.Errorf("failed to update value: %w", err)
 	}
 
 	return nil
 }
 
-// DeleteRow removes a key-value pair by key
-func (uc *KeyValueUseCase) DeleteRow(key string) error {
-	if key == "" {
-		return errors.New("key cannot be empty")
+// PutValue creates a key or replaces its value and reports whether it was
+// created. A create or delete of another client between the update and the
+// insert is retried, so the last writer wins as with UpdateValue.
+func (uc *KeyValueUseCase) PutValue(ctx context.Context, item entities.VaultItem) (created bool, err error) {
+	ctx, span := tracing.Start(ctx, "KeyValueUseCase.PutValue", attribute.String("vault.key", item.Key))
+	defer func() { tracing.End(span, err) }()
+
+	if err := uc.rules.Key(item.Key); err != nil {
+		return false, err
+	}
+	if err := uc.rules.Value(item.Value); err != nil {
+		return false, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := uc.repo.Update(ctx, item)
+		if !errors.Is(err, custom_errors.ErrKeyNotExists) {
+			if err != nil {
+				return false, fmt.Errorf("failed to put value: %w", err)
+			}
+			return false, nil
+		}
+
+		err = uc.repo.Insert(ctx, item)
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, custom_errors.ErrKeyExists) || attempt == 2 {
+			return false, fmt.Errorf("failed to put value: %w", err)
+		}
+	}
+}
+
+// List returns up to limit key-value pairs ordered by key whose keys start
+// with prefix and follow after. Zero limit means DefaultListLimit.
+func (uc *KeyValueUseCase) List(ctx context.Context, prefix, after string, limit int) (_ []entities.VaultItem, err error) {
+	ctx, span := tracing.Start(ctx, "KeyValueUseCase.List", attribute.String("vault.prefix", prefix))
+	defer func() { tracing.End(span, err) }()
+
+	if limit == 0 {
+		limit = DefaultListLimit
+	}
+	if limit < 0 || limit > MaxListLimit {
+		return nil, custom_errors.NewFieldError("limit", i18n.MsgLimitRange,
+			i18n.Params{"max": strconv.Itoa(MaxListLimit)})
+	}
+	if uc.rules.MaxKeyLen > 0 && len(prefix) > uc.rules.MaxKeyLen {
+		return nil, custom_errors.NewFieldError("prefix", i18n.MsgKeyTooLong,
+			i18n.Params{"limit": strconv.Itoa(uc.rules.MaxKeyLen)})
 	}
 
-	// Check if key exists
-	exists, err := uc.repo.KeyExists(key)
+	items, err := uc.repo.List(ctx, prefix, after, limit)
 	if err != nil {
-		return fmt.Errorf("failed to check key existence: %w", err)
+		return nil, fmt.Errorf("failed to list values: %w", err)
 	}
-	if !exists {
-		return custom_errors.NewKeyNotExistsError(key)
+
+	return items, nil
+}
+
+// DeleteRow removes a key-value pair by key
+func (uc *KeyValueUseCase) DeleteRow(ctx context.Context, key string) (err error) {
+	ctx, span := tracing.Start(ctx, "KeyValueUseCase.DeleteRow", attribute.String("vault.key", key))
+	defer func() { tracing.End(span, err) }()
+
+	if err := uc.rules.LookupKey(key); err != nil {
+		return err
 	}
 
 	// Delete the record
-	if err := uc.repo.Delete(key); err != nil {
+	if err := uc.repo.Delete(ctx, key); err != nil {
 		return fmt.Errorf("failed to delete value: %w", err)
 	}
 
 	return nil
 }
 
-// Get retrieves a value by key
-func (uc *KeyValueUseCase) Get(key string) (entities.VaultItem, error) {
-	if key == "" {
-		return entities.VaultItem{}, errors.New("key cannot be empty")
+// Expire sets the time key expires at, zero time makes it persistent.
+// A time not in the future deletes the key, as in Redis.
+func (uc *KeyValueUseCase) Expire(ctx context.Context, key string, at time.Time) (err error) {
+	ctx, span := tracing.Start(ctx, "KeyValueUseCase.Expire", attribute.String("vault.key", key))
+	defer func() { tracing.End(span, err) }()
+
+	if err := uc.rules.LookupKey(key); err != nil {
+		return err
 	}
 
-	// Check if key exists
-	exists, err := uc.repo.KeyExists(key)
+	if !at.IsZero() && !at.After(time.Now()) {
+		err = uc.repo.Delete(ctx, key)
+	} else {
+		err = uc.repo.Expire(ctx, key, at)
+	}
 	if err != nil {
-		return entities.VaultItem{}, fmt.Errorf("failed to check key existence: %w", err)
+		return fmt.Errorf("failed to expire value: %w", err)
 	}
-	if !exists {
-		return entities.VaultItem{}, custom_errors.NewKeyNotExistsError(key)
+
+	return nil
+}
+
+// Get retrieves a value by key
+func (uc *KeyValueUseCase) Get(ctx context.Context, key string) (_ entities.VaultItem, err error) {
+	ctx, span := tracing.Start(ctx, "KeyValueUseCase.Get", attribute.String("vault.key", key))
+	defer func() { tracing.End(span, err) }()
+
+	if err := uc.rules.LookupKey(key); err != nil {
+		return entities.VaultItem{}, err
 	}
 
-	item, err := uc.repo.Get(key)
+	item, err := uc.repo.Get(ctx, key)
 	if err != nil {
 		return entities.VaultItem{}, fmt.Errorf("failed to get value: %w", err)
 	}
 
 	return item, nil
 }
+
+// GetVersioned retrieves a value by key with its version for CompareAndSwap
+func (uc *KeyValueUseCase) GetVersioned(ctx context.Context, key string) (_ entities.VaultItem, _ uint64, err error) {
+	ctx, span := tracing.Start(ctx, "KeyValueUseCase.GetVersioned", attribute.String("vault.key", key))
+	defer func() { tracing.End(span, err) }()
+
+	if err := uc.rules.LookupKey(key); err != nil {
+		return entities.VaultItem{}, 0, err
+	}
+
+	item, version, err := uc.repo.GetVersioned(ctx, key)
+	if err != nil {
+		return entities.VaultItem{}, 0, fmt.Errorf("failed to get value: %w", err)
+	}
+
+	return item, version, nil
+}
+
+// CompareAndSwap replaces the value of an existing key after validation
+// unless the key was written since its version was read
+func (uc *KeyValueUseCase) CompareAndSwap(ctx context.Context, item entities.VaultItem, version uint64) (err error) {
+	ctx, span := tracing.Start(ctx, "KeyValueUseCase.CompareAndSwap", attribute.String("vault.key", item.Key))
+	defer func() { tracing.End(span, err) }()
+
+	if err := uc.rules.Key(item.Key); err != nil {
+		return err
+	}
+	if err := uc.rules.Value(item.Value); err != nil {
+		return err
+	}
+
+	if err := uc.repo.CompareAndSwap(ctx, item, version); err != nil {
+		return fmt.Errorf("failed to swap value: %w", err)
+	}
+
+	return nil
+}
+
+// Import writes a chunk of items in one storage transaction. Existing keys
+// are replaced, skipped or, in fail-on-conflict mode, fail the whole chunk.
+// A dry run validates and counts without writing.
+func (uc *KeyValueUseCase) Import(ctx context.Context, items []entities.VaultItem, mode entities.ImportMode, dryRun bool) (_ entities.ImportResult, err error) {
+	ctx, span := tracing.Start(ctx, "KeyValueUseCase.Import",
+		attribute.Int("vault.items", len(items)), attribute.String("vault.import_mode", string(mode)))
+	defer func() { tracing.End(span, err) }()
+
+	if err := ValidImportMode(mode); err != nil {
+		return entities.ImportResult{}, err
+	}
+	if len(items) == 0 || len(items) > MaxImportChunk {
+		return entities.ImportResult{}, custom_errors.NewFieldError("items", i18n.MsgBatchSize,
+			i18n.Params{"max": strconv.Itoa(MaxImportChunk)})
+	}
+	for _, item := range items {
+		if err := uc.rules.Key(item.Key); err != nil {
+			return entities.ImportResult{}, err
+		}
+		if err := uc.rules.Value(item.Value); err != nil {
+			return entities.ImportResult{}, err
+		}
+	}
+
+	result, err := uc.repo.Import(ctx, items, mode, dryRun)
+	if err != nil {
+		return entities.ImportResult{}, fmt.Errorf("failed to import values: %w", err)
+	}
+
+	return result, nil
+}
+
+// Dump calls fn with pages of all stored items in key order, together the
+// pages are a consistent state of the storage
+func (uc *KeyValueUseCase) Dump(ctx context.Context, fn func([]entities.VaultItem) error) (err error) {
+	ctx, span := tracing.Start(ctx, "KeyValueUseCase.Dump")
+	defer func() { tracing.End(span, err) }()
+
+	if err := uc.repo.Dump(ctx, fn); err != nil {
+		return fmt.Errorf("failed to dump values: %w", err)
+	}
+
+	return nil
+}
+
+// ValidImportMode checks that mode is one of the import modes
+func ValidImportMode(mode entities.ImportMode) error {
+	switch mode {
+	case entities.ImportFailOnConflict, entities.ImportOverwrite, entities.ImportSkipExisting:
+		return nil
+	}
+	return custom_errors.NewValidationError("mode", i18n.MsgImportMode)
+}
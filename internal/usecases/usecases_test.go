@@ -0,0 +1,126 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"github.com/vvjke314/vk-test-03-2025/internal/entities"
+	"github.com/vvjke314/vk-test-03-2025/internal/repository/repotest"
+	"github.com/vvjke314/vk-test-03-2025/internal/validation"
+)
+
+func TestRules(t *testing.T) {
+	// keys stored before the grammar was tightened
+	repo := repotest.New(map[string]string{"Bad/Key": "1"})
+	uc := NewKeyValueUseCase(repo, validation.Rules{
+		MaxKeyLen:     8,
+		MaxValueBytes: 16,
+		KeyPattern:    regexp.MustCompile(`^[a-z0-9-]*$`),
+		KeyCharset:    "a-z0-9-",
+	})
+	ctx := context.Background()
+
+	tests := []struct {
+		key, value string
+		want       custom_errors.Code
+	}{
+		{"config-1", `{"a":1}`, ""},
+		{"", `1`, custom_errors.CodeValidation},
+		{"too-long-key", `1`, custom_errors.CodeValidation},
+		{"Bad/Key", `1`, custom_errors.CodeValidation},
+		{"config", "", custom_errors.CodeValidation},
+		{"config", `"` + strings.Repeat("x", 16) + `"`, custom_errors.CodeTooLarge},
+	}
+
+	for _, tt := range tests {
+		err := uc.InsertValue(ctx, entities.VaultItem{Key: tt.key, Value: tt.value})
+		if got := custom_errors.CodeOf(err); got != tt.want {
+			t.Errorf("InsertValue(%q, %q) code = %q, want %q (%v)", tt.key, tt.value, got, tt.want, err)
+		}
+	}
+
+	// the grammar binds writes only, stored keys stay addressable
+	if _, err := uc.Get(ctx, "Bad/Key"); err != nil {
+		t.Errorf("Get rejected key outside charset: %v", err)
+	}
+	if err := uc.DeleteRow(ctx, "Bad/Key"); err != nil {
+		t.Errorf("DeleteRow rejected key outside charset: %v", err)
+	}
+	if _, err := uc.Get(ctx, "too-long-key"); custom_errors.CodeOf(err) != custom_errors.CodeValidation {
+		t.Errorf("Get accepted long key: %v", err)
+	}
+	if err := uc.DeleteRow(ctx, ""); custom_errors.CodeOf(err) != custom_errors.CodeValidation {
+		t.Errorf("DeleteRow accepted empty key: %v", err)
+	}
+	if err := uc.UpdateValue(ctx, entities.VaultItem{Key: "config", Value: strings.Repeat("1", 17)}); custom_errors.CodeOf(err) != custom_errors.CodeTooLarge {
+		t.Errorf("UpdateValue accepted large value: %v", err)
+	}
+}
+
+func TestPutValue(t *testing.T) {
+	repo := repotest.New(nil)
+	uc := NewKeyValueUseCase(repo, validation.Rules{})
+	ctx := context.Background()
+
+	created, err := uc.PutValue(ctx, entities.VaultItem{Key: "a", Value: "1"})
+	if err != nil || !created {
+		t.Fatalf("first put: created %v, %v", created, err)
+	}
+	created, err = uc.PutValue(ctx, entities.VaultItem{Key: "a", Value: "2"})
+	if value, _ := repo.Value("a"); err != nil || created || value != "2" {
+		t.Errorf("second put: created %v, value %q, %v", created, value, err)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	repo := repotest.New(map[string]string{"a": "1"})
+	uc := NewKeyValueUseCase(repo, validation.Rules{})
+	ctx := context.Background()
+
+	at := time.Now().Add(time.Hour)
+	if err := uc.Expire(ctx, "a", at); err != nil {
+		t.Fatal(err)
+	}
+	if item, err := uc.Get(ctx, "a"); err != nil || !item.ExpiresAt.Equal(at) {
+		t.Errorf("expiry not stored: %+v, %v", item, err)
+	}
+	if err := uc.Expire(ctx, "a", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if item, _ := uc.Get(ctx, "a"); !item.ExpiresAt.IsZero() {
+		t.Errorf("expiry not removed: %+v", item)
+	}
+
+	// a time in the past deletes the key
+	if err := uc.Expire(ctx, "a", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := repo.Value("a"); ok {
+		t.Error("key expired in the past is kept")
+	}
+	if err := uc.Expire(ctx, "a", at); !errors.Is(err, custom_errors.ErrKeyNotExists) {
+		t.Errorf("Expire of missing key: %v", err)
+	}
+}
+
+func TestListLimit(t *testing.T) {
+	uc := NewKeyValueUseCase(repotest.New(nil), validation.Rules{MaxKeyLen: 4})
+	ctx := context.Background()
+
+	for _, limit := range []int{-1, MaxListLimit + 1} {
+		if _, err := uc.List(ctx, "", "", limit); custom_errors.CodeOf(err) != custom_errors.CodeValidation {
+			t.Errorf("List with limit %d: %v", limit, err)
+		}
+	}
+	if _, err := uc.List(ctx, "long-prefix", "", 0); custom_errors.CodeOf(err) != custom_errors.CodeValidation {
+		t.Errorf("List accepted prefix longer than a key: %v", err)
+	}
+	if _, err := uc.List(ctx, "a", "", 0); err != nil {
+		t.Errorf("List: %v", err)
+	}
+}
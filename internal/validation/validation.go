@@ -0,0 +1,86 @@
+package validation
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"github.com/vvjke314/vk-test-03-2025/internal/i18n"
+)
+
+// Rules bounds keys and values, zero fields disable the corresponding check.
+// Keys always follow the grammar: non-empty, no surrounding whitespace, no
+// control characters and only characters allowed unescaped in a URL path
+// segment, so that every key can be addressed as /kv/{key}.
+type Rules struct {
+	MaxKeyLen     int
+	MaxValueBytes int
+	KeyPattern    *regexp.Regexp // Pattern whole keys must match
+	KeyCharset    string         // Human readable description of KeyPattern
+}
+
+// Key checks a key being written against the grammar and limits
+func (r Rules) Key(key string) error {
+	switch {
+	case key == "":
+		return custom_errors.NewValidationError("key", i18n.MsgKeyEmpty)
+	case r.MaxKeyLen > 0 && len(key) > r.MaxKeyLen:
+		return custom_errors.NewFieldError("key", i18n.MsgKeyTooLong,
+			i18n.Params{"limit": strconv.Itoa(r.MaxKeyLen)})
+	case strings.TrimSpace(key) != key:
+		return custom_errors.NewValidationError("key", i18n.MsgKeyWhitespace)
+	case strings.IndexFunc(key, unicode.IsControl) >= 0:
+		return custom_errors.NewValidationError("key", i18n.MsgKeyControl)
+	case !urlSafe(key):
+		return custom_errors.NewValidationError("key", i18n.MsgKeyNotURLSafe)
+	case r.KeyPattern != nil && !r.KeyPattern.MatchString(key):
+		return custom_errors.NewFieldError("key", i18n.MsgKeyCharset,
+			i18n.Params{"charset": r.KeyCharset})
+	}
+	return nil
+}
+
+// LookupKey checks a key naming a record to read or delete. Only emptiness
+// and length are checked: the grammar binds keys being written, keys stored
+// before it was tightened stay readable and deletable.
+func (r Rules) LookupKey(key string) error {
+	switch {
+	case key == "":
+		return custom_errors.NewValidationError("key", i18n.MsgKeyEmpty)
+	case r.MaxKeyLen > 0 && len(key) > r.MaxKeyLen:
+		return custom_errors.NewFieldError("key", i18n.MsgKeyTooLong,
+			i18n.Params{"limit": strconv.Itoa(r.MaxKeyLen)})
+	}
+	return nil
+}
+
+// Value checks value against emptiness and size limits
+func (r Rules) Value(value string) error {
+	switch {
+	case value == "":
+		return custom_errors.NewValidationError("value", i18n.MsgValueEmpty)
+	case r.MaxValueBytes > 0 && len(value) > r.MaxValueBytes:
+		return custom_errors.NewTooLargeError("value", i18n.MsgValueTooLarge, int64(r.MaxValueBytes))
+	}
+	return nil
+}
+
+// urlSafe reports whether key is a path segment needing no escaping
+// (RFC 3986 pchar) that routers will not normalize away
+func urlSafe(key string) bool {
+	if key == "." || key == ".." {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("-._~!$&'()*+,;=:@", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package validation
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"github.com/vvjke314/vk-test-03-2025/internal/i18n"
+)
+
+func TestKey(t *testing.T) {
+	rules := Rules{
+		MaxKeyLen:  16,
+		KeyPattern: regexp.MustCompile(`^[A-Za-z0-9._~:@-]*$`),
+		KeyCharset: "A-Za-z0-9._~:@-",
+	}
+
+	tests := []struct {
+		key  string
+		want i18n.MessageID
+	}{
+		{"config:db-1", ""},
+		{"user@host", ""},
+		{"", i18n.MsgKeyEmpty},
+		{strings.Repeat("k", 17), i18n.MsgKeyTooLong},
+		{" key", i18n.MsgKeyWhitespace},
+		{"key\t", i18n.MsgKeyWhitespace},
+		{"a\x00b", i18n.MsgKeyControl},
+		{"a/b", i18n.MsgKeyNotURLSafe},
+		{"a%20b", i18n.MsgKeyNotURLSafe},
+		{"..", i18n.MsgKeyNotURLSafe},
+		{"ключ", i18n.MsgKeyNotURLSafe},
+		{"a+b", i18n.MsgKeyCharset},
+	}
+
+	for _, tt := range tests {
+		err := rules.Key(tt.key)
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("Key(%q) = %v, want nil", tt.key, err)
+			}
+			continue
+		}
+		e, ok := custom_errors.As(err)
+		if !ok || e.MsgID != tt.want || e.Field != "key" {
+			t.Errorf("Key(%q) = %#v, want message %q", tt.key, err, tt.want)
+		}
+	}
+}
+
+func TestLookupKey(t *testing.T) {
+	rules := Rules{
+		MaxKeyLen:  16,
+		KeyPattern: regexp.MustCompile(`^[a-z]*$`),
+		KeyCharset: "a-z",
+	}
+
+	// keys written under looser rules can still be addressed
+	for _, key := range []string{"key", "Old Key", "a/b", "ключ", "a\tb"} {
+		if err := rules.LookupKey(key); err != nil {
+			t.Errorf("LookupKey(%q) = %v", key, err)
+		}
+	}
+	for key, want := range map[string]i18n.MessageID{
+		"":                      i18n.MsgKeyEmpty,
+		strings.Repeat("k", 17): i18n.MsgKeyTooLong,
+	} {
+		if e, ok := custom_errors.As(rules.LookupKey(key)); !ok || e.MsgID != want {
+			t.Errorf("LookupKey(%q) message = %v, want %q", key, e, want)
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	rules := Rules{MaxValueBytes: 4}
+
+	if err := rules.Value("1234"); err != nil {
+		t.Errorf("Value at the limit: %v", err)
+	}
+	if code := custom_errors.CodeOf(rules.Value("")); code != custom_errors.CodeValidation {
+		t.Errorf("empty value code = %q", code)
+	}
+	if code := custom_errors.CodeOf(rules.Value("12345")); code != custom_errors.CodeTooLarge {
+		t.Errorf("large value code = %q", code)
+	}
+}
@@ -0,0 +1,105 @@
+// Package watch fans out notifications about changed keys to subscribers
+package watch
+
+import "sync"
+
+// Event tells that a key has changed, its new state must be read from the storage
+type Event struct {
+	Key   string
+	Reset bool // Events may have been missed, every watched key must be considered changed
+}
+
+// Hub delivers published changes to matching subscriptions. Publishing never
+// blocks: a subscriber falling behind gets its queue replaced by a Reset event.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+// NewHub creates a hub without subscriptions
+func NewHub() *Hub {
+	return &Hub{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscription receives events of watched keys
+type Subscription struct {
+	hub  *Hub
+	keys map[string]struct{} // nil watches every key
+	c    chan Event
+}
+
+// Subscribe watches keys, no keys watch every key. Up to buffer events are
+// queued before the subscription is reset.
+func (h *Hub) Subscribe(keys []string, buffer int) *Subscription {
+	s := &Subscription{hub: h, c: make(chan Event, max(buffer, 1))}
+	if len(keys) > 0 {
+		s.keys = make(map[string]struct{}, len(keys))
+		for _, key := range keys {
+			s.keys[key] = struct{}{}
+		}
+	}
+
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+// Events returns the channel of events, it is closed by Close
+func (s *Subscription) Events() <-chan Event {
+	return s.c
+}
+
+// Close stops delivery and closes the events channel
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	if _, ok := s.hub.subs[s]; ok {
+		delete(s.hub.subs, s)
+		close(s.c)
+	}
+}
+
+// Publish notifies subscriptions watching key
+func (h *Hub) Publish(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		if s.keys != nil {
+			if _, ok := s.keys[key]; !ok {
+				continue
+			}
+		}
+		select {
+		case s.c <- Event{Key: key}:
+		default:
+			s.reset()
+		}
+	}
+}
+
+// Reset notifies every subscription that events may have been missed
+func (h *Hub) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		s.reset()
+	}
+}
+
+// reset replaces queued events with a single Reset, they are all covered by
+// it. Publishers hold hub.mu, so the drained queue has room for it.
+func (s *Subscription) reset() {
+	for {
+		select {
+		case <-s.c:
+			continue
+		default:
+		}
+		break
+	}
+	select {
+	case s.c <- Event{Reset: true}:
+	default:
+	}
+}
@@ -0,0 +1,51 @@
+package watch
+
+import "testing"
+
+func TestHub(t *testing.T) {
+	h := NewHub()
+	one := h.Subscribe([]string{"a"}, 4)
+	all := h.Subscribe(nil, 4)
+	defer all.Close()
+
+	h.Publish("a")
+	h.Publish("b")
+
+	if ev := <-one.Events(); ev.Key != "a" || len(one.Events()) != 0 {
+		t.Errorf("subscription of a got %+v, %d more", ev, len(one.Events()))
+	}
+	if a, b := <-all.Events(), <-all.Events(); a.Key != "a" || b.Key != "b" {
+		t.Errorf("subscription of all keys got %+v %+v", a, b)
+	}
+
+	one.Close()
+	if _, ok := <-one.Events(); ok {
+		t.Errorf("events channel open after Close")
+	}
+	h.Publish("a") // must not send on the closed channel
+	one.Close()
+}
+
+func TestHubOverflow(t *testing.T) {
+	h := NewHub()
+	s := h.Subscribe(nil, 2)
+	defer s.Close()
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		h.Publish(key)
+	}
+
+	// the slow subscriber gets a reset instead of blocking publishers
+	var events []Event
+	for len(s.Events()) > 0 {
+		events = append(events, <-s.Events())
+	}
+	if len(events) == 0 || !events[0].Reset {
+		t.Errorf("events after overflow = %+v, want a reset first", events)
+	}
+
+	h.Reset()
+	if ev := <-s.Events(); !ev.Reset {
+		t.Errorf("Reset delivered %+v", ev)
+	}
+}
@@ -0,0 +1,172 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ImportMode decides what an import does with keys that already exist
+type ImportMode string
+
+const (
+	ImportFailOnConflict ImportMode = "fail-on-conflict"
+	ImportOverwrite      ImportMode = "overwrite"
+	ImportSkipExisting   ImportMode = "skip-existing"
+)
+
+// ImportOptions configures Import
+type ImportOptions struct {
+	Mode   ImportMode // ImportFailOnConflict when empty
+	DryRun bool       // Only validate and count, nothing is written
+}
+
+// ImportReport tells what an import did or, in a dry run, would do
+type ImportReport struct {
+	Mode         ImportMode `json:"mode"`
+	DryRun       bool       `json:"dry_run"`
+	Records      int        `json:"records"`
+	Created      int        `json:"created"`
+	Updated      int        `json:"updated"`
+	Skipped      int        `json:"skipped"`
+	Conflicts    int        `json:"conflicts"`
+	ConflictKeys []string   `json:"conflict_keys"`
+}
+
+// ImportError is returned when an import stops part way, the records
+// before the failed chunk stay written
+type ImportError struct {
+	Committed int   // Records written before the import stopped
+	Err       error // Cause, usually an *Error
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("import stopped after %d records: %v", e.Committed, e.Err)
+}
+
+func (e *ImportError) Unwrap() error {
+	return e.Err
+}
+
+// Record is a line of Export and Import
+type Record struct {
+	Key       string          `json:"key"`
+	Value     json.RawMessage `json:"value"`
+	ExpiresAt *time.Time      `json:"expires_at,omitempty"` // Nil when the key never expires
+	Flags     uint32          `json:"flags,omitempty"`      // Flags stored by memcached clients
+}
+
+// Export streams keys starting with prefix in key order, fn is called for
+// every record and stops the export by returning an error. The export is
+// made of one request, so it is neither retried nor bounded by WithTimeout.
+func (c *Client) Export(ctx context.Context, prefix string, fn func(Record) error) error {
+	path := "/_export"
+	if prefix != "" {
+		path += "?prefix=" + url.QueryEscape(prefix)
+	}
+	req, err := c.newRequest(ctx, http.MethodGet, path, nil, uuid.NewString())
+	if err != nil {
+		return err
+	}
+
+	// the transport asks for gzip and decompresses the response itself
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("GET /_export: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		data, _ := io.ReadAll(resp.Body)
+		return parseError(resp, data)
+	}
+
+	r := bufio.NewReader(resp.Body)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			// the server breaks the connection when the export fails midway
+			return fmt.Errorf("GET /_export: export interrupted: %w", err)
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var rec Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return fmt.Errorf("GET /_export: failed to decode record: %w", err)
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
+
+// Import loads JSON lines of Record objects from r, the format written by
+// Export. The body is streamed gzipped in one request, so the call
+// is neither retried nor bounded by WithTimeout. When the server stops the
+// import part way the error is an *ImportError.
+func (c *Client) Import(ctx context.Context, r io.Reader, opts ImportOptions) (*ImportReport, error) {
+	if opts.Mode == "" {
+		opts.Mode = ImportFailOnConflict
+	}
+	query := url.Values{"mode": {string(opts.Mode)}}
+	if opts.DryRun {
+		query.Set("dry_run", "true")
+	}
+	path := "/_import?" + query.Encode()
+
+	body, w := io.Pipe()
+	go func() {
+		gz := gzip.NewWriter(w)
+		_, err := io.Copy(gz, r)
+		if err == nil {
+			err = gz.Close()
+		}
+		w.CloseWithError(err)
+	}()
+	defer body.Close()
+
+	req, err := c.newRequest(ctx, http.MethodPost, path, body, uuid.NewString())
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Content-Encoding", "gzip")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("POST /_import: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("POST /_import: failed to read response: %w", err)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		err := error(parseError(resp, data))
+		if committed, convErr := strconv.Atoi(resp.Header.Get("X-Import-Committed")); convErr == nil {
+			err = &ImportError{Committed: committed, Err: err}
+		}
+		return nil, err
+	}
+
+	var report ImportReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("POST /_import: failed to decode response: %w", err)
+	}
+	return &report, nil
+}
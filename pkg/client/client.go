@@ -0,0 +1,315 @@
+// Package client is a Go client of the vault HTTP API described by /openapi.json
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Client calls the vault API. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	http       *http.Client
+	timeout    time.Duration
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	language   string
+	adminToken string
+	apiKey     string
+}
+
+// Option configures Client
+type Option func(*Client)
+
+// WithHTTPClient sets the underlying HTTP client, http.DefaultClient by default
+func WithHTTPClient(c *http.Client) Option {
+	return func(cl *Client) { cl.http = c }
+}
+
+// WithTimeout bounds every attempt of a call, 5s by default, zero disables
+func WithTimeout(d time.Duration) Option {
+	return func(cl *Client) { cl.timeout = d }
+}
+
+// WithRetries sets how many times a failed call is retried, 2 by default
+func WithRetries(n int) Option {
+	return func(cl *Client) { cl.retries = n }
+}
+
+// WithBackoff sets the first and the largest delay between retries,
+// the delay doubles with every attempt and is jittered
+func WithBackoff(base, max time.Duration) Option {
+	return func(cl *Client) { cl.backoff, cl.maxBackoff = base, max }
+}
+
+// WithLanguage sets Accept-Language of requests, i.e. language of error details
+func WithLanguage(lang string) Option {
+	return func(cl *Client) { cl.language = lang }
+}
+
+// WithAdminToken sets the bearer token sent to /admin and bulk routes
+func WithAdminToken(token string) Option {
+	return func(cl *Client) { cl.adminToken = token }
+}
+
+// WithAPIKey sets X-API-Key sent with every request. A server with the key
+// in RATELIMITAPIKEYS and RATELIMITKEY=apikey accounts the requests to the
+// budget of its client, proxies in front of the server may check it too.
+func WithAPIKey(key string) Option {
+	return func(cl *Client) { cl.apiKey = key }
+}
+
+// New creates a client of the server at baseURL, e.g. http://localhost:8080
+func New(baseURL string, opts ...Option) (*Client, error) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base url %q: scheme must be http or https", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    baseURL,
+		http:       http.DefaultClient,
+		timeout:    5 * time.Second,
+		retries:    2,
+		backoff:    100 * time.Millisecond,
+		maxBackoff: 2 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Get returns the raw JSON value of key
+func (c *Client) Get(ctx context.Context, key string) (json.RawMessage, error) {
+	var resp struct {
+		Value json.RawMessage `json:"value"`
+	}
+	if err := c.do(ctx, http.MethodGet, keyPath(key), nil, true, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Value, nil
+}
+
+// GetInto decodes the value of key into v
+func (c *Client) GetInto(ctx context.Context, key string, v interface{}) error {
+	raw, err := c.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("failed to decode value of %q: %w", key, err)
+	}
+	return nil
+}
+
+// Create stores a new key, value is encoded as JSON.
+// It fails with ErrAlreadyExists when the key is taken.
+func (c *Client) Create(ctx context.Context, key string, value interface{}) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode value: %w", err)
+	}
+	body := struct {
+		Key   string          `json:"key"`
+		Value json.RawMessage `json:"value"`
+	}{key, raw}
+	return c.do(ctx, http.MethodPost, "/kv", body, false, nil)
+}
+
+// Update replaces the value of an existing key, value is encoded as JSON.
+// It fails with ErrNotFound when the key does not exist.
+func (c *Client) Update(ctx context.Context, key string, value interface{}) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode value: %w", err)
+	}
+	body := struct {
+		Value json.RawMessage `json:"value"`
+	}{raw}
+	return c.do(ctx, http.MethodPut, keyPath(key), body, true, nil)
+}
+
+// Delete removes key. It fails with ErrNotFound when the key does not exist.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	return c.do(ctx, http.MethodDelete, keyPath(key), nil, true, nil)
+}
+
+// Ready reports whether the server and its dependencies are ready
+func (c *Client) Ready(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/readyz", nil, true, nil)
+}
+
+// LogLevel returns the current log level of the server
+func (c *Client) LogLevel(ctx context.Context) (string, error) {
+	var resp struct {
+		Level string `json:"level"`
+	}
+	err := c.do(ctx, http.MethodGet, "/admin/loglevel", nil, true, &resp)
+	return resp.Level, err
+}
+
+// SetLogLevel changes the log level of the server
+func (c *Client) SetLogLevel(ctx context.Context, level string) error {
+	body := struct {
+		Level string `json:"level"`
+	}{level}
+	return c.do(ctx, http.MethodPut, "/admin/loglevel", body, true, nil)
+}
+
+func keyPath(key string) string {
+	return "/kv/" + url.PathEscape(key)
+}
+
+// do sends the request, retrying failures the server may not have processed.
+// Requests that are not idempotent are retried only when the server rejected
+// them before processing: rate limited or storage unavailable.
+func (c *Client) do(ctx context.Context, method, path string, in interface{}, idempotent bool, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+	// the same id over all attempts correlates them in server logs
+	requestID := uuid.NewString()
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retryAfter time.Duration
+		err = c.attempt(ctx, method, path, body, requestID, out)
+
+		var apiErr *Error
+		switch {
+		case err == nil:
+			return nil
+		case ctx.Err() != nil:
+			return err
+		case errors.As(err, &apiErr):
+			if !retryable(apiErr.Code, idempotent) {
+				return err
+			}
+			retryAfter = apiErr.RetryAfter
+		case !idempotent:
+			// the request may have reached the server
+			return err
+		}
+
+		if attempt >= c.retries {
+			return err
+		}
+		if err := sleep(ctx, max(retryAfter, c.delay(attempt))); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, method, path string, body []byte, requestID string, out interface{}) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := c.newRequest(ctx, method, path, reader, requestID)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s %s: failed to read response: %w", method, path, err)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return parseError(resp, data)
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("%s %s: failed to decode response: %w", method, path, err)
+		}
+	}
+	return nil
+}
+
+// newRequest builds a request with the headers common to all calls
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader, requestID string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json, application/problem+json")
+	req.Header.Set("X-Request-ID", requestID)
+	if c.language != "" {
+		req.Header.Set("Accept-Language", c.language)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.adminToken != "" && (strings.HasPrefix(path, "/admin/") || strings.HasPrefix(path, "/_")) {
+		req.Header.Set("Authorization", "Bearer "+c.adminToken)
+	}
+	return req, nil
+}
+
+func retryable(code Code, idempotent bool) bool {
+	switch code {
+	case CodeRateLimited, CodeUnavailable:
+		return true
+	case CodeTimeout:
+		return idempotent
+	}
+	return false
+}
+
+// delay returns the jittered exponential backoff before retry number attempt+1
+func (c *Client) delay(attempt int) time.Duration {
+	d := c.backoff << attempt
+	if d <= 0 || d > c.maxBackoff {
+		d = c.maxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,205 @@
+package client
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	c, err := New(srv.URL, WithBackoff(time.Millisecond, 5*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func writeProblem(w http.ResponseWriter, status int, body string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	io.WriteString(w, body)
+}
+
+func TestGet(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/kv/user:1" {
+			writeProblem(w, 404, `{"status":404,"code":"not_found","detail":"key 'x' does not exist","key":"x"}`)
+			return
+		}
+		io.WriteString(w, `{"key":"user:1","value":{"name":"a"}}`)
+	})
+
+	var v struct{ Name string }
+	if err := c.GetInto(context.Background(), "user:1", &v); err != nil || v.Name != "a" {
+		t.Fatalf("GetInto = %+v, %v", v, err)
+	}
+
+	_, err := c.Get(context.Background(), "x")
+	var apiErr *Error
+	if !errors.Is(err, ErrNotFound) || !errors.As(err, &apiErr) || apiErr.Key != "x" || apiErr.Status != 404 {
+		t.Errorf("missing key error = %#v", err)
+	}
+	if errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("not_found matched already_exists")
+	}
+}
+
+func TestFieldErrors(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]json.RawMessage
+		json.NewDecoder(r.Body).Decode(&body)
+		if string(body["value"]) != `[1,2]` || string(body["key"]) != `" bad"` {
+			t.Errorf("request body = %v", body)
+		}
+		writeProblem(w, 400, `{"status":400,"code":"validation","errors":[{"field":"key","code":"validation","detail":"bad key"}]}`)
+	})
+
+	err := c.Create(context.Background(), " bad", []int{1, 2})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || !errors.Is(err, ErrValidation) || len(apiErr.Errors) != 1 || apiErr.Errors[0].Field != "key" {
+		t.Errorf("Create error = %#v", err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			writeProblem(w, 503, `{"status":503,"code":"unavailable"}`)
+			return
+		}
+		io.WriteString(w, `{"status":"ok"}`)
+	})
+
+	if err := c.Delete(context.Background(), "a"); err != nil || calls.Load() != 3 {
+		t.Fatalf("Delete after two failures = %v, calls %d", err, calls.Load())
+	}
+
+	// retries are exhausted
+	calls.Store(-10)
+	if err := c.Delete(context.Background(), "a"); !errors.Is(err, ErrUnavailable) || calls.Load() != -7 {
+		t.Errorf("Delete = %v, calls %d", err, calls.Load())
+	}
+}
+
+func TestNoRetryOfCreateTimeout(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		writeProblem(w, 504, `{"status":504,"code":"timeout"}`)
+	})
+
+	// the insert may have happened, repeating it would turn success into a conflict
+	if err := c.Create(context.Background(), "a", 1); !errors.Is(err, ErrTimeout) || calls.Load() != 1 {
+		t.Errorf("Create = %v, calls %d", err, calls.Load())
+	}
+	calls.Store(0)
+	if err := c.Update(context.Background(), "a", 1); !errors.Is(err, ErrTimeout) || calls.Load() != 3 {
+		t.Errorf("Update = %v, calls %d", err, calls.Load())
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	var first time.Time
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			first = time.Now()
+			w.Header().Set("Retry-After", "1")
+			writeProblem(w, 429, `{"status":429,"code":"rate_limited"}`)
+			return
+		}
+		if time.Since(first) < time.Second {
+			t.Errorf("retried after %v, Retry-After is 1s", time.Since(first))
+		}
+		io.WriteString(w, `{"status":"ok"}`)
+	})
+
+	if err := c.Create(context.Background(), "a", 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNonProblemError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		io.WriteString(w, "<html>bad gateway</html>")
+	})
+	c.retries = 0
+
+	err := c.Ready(context.Background())
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("502 from proxy = %v", err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_export" || r.URL.Query().Get("prefix") != "user:" {
+			writeProblem(w, 404, `{"status":404,"code":"not_found"}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		io.WriteString(w, `{"key":"user:1","value":{"name":"a"}}`+"\n"+
+			`{"key":"user:2","value":2,"expires_at":"2030-01-02T03:04:05Z","flags":3}`+"\n")
+	})
+
+	var got []string
+	err := c.Export(context.Background(), "user:", func(r Record) error {
+		got = append(got, r.Key+"="+string(r.Value))
+		if r.ExpiresAt != nil {
+			got = append(got, r.ExpiresAt.Format(time.RFC3339), strconv.Itoa(int(r.Flags)))
+		}
+		return nil
+	})
+	if err != nil || strings.Join(got, " ") != `user:1={"name":"a"} user:2=2 2030-01-02T03:04:05Z 3` {
+		t.Errorf("Export = %v, %v", got, err)
+	}
+}
+
+func TestImport(t *testing.T) {
+	var auth atomic.Value
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		auth.Store(r.Header.Get("Authorization"))
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			writeProblem(w, 400, `{"status":400,"code":"validation"}`)
+			return
+		}
+		body, _ := io.ReadAll(zr)
+		if lines := strings.Count(string(body), "\n"); lines != 2 || r.URL.Query().Get("mode") != "skip-existing" {
+			w.Header().Set("X-Import-Committed", "500")
+			writeProblem(w, 409, `{"status":409,"code":"already_exists","key":"a"}`)
+			return
+		}
+		io.WriteString(w, `{"mode":"skip-existing","dry_run":true,"records":2,"created":1,"skipped":1,"conflict_keys":[]}`)
+	})
+	WithAdminToken("secret")(c)
+
+	body := `{"key":"a","value":1}` + "\n" + `{"key":"b","value":2}` + "\n"
+	report, err := c.Import(context.Background(), strings.NewReader(body), ImportOptions{Mode: ImportSkipExisting, DryRun: true})
+	if err != nil || report.Created != 1 || report.Skipped != 1 || !report.DryRun {
+		t.Fatalf("Import = %+v, %v", report, err)
+	}
+	if auth.Load() != "Bearer secret" {
+		t.Errorf("admin token not sent: %v", auth.Load())
+	}
+
+	_, err = c.Import(context.Background(), strings.NewReader(body), ImportOptions{})
+	var importErr *ImportError
+	if !errors.As(err, &importErr) || importErr.Committed != 500 || !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("stopped import error = %#v", err)
+	}
+}
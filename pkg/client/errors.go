@@ -0,0 +1,113 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Code is the stable machine-readable error class returned by the server,
+// it mirrors codes of internal/custom_errors
+type Code string
+
+const (
+	CodeValidation      Code = "validation"
+	CodeUnauthenticated Code = "unauthenticated"
+	CodeNotFound        Code = "not_found"
+	CodeAlreadyExists   Code = "already_exists"
+	CodeConflict        Code = "conflict"
+	CodeTooLarge        Code = "too_large"
+	CodeRateLimited     Code = "rate_limited"
+	CodeCanceled        Code = "canceled"
+	CodeTimeout         Code = "timeout"
+	CodeUnavailable     Code = "unavailable"
+	CodeInternal        Code = "internal"
+)
+
+// Sentinel errors for use with errors.Is, they match any Error of the same code
+var (
+	ErrValidation      = &Error{Code: CodeValidation}
+	ErrUnauthenticated = &Error{Code: CodeUnauthenticated}
+	ErrNotFound        = &Error{Code: CodeNotFound}
+	ErrAlreadyExists   = &Error{Code: CodeAlreadyExists}
+	ErrConflict        = &Error{Code: CodeConflict}
+	ErrTooLarge        = &Error{Code: CodeTooLarge}
+	ErrRateLimited     = &Error{Code: CodeRateLimited}
+	ErrCanceled        = &Error{Code: CodeCanceled}
+	ErrTimeout         = &Error{Code: CodeTimeout}
+	ErrUnavailable     = &Error{Code: CodeUnavailable}
+	ErrInternal        = &Error{Code: CodeInternal}
+)
+
+// FieldError describes one invalid request field
+type FieldError struct {
+	Field  string `json:"field"`
+	Code   Code   `json:"code"`
+	Detail string `json:"detail"`
+}
+
+// Error is an error answered by the server as application/problem+json
+type Error struct {
+	Status     int           `json:"status"`
+	Code       Code          `json:"code"`
+	Title      string        `json:"title"`
+	Detail     string        `json:"detail"`
+	Key        string        `json:"key"`
+	Field      string        `json:"field"`
+	RequestID  string        `json:"request_id"`
+	Errors     []FieldError  `json:"errors"`
+	RetryAfter time.Duration `json:"-"` // Delay asked by Retry-After, if any
+}
+
+func (e *Error) Error() string {
+	msg := e.Detail
+	if msg == "" {
+		msg = e.Title
+	}
+	return fmt.Sprintf("vault: %s (%d %s)", msg, e.Status, e.Code)
+}
+
+// Is matches sentinel errors of the same code
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Status == 0 && t.Code == e.Code
+}
+
+// parseError builds Error from a failed response. Bodies that are not
+// problem+json, e.g. from a proxy, are classified by status only.
+func parseError(resp *http.Response, body []byte) *Error {
+	e := &Error{}
+	if err := json.Unmarshal(body, e); err != nil || e.Code == "" {
+		e = &Error{Code: codeOf(resp.StatusCode), Title: http.StatusText(resp.StatusCode)}
+	}
+	e.Status = resp.StatusCode
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s > 0 {
+		e.RetryAfter = time.Duration(s) * time.Second
+	}
+	return e
+}
+
+// codeOf guesses the code of a response without problem body
+func codeOf(status int) Code {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeValidation
+	case http.StatusUnauthorized:
+		return CodeUnauthenticated
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return CodeTooLarge
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case http.StatusGatewayTimeout:
+		return CodeTimeout
+	}
+	return CodeInternal
+}
@@ -0,0 +1,110 @@
+package grpcserver
+
+import (
+	"context"
+
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"github.com/vvjke314/vk-test-03-2025/internal/i18n"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// errorDomain is the ErrorInfo domain of every error of the service
+const errorDomain = "vault"
+
+// GRPCCode maps error code to gRPC status code
+func GRPCCode(code custom_errors.Code) codes.Code {
+	switch code {
+	case custom_errors.CodeValidation, custom_errors.CodeTooLarge:
+		return codes.InvalidArgument
+	case custom_errors.CodeUnauthenticated:
+		return codes.Unauthenticated
+	case custom_errors.CodeNotFound:
+		return codes.NotFound
+	case custom_errors.CodeAlreadyExists:
+		return codes.AlreadyExists
+	case custom_errors.CodeConflict:
+		return codes.Aborted
+	case custom_errors.CodeRateLimited:
+		return codes.ResourceExhausted
+	case custom_errors.CodeCanceled:
+		return codes.Canceled
+	case custom_errors.CodeTimeout:
+		return codes.DeadlineExceeded
+	case custom_errors.CodeUnavailable:
+		return codes.Unavailable
+	}
+	return codes.Internal
+}
+
+// language picks the message language from accept-language metadata
+func language(ctx context.Context) i18n.Lang {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var header string
+	if values := md.Get("accept-language"); len(values) > 0 {
+		header = values[0]
+	}
+	return i18n.FromAcceptLanguage(header)
+}
+
+// toStatus is the gRPC counterpart of handlers.WriteError. The status carries
+// the message localized from accept-language metadata, ErrorInfo with the
+// error code as reason and BadRequest listing invalid fields. The error is
+// logged at a level matching its class.
+func toStatus(ctx context.Context, l logger.Logger, err error) *status.Status {
+	if st, ok := status.FromError(err); ok {
+		return st
+	}
+
+	code := custom_errors.CodeOf(err)
+	grpcCode := GRPCCode(code)
+	lang := language(ctx)
+
+	message := code.Title(lang)
+	info := &errdetails.ErrorInfo{Reason: string(code), Domain: errorDomain}
+	var badRequest *errdetails.BadRequest
+	// internal details never leave the service
+	if e, ok := custom_errors.As(err); ok && code != custom_errors.CodeInternal {
+		message = e.Localize(lang)
+		if e.Key != "" {
+			info.Metadata = map[string]string{"key": e.Key}
+		}
+		details := e.Details
+		if len(details) == 0 && e.Field != "" {
+			details = []*custom_errors.Error{e}
+		}
+		for _, d := range details {
+			if badRequest == nil {
+				badRequest = &errdetails.BadRequest{}
+			}
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       d.Field,
+				Description: d.Localize(lang),
+			})
+		}
+	}
+
+	log := logger.FromContext(ctx, l).With(logger.F("code", string(code)), logger.F("grpc_code", grpcCode.String()))
+	switch {
+	case serverError(grpcCode):
+		log.Error("request failed", logger.Err(err))
+	case code == custom_errors.CodeNotFound || code == custom_errors.CodeAlreadyExists:
+		log.Info("request rejected", logger.Err(err))
+	default:
+		log.Warn("request rejected", logger.Err(err))
+	}
+
+	details := []protoadapt.MessageV1{info}
+	if badRequest != nil {
+		details = append(details, badRequest)
+	}
+	st := status.New(grpcCode, message)
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		return withDetails
+	}
+	return st
+}
@@ -0,0 +1,217 @@
+package grpcserver
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vvjke314/vk-test-03-2025/config"
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+	"github.com/vvjke314/vk-test-03-2025/internal/metrics"
+	"github.com/vvjke314/vk-test-03-2025/internal/ratelimit"
+	"github.com/vvjke314/vk-test-03-2025/internal/tracing"
+	"github.com/vvjke314/vk-test-03-2025/pkg/vaultpb"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// interceptor wraps handling of unary and streaming calls alike,
+// next runs the rest of the chain with the given context
+type interceptor func(ctx context.Context, method string, next func(context.Context) error) error
+
+// unary adapts ic to unary calls
+func unary(ic interceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var resp interface{}
+		err := ic(ctx, info.FullMethod, func(ctx context.Context) (err error) {
+			resp, err = handler(ctx, req)
+			return err
+		})
+		return resp, err
+	}
+}
+
+// stream adapts ic to streaming calls
+func stream(ic interceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return ic(ss.Context(), info.FullMethod, func(ctx context.Context) error {
+			return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		})
+	}
+}
+
+// serverStream replaces the context of a stream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// requestIDInterceptor takes x-request-id metadata from the client or
+// generates one, echoes it in the response header and stores it in the
+// context for log correlation
+func requestIDInterceptor(ctx context.Context, method string, next func(context.Context) error) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var id string
+	if values := md.Get("x-request-id"); len(values) > 0 {
+		id = values[0]
+	}
+	if !logger.ValidRequestID(id) {
+		id = uuid.NewString()
+	}
+
+	grpc.SetHeader(ctx, metadata.Pairs("x-request-id", id))
+	return next(logger.ContextWithRequestID(ctx, id))
+}
+
+// tracingInterceptor continues the trace from traceparent metadata or starts
+// a new one
+func tracingInterceptor(ctx context.Context, method string, next func(context.Context) error) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	header := http.Header{}
+	for name, values := range md {
+		for _, value := range values {
+			header.Add(name, value)
+		}
+	}
+
+	service, name := splitMethod(method)
+	ctx, span := tracing.StartServer(ctx, header, service+"/"+name,
+		attribute.String("rpc.system", "grpc"),
+		attribute.String("rpc.service", service),
+		attribute.String("rpc.method", name),
+	)
+	defer span.End()
+
+	err := next(ctx)
+
+	code := status.Code(err)
+	span.SetAttributes(attribute.Int("rpc.grpc.status_code", int(code)))
+	if serverError(code) {
+		span.SetStatus(otelcodes.Error, code.String())
+	}
+	return err
+}
+
+// metricsInterceptor writes an access log record for every call and records
+// its count and latency labeled by method
+func metricsInterceptor(l logger.Logger) interceptor {
+	return func(ctx context.Context, method string, next func(context.Context) error) error {
+		start := time.Now()
+
+		err := next(ctx)
+
+		code := status.Code(err)
+		duration := time.Since(start)
+		metrics.ObserveGRPC(method, code.String(), duration)
+		logger.FromContext(ctx, l).Info("grpc request",
+			logger.F("method", method),
+			logger.F("code", code.String()),
+			logger.F("duration_ms", duration.Milliseconds()),
+		)
+		return err
+	}
+}
+
+// errorInterceptor converts errors returned by the service into statuses
+func errorInterceptor(l logger.Logger) interceptor {
+	return func(ctx context.Context, method string, next func(context.Context) error) error {
+		if err := next(ctx); err != nil {
+			return toStatus(ctx, l, err).Err()
+		}
+		return nil
+	}
+}
+
+// timeoutInterceptor bounds unary calls by the timeouts of the matching
+// /kv routes, a shorter client deadline takes precedence. List uses the
+// Get timeout and Batch the longest one.
+func timeoutInterceptor(t config.RouteTimeouts) grpc.UnaryServerInterceptor {
+	timeouts := map[string]time.Duration{
+		vaultpb.Vault_Get_FullMethodName:    t.Get,
+		vaultpb.Vault_List_FullMethodName:   t.Get,
+		vaultpb.Vault_Put_FullMethodName:    t.Update,
+		vaultpb.Vault_Create_FullMethodName: t.Create,
+		vaultpb.Vault_Delete_FullMethodName: t.Delete,
+		vaultpb.Vault_Batch_FullMethodName:  max(t.Get, t.Create, t.Update, t.Delete),
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if timeout := timeouts[info.FullMethod]; timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return handler(ctx, req)
+	}
+}
+
+// methodOps tells how calls are accounted by the limits, Batch accounts
+// each of its operations instead
+var methodOps = map[string]ratelimit.Op{
+	vaultpb.Vault_Get_FullMethodName:    ratelimit.Get,
+	vaultpb.Vault_List_FullMethodName:   ratelimit.Get,
+	vaultpb.Vault_Watch_FullMethodName:  ratelimit.Get,
+	vaultpb.Vault_Put_FullMethodName:    ratelimit.Update,
+	vaultpb.Vault_Create_FullMethodName: ratelimit.Create,
+	vaultpb.Vault_Delete_FullMethodName: ratelimit.Delete,
+}
+
+// limitInterceptor applies the budgets shared with the other front ends,
+// clients are told apart by address. Watch only takes a token, holding a
+// concurrency slot for the life of the stream would starve short calls.
+func limitInterceptor(limits *ratelimit.Limits) interceptor {
+	return func(ctx context.Context, method string, next func(context.Context) error) error {
+		op, ok := methodOps[method]
+		if !ok {
+			return next(ctx)
+		}
+		if method == vaultpb.Vault_Watch_FullMethodName {
+			if d := limits.Allow(peerKey(ctx), op); !d.Allowed {
+				return custom_errors.ErrRateLimited
+			}
+			return next(ctx)
+		}
+		release, err := limits.Take(peerKey(ctx), op)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return next(ctx)
+	}
+}
+
+// peerKey identifies the client of a call for the limits
+func peerKey(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ratelimit.IPKey("")
+	}
+	return ratelimit.AddrKey(p.Addr)
+}
+
+// splitMethod splits /package.Service/Method into its parts
+func splitMethod(method string) (service, name string) {
+	service, name = path.Split(method)
+	return strings.Trim(service, "/"), name
+}
+
+// serverError tells whether code is the server's fault
+func serverError(code codes.Code) bool {
+	switch code {
+	case codes.Internal, codes.Unknown, codes.Unavailable, codes.DeadlineExceeded, codes.DataLoss, codes.Unimplemented:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+// Package grpcserver serves the vault.v1.Vault gRPC service next to the HTTP API
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/vvjke314/vk-test-03-2025/config"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+	"github.com/vvjke314/vk-test-03-2025/internal/ratelimit"
+	"github.com/vvjke314/vk-test-03-2025/internal/usecases"
+	"github.com/vvjke314/vk-test-03-2025/internal/watch"
+	"github.com/vvjke314/vk-test-03-2025/pkg/vaultpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// Options groups dependencies and settings of the gRPC server
+type Options struct {
+	UseCase  *usecases.KeyValueUseCase
+	Hub      *watch.Hub // Source of Watch events, nil makes Watch unavailable
+	Logger   logger.Logger
+	Timeouts config.RouteTimeouts
+	Limits   *ratelimit.Limits // Budgets shared with the other front ends, nil limits nothing
+	MaxRecv  int               // Largest accepted request message in bytes, zero keeps the gRPC default
+}
+
+// Server is a gRPC server with the Vault, health and reflection services
+type Server struct {
+	grpc   *grpc.Server
+	health *health.Server
+
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// New creates a server reporting NOT_SERVING until SetServing(true)
+func New(opts Options) *Server {
+	serverOpts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			unary(requestIDInterceptor),
+			unary(tracingInterceptor),
+			unary(metricsInterceptor(opts.Logger)),
+			unary(errorInterceptor(opts.Logger)),
+			unary(limitInterceptor(opts.Limits)),
+			timeoutInterceptor(opts.Timeouts),
+		),
+		grpc.ChainStreamInterceptor(
+			stream(requestIDInterceptor),
+			stream(tracingInterceptor),
+			stream(metricsInterceptor(opts.Logger)),
+			stream(errorInterceptor(opts.Logger)),
+			stream(limitInterceptor(opts.Limits)),
+		),
+	}
+	if opts.MaxRecv > 0 {
+		serverOpts = append(serverOpts, grpc.MaxRecvMsgSize(opts.MaxRecv))
+	}
+
+	s := &Server{
+		grpc:   grpc.NewServer(serverOpts...),
+		health: health.NewServer(),
+		done:   make(chan struct{}),
+	}
+	vaultpb.RegisterVaultServer(s.grpc, &vaultService{
+		uc:      opts.UseCase,
+		limits:  opts.Limits,
+		hub:     opts.Hub,
+		logger:  opts.Logger,
+		timeout: opts.Timeouts.Get,
+		done:    s.done,
+	})
+	healthpb.RegisterHealthServer(s.grpc, s.health)
+	reflection.Register(s.grpc)
+
+	s.SetServing(false)
+	return s
+}
+
+// Serve accepts connections on lis until Shutdown
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpc.Serve(lis)
+}
+
+// SetServing sets the status reported by the health service
+func (s *Server) SetServing(serving bool) {
+	st := healthpb.HealthCheckResponse_NOT_SERVING
+	if serving {
+		st = healthpb.HealthCheckResponse_SERVING
+	}
+	s.health.SetServingStatus("", st)
+	s.health.SetServingStatus(vaultpb.Vault_ServiceDesc.ServiceName, st)
+}
+
+// Shutdown ends Watch streams and waits for other calls to finish.
+// Calls still running when ctx is done are canceled.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.done) })
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.grpc.Stop()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,363 @@
+package grpcserver
+
+import (
+	"context"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vvjke314/vk-test-03-2025/config"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+	"github.com/vvjke314/vk-test-03-2025/internal/ratelimit"
+	"github.com/vvjke314/vk-test-03-2025/internal/repository/repotest"
+	"github.com/vvjke314/vk-test-03-2025/internal/usecases"
+	"github.com/vvjke314/vk-test-03-2025/internal/validation"
+	"github.com/vvjke314/vk-test-03-2025/internal/watch"
+	"github.com/vvjke314/vk-test-03-2025/pkg/vaultpb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testOptions serves an empty repository with the rules and timeouts of the tests
+func testOptions(hub *watch.Hub) Options {
+	uc := usecases.NewKeyValueUseCase(repotest.New(nil), validation.Rules{
+		MaxKeyLen:     256,
+		MaxValueBytes: 1 << 20,
+		KeyPattern:    regexp.MustCompile(`^[A-Za-z0-9._~:@-]*$`),
+		KeyCharset:    "A-Za-z0-9._~:@-",
+	})
+	timeout := 3 * time.Second
+	return Options{
+		UseCase:  uc,
+		Hub:      hub,
+		Logger:   logger.NewWriterLogger(io.Discard),
+		Timeouts: config.RouteTimeouts{Get: timeout, Create: timeout, Update: timeout, Delete: timeout},
+		MaxRecv:  1<<20 + 64<<10,
+	}
+}
+
+// startServer serves the Vault service over an in-memory listener
+func startServer(t *testing.T, hub *watch.Hub) (*Server, *grpc.ClientConn) {
+	return serve(t, testOptions(hub))
+}
+
+// serve starts a server on an in-memory listener, it is shut down with the test
+func serve(t *testing.T, opts Options) (*Server, *grpc.ClientConn) {
+	t.Helper()
+	srv := New(opts)
+	srv.SetServing(true)
+
+	lis := bufconn.Listen(1 << 20)
+	go srv.Serve(lis)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	})
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return srv, conn
+}
+
+// reason returns ErrorInfo reason of a status error
+func reason(err error) string {
+	for _, d := range status.Convert(err).Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+	return ""
+}
+
+func TestCRUD(t *testing.T) {
+	_, conn := startServer(t, nil)
+	c := vaultpb.NewVaultClient(conn)
+	ctx := context.Background()
+
+	if _, err := c.Create(ctx, &vaultpb.CreateRequest{Key: "a", Value: `{"x":1}`}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	_, err := c.Create(ctx, &vaultpb.CreateRequest{Key: "a", Value: `1`})
+	if status.Code(err) != codes.AlreadyExists || reason(err) != "already_exists" {
+		t.Errorf("duplicate create: %v", err)
+	}
+
+	var header metadata.MD
+	callCtx := metadata.AppendToOutgoingContext(ctx, "x-request-id", "req-1")
+	kv, err := c.Get(callCtx, &vaultpb.GetRequest{Key: "a"}, grpc.Header(&header))
+	if err != nil || kv.Value != `{"x":1}` {
+		t.Errorf("get = %v, %v", kv, err)
+	}
+	if got := header.Get("x-request-id"); len(got) != 1 || got[0] != "req-1" {
+		t.Errorf("x-request-id = %v", got)
+	}
+
+	put, err := c.Put(ctx, &vaultpb.PutRequest{Key: "a", Value: `[2]`})
+	if err != nil || put.Created {
+		t.Errorf("put existing = %v, %v", put, err)
+	}
+	put, err = c.Put(ctx, &vaultpb.PutRequest{Key: "b", Value: `true`})
+	if err != nil || !put.Created {
+		t.Errorf("put new = %v, %v", put, err)
+	}
+
+	if _, err := c.Delete(ctx, &vaultpb.DeleteRequest{Key: "a"}); err != nil {
+		t.Errorf("delete: %v", err)
+	}
+	ruCtx := metadata.AppendToOutgoingContext(ctx, "accept-language", "ru")
+	_, err = c.Get(ruCtx, &vaultpb.GetRequest{Key: "a"})
+	if status.Code(err) != codes.NotFound || reason(err) != "not_found" || status.Convert(err).Message() != "ключ 'a' не существует" {
+		t.Errorf("get deleted: %v", err)
+	}
+}
+
+func TestValidationDetails(t *testing.T) {
+	_, conn := startServer(t, nil)
+	c := vaultpb.NewVaultClient(conn)
+
+	_, err := c.Create(context.Background(), &vaultpb.CreateRequest{Key: "", Value: "nope"})
+	if status.Code(err) != codes.InvalidArgument || reason(err) != "validation" {
+		t.Fatalf("create = %v", err)
+	}
+	var fields []string
+	for _, d := range status.Convert(err).Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, v := range br.FieldViolations {
+				fields = append(fields, v.Field)
+			}
+		}
+	}
+	if strings.Join(fields, ",") != "key,value" {
+		t.Errorf("field violations = %v", fields)
+	}
+
+	_, err = c.List(context.Background(), &vaultpb.ListRequest{PageToken: "%%"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("bad page token: %v", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	_, conn := startServer(t, nil)
+	c := vaultpb.NewVaultClient(conn)
+	ctx := context.Background()
+
+	for _, key := range []string{"user:3", "user:1", "other", "user:2"} {
+		if _, err := c.Create(ctx, &vaultpb.CreateRequest{Key: key, Value: `1`}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var keys []string
+	req := &vaultpb.ListRequest{Prefix: "user:", PageSize: 2}
+	for pages := 0; ; pages++ {
+		resp, err := c.List(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range resp.Items {
+			keys = append(keys, item.Key)
+		}
+		if resp.NextPageToken == "" || pages > 3 {
+			break
+		}
+		req.PageToken = resp.NextPageToken
+	}
+	if strings.Join(keys, " ") != "user:1 user:2 user:3" {
+		t.Errorf("listed %v", keys)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	_, conn := startServer(t, nil)
+	c := vaultpb.NewVaultClient(conn)
+
+	resp, err := c.Batch(context.Background(), &vaultpb.BatchRequest{Operations: []*vaultpb.Operation{
+		{Op: &vaultpb.Operation_Create{Create: &vaultpb.CreateRequest{Key: "a", Value: `1`}}},
+		{Op: &vaultpb.Operation_Create{Create: &vaultpb.CreateRequest{Key: "a", Value: `2`}}},
+		{Op: &vaultpb.Operation_Get{Get: &vaultpb.GetRequest{Key: "a"}}},
+		{},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := resp.Results
+	if len(r) != 4 || r[0].GetCreate() == nil || r[2].GetItem().GetValue() != `1` {
+		t.Fatalf("results = %v", r)
+	}
+	if e := r[1].GetError(); e.GetReason() != "already_exists" || codes.Code(e.GetCode()) != codes.AlreadyExists {
+		t.Errorf("duplicate create result = %v", r[1])
+	}
+	if r[3].GetError().GetReason() != "validation" {
+		t.Errorf("empty operation result = %v", r[3])
+	}
+
+	_, err = c.Batch(context.Background(), &vaultpb.BatchRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("empty batch: %v", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	opts := testOptions(nil)
+	opts.Limits = ratelimit.New(config.RateLimits{Write: config.RateLimit{Rate: 1, Burst: 1}})
+	_, conn := serve(t, opts)
+	c := vaultpb.NewVaultClient(conn)
+	ctx := context.Background()
+
+	if _, err := c.Create(ctx, &vaultpb.CreateRequest{Key: "a", Value: `1`}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Put(ctx, &vaultpb.PutRequest{Key: "a", Value: `2`}); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("write beyond the budget: %v", err)
+	}
+	if _, err := c.Get(ctx, &vaultpb.GetRequest{Key: "a"}); err != nil {
+		t.Errorf("read shares the write budget: %v", err)
+	}
+
+	// each batch operation is accounted on its own
+	resp, err := c.Batch(ctx, &vaultpb.BatchRequest{Operations: []*vaultpb.Operation{
+		{Op: &vaultpb.Operation_Get{Get: &vaultpb.GetRequest{Key: "a"}}},
+		{Op: &vaultpb.Operation_Delete{Delete: &vaultpb.DeleteRequest{Key: "a"}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := resp.Results; r[0].GetItem() == nil || r[1].GetError().GetReason() != "rate_limited" {
+		t.Errorf("results = %v", r)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	hub := watch.NewHub()
+	srv, conn := startServer(t, hub)
+	c := vaultpb.NewVaultClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := c.Create(ctx, &vaultpb.CreateRequest{Key: "a", Value: `1`}); err != nil {
+		t.Fatal(err)
+	}
+	stream, err := c.Watch(ctx, &vaultpb.WatchRequest{Keys: []string{"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := func() string {
+		t.Helper()
+		ev, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("recv: %v", err)
+		}
+		return ev.Type.String() + " " + ev.Key + " " + ev.Value
+	}
+
+	if got := next() + "; " + next(); got != "TYPE_SNAPSHOT a 1; TYPE_DELETE b " {
+		t.Errorf("snapshot = %q", got)
+	}
+
+	if _, err := c.Put(ctx, &vaultpb.PutRequest{Key: "b", Value: `2`}); err != nil {
+		t.Fatal(err)
+	}
+	hub.Publish("b")
+	hub.Publish("c")
+	if got := next(); got != "TYPE_PUT b 2" {
+		t.Errorf("change = %q", got)
+	}
+
+	hub.Reset()
+	if got := next() + "; " + next(); got != "TYPE_SNAPSHOT a 1; TYPE_SNAPSHOT b 2" {
+		t.Errorf("snapshot after reset = %q", got)
+	}
+
+	// shutdown ends streams instead of waiting for clients to cancel them
+	shutdownCtx, stop := context.WithTimeout(context.Background(), time.Second)
+	defer stop()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.Unavailable {
+		t.Errorf("recv after shutdown: %v", err)
+	}
+}
+
+func TestWatchWithoutHub(t *testing.T) {
+	_, conn := startServer(t, nil)
+	stream, err := vaultpb.NewVaultClient(conn).Watch(context.Background(), &vaultpb.WatchRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("watch without change events: %v", err)
+	}
+}
+
+func TestHealthAndReflection(t *testing.T) {
+	srv, conn := startServer(t, nil)
+	ctx := context.Background()
+
+	health := healthpb.NewHealthClient(conn)
+	check := func() healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := health.Check(ctx, &healthpb.HealthCheckRequest{Service: vaultpb.Vault_ServiceDesc.ServiceName})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Status
+	}
+	if st := check(); st != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("health = %v", st)
+	}
+	srv.SetServing(false)
+	if st := check(); st != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("health after SetServing(false) = %v", st)
+	}
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var services []string
+	for _, s := range resp.GetListServicesResponse().GetService() {
+		services = append(services, s.Name)
+	}
+	if !strings.Contains(strings.Join(services, " "), "vault.v1.Vault") {
+		t.Errorf("reflection services = %v", services)
+	}
+
+	// the descriptor is served too, so tools like grpcurl can build requests
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: "vault.v1.Vault"},
+	})
+	if err == nil {
+		resp, err = stream.Recv()
+	}
+	if err != nil || len(resp.GetFileDescriptorResponse().GetFileDescriptorProto()) == 0 {
+		t.Errorf("file containing vault.v1.Vault: %v, %v", resp, err)
+	}
+}
@@ -0,0 +1,270 @@
+package grpcserver
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"github.com/vvjke314/vk-test-03-2025/internal/entities"
+	"github.com/vvjke314/vk-test-03-2025/internal/i18n"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+	"github.com/vvjke314/vk-test-03-2025/internal/ratelimit"
+	"github.com/vvjke314/vk-test-03-2025/internal/usecases"
+	"github.com/vvjke314/vk-test-03-2025/internal/validation"
+	"github.com/vvjke314/vk-test-03-2025/internal/watch"
+	"github.com/vvjke314/vk-test-03-2025/pkg/vaultpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// MaxBatchSize bounds the number of operations in a Batch call
+	MaxBatchSize = 100
+
+	// watchBuffer is the number of changes queued for a slow Watch client
+	// before it gets a reset
+	watchBuffer = 256
+)
+
+// vaultService implements vaultpb.VaultServer on top of the use case
+type vaultService struct {
+	vaultpb.UnimplementedVaultServer
+
+	uc      *usecases.KeyValueUseCase
+	limits  *ratelimit.Limits
+	hub     *watch.Hub
+	logger  logger.Logger
+	timeout time.Duration // Deadline of storage reads made by Watch
+	done    <-chan struct{}
+}
+
+// Get returns the value of a key
+func (s *vaultService) Get(ctx context.Context, req *vaultpb.GetRequest) (*vaultpb.KeyValue, error) {
+	item, err := s.uc.Get(ctx, req.Key)
+	if err != nil {
+		return nil, err
+	}
+	return &vaultpb.KeyValue{Key: item.Key, Value: item.Value}, nil
+}
+
+// Put creates a key or replaces its value
+func (s *vaultService) Put(ctx context.Context, req *vaultpb.PutRequest) (*vaultpb.PutResponse, error) {
+	rules := s.uc.Rules()
+	if err := custom_errors.NewValidationErrors(rules.Key(req.Key), checkValue(rules, req.Value)); err != nil {
+		return nil, err
+	}
+
+	created, err := s.uc.PutValue(ctx, entities.VaultItem{Key: req.Key, Value: req.Value})
+	if err != nil {
+		return nil, err
+	}
+	return &vaultpb.PutResponse{Created: created}, nil
+}
+
+// Create stores a new key
+func (s *vaultService) Create(ctx context.Context, req *vaultpb.CreateRequest) (*vaultpb.CreateResponse, error) {
+	rules := s.uc.Rules()
+	if err := custom_errors.NewValidationErrors(rules.Key(req.Key), checkValue(rules, req.Value)); err != nil {
+		return nil, err
+	}
+
+	if err := s.uc.InsertValue(ctx, entities.VaultItem{Key: req.Key, Value: req.Value}); err != nil {
+		return nil, err
+	}
+	return &vaultpb.CreateResponse{}, nil
+}
+
+// Delete removes a key
+func (s *vaultService) Delete(ctx context.Context, req *vaultpb.DeleteRequest) (*vaultpb.DeleteResponse, error) {
+	if err := s.uc.DeleteRow(ctx, req.Key); err != nil {
+		return nil, err
+	}
+	return &vaultpb.DeleteResponse{}, nil
+}
+
+// List returns a page of keys starting with the prefix. The page token is
+// the last key of the previous page.
+func (s *vaultService) List(ctx context.Context, req *vaultpb.ListRequest) (*vaultpb.ListResponse, error) {
+	if req.PageSize < 0 || req.PageSize > usecases.MaxListLimit {
+		return nil, custom_errors.NewFieldError("page_size", i18n.MsgLimitRange,
+			i18n.Params{"max": strconv.Itoa(usecases.MaxListLimit)})
+	}
+	after, err := base64.RawURLEncoding.DecodeString(req.PageToken)
+	if err != nil {
+		return nil, custom_errors.NewValidationError("page_token", i18n.MsgPageToken)
+	}
+
+	limit := int(req.PageSize)
+	if limit == 0 {
+		limit = usecases.DefaultListLimit
+	}
+	items, err := s.uc.List(ctx, req.Prefix, string(after), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &vaultpb.ListResponse{Items: make([]*vaultpb.KeyValue, len(items))}
+	for i, item := range items {
+		resp.Items[i] = &vaultpb.KeyValue{Key: item.Key, Value: item.Value}
+	}
+	// a full page may be followed by more keys
+	if len(items) == limit {
+		resp.NextPageToken = base64.RawURLEncoding.EncodeToString([]byte(items[len(items)-1].Key))
+	}
+	return resp, nil
+}
+
+// Batch runs operations in order, a failed operation does not stop the rest
+func (s *vaultService) Batch(ctx context.Context, req *vaultpb.BatchRequest) (*vaultpb.BatchResponse, error) {
+	if len(req.Operations) == 0 || len(req.Operations) > MaxBatchSize {
+		return nil, custom_errors.NewFieldError("operations", i18n.MsgBatchSize,
+			i18n.Params{"max": strconv.Itoa(MaxBatchSize)})
+	}
+
+	resp := &vaultpb.BatchResponse{Results: make([]*vaultpb.Result, len(req.Operations))}
+	for i, op := range req.Operations {
+		result, err := s.run(ctx, op)
+		if err != nil {
+			st := toStatus(ctx, s.logger, err)
+			result = &vaultpb.Result{Result: &vaultpb.Result_Error{Error: &vaultpb.Error{
+				Code:    int32(st.Code()),
+				Reason:  string(custom_errors.CodeOf(err)),
+				Message: st.Message(),
+			}}}
+		}
+		resp.Results[i] = result
+	}
+	return resp, nil
+}
+
+// run performs a single batch operation, accounted like the call it stands for
+func (s *vaultService) run(ctx context.Context, op *vaultpb.Operation) (*vaultpb.Result, error) {
+	release, err := s.limits.Take(peerKey(ctx), batchOp(op))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	switch op := op.GetOp().(type) {
+	case *vaultpb.Operation_Get:
+		item, err := s.Get(ctx, op.Get)
+		return &vaultpb.Result{Result: &vaultpb.Result_Item{Item: item}}, err
+	case *vaultpb.Operation_Put:
+		put, err := s.Put(ctx, op.Put)
+		return &vaultpb.Result{Result: &vaultpb.Result_Put{Put: put}}, err
+	case *vaultpb.Operation_Create:
+		created, err := s.Create(ctx, op.Create)
+		return &vaultpb.Result{Result: &vaultpb.Result_Create{Create: created}}, err
+	case *vaultpb.Operation_Delete:
+		deleted, err := s.Delete(ctx, op.Delete)
+		return &vaultpb.Result{Result: &vaultpb.Result_Delete{Delete: deleted}}, err
+	}
+	return nil, custom_errors.NewValidationError("op", i18n.MsgOperationEmpty)
+}
+
+// batchOp tells how a batch operation is accounted by the limits
+func batchOp(op *vaultpb.Operation) ratelimit.Op {
+	switch op.GetOp().(type) {
+	case *vaultpb.Operation_Get:
+		return ratelimit.Get
+	case *vaultpb.Operation_Put:
+		return ratelimit.Update
+	case *vaultpb.Operation_Create:
+		return ratelimit.Create
+	case *vaultpb.Operation_Delete:
+		return ratelimit.Delete
+	}
+	return ratelimit.None
+}
+
+// Watch sends the current state of watched keys and then their changes.
+// It subscribes before reading the snapshot, so a change made in between is
+// sent twice rather than lost.
+func (s *vaultService) Watch(req *vaultpb.WatchRequest, stream grpc.ServerStreamingServer[vaultpb.WatchEvent]) error {
+	if s.hub == nil {
+		return status.Error(codes.Unavailable, "change events are not available")
+	}
+	rules := s.uc.Rules()
+	errs := make([]error, len(req.Keys))
+	for i, key := range req.Keys {
+		errs[i] = rules.LookupKey(key)
+	}
+	if err := custom_errors.NewValidationErrors(errs...); err != nil {
+		return err
+	}
+
+	sub := s.hub.Subscribe(req.Keys, watchBuffer)
+	defer sub.Close()
+
+	ctx := stream.Context()
+	if err := s.sendSnapshot(ctx, stream, req.Keys); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.done:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case ev := <-sub.Events():
+			var err error
+			switch {
+			case !ev.Reset:
+				err = s.sendChange(ctx, stream, ev.Key, vaultpb.WatchEvent_TYPE_PUT)
+			case len(req.Keys) == 0:
+				err = stream.Send(&vaultpb.WatchEvent{Type: vaultpb.WatchEvent_TYPE_RESET})
+			default:
+				err = s.sendSnapshot(ctx, stream, req.Keys)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// sendSnapshot sends the current state of keys
+func (s *vaultService) sendSnapshot(ctx context.Context, stream grpc.ServerStreamingServer[vaultpb.WatchEvent], keys []string) error {
+	for _, key := range keys {
+		if err := s.sendChange(ctx, stream, key, vaultpb.WatchEvent_TYPE_SNAPSHOT); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendChange reads key and sends it as an event of typ, or as TYPE_DELETE
+// when the key does not exist
+func (s *vaultService) sendChange(ctx context.Context, stream grpc.ServerStreamingServer[vaultpb.WatchEvent], key string, typ vaultpb.WatchEvent_Type) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	ev := &vaultpb.WatchEvent{Type: typ, Key: key}
+	item, err := s.uc.Get(ctx, key)
+	switch {
+	case errors.Is(err, custom_errors.ErrKeyNotExists):
+		ev.Type = vaultpb.WatchEvent_TYPE_DELETE
+	case err != nil:
+		return err
+	default:
+		ev.Value = item.Value
+	}
+	return stream.Send(ev)
+}
+
+// checkValue validates a JSON encoded value field
+func checkValue(rules validation.Rules, value string) error {
+	switch {
+	case value == "":
+		return custom_errors.NewValidationError("value", i18n.MsgFieldRequired)
+	case !json.Valid([]byte(value)):
+		return custom_errors.NewValidationError("value", i18n.MsgValueInvalidJSON)
+	}
+	return rules.Value(value)
+}
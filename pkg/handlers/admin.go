@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"github.com/vvjke314/vk-test-03-2025/internal/i18n"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+)
+
+// LevelController is implemented by loggers whose level can change at runtime
+type LevelController interface {
+	SetLevel(level logger.Level)
+	Level() logger.Level
+}
+
+// AdminHandler serves operational endpoints under /admin
+type AdminHandler struct {
+	levels LevelController
+	logger logger.Logger
+}
+
+func NewAdminHandler(levels LevelController, l logger.Logger) *AdminHandler {
+	return &AdminHandler{
+		levels: levels,
+		logger: l,
+	}
+}
+
+// GetLogLevelHandler handles GET /admin/loglevel
+func (h *AdminHandler) GetLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"level": h.levels.Level().String()})
+}
+
+// SetLogLevelHandler handles PUT /admin/loglevel
+func (h *AdminHandler) SetLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.logger)
+
+	var req struct {
+		Level string `json:"level"`
+	}
+	if err := decodeBody(r, &req); err != nil {
+		WriteError(w, r, h.logger, err)
+		return
+	}
+
+	level, err := logger.ParseLevel(req.Level)
+	if err != nil || req.Level == "" {
+		WriteError(w, r, h.logger, custom_errors.NewValidationError("level", i18n.MsgLevelInvalid))
+		return
+	}
+
+	previous := h.levels.Level()
+	h.levels.SetLevel(level)
+	log.Warn("log level changed", logger.F("from", previous.String()), logger.F("to", level.String()))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"level": level.String()})
+}
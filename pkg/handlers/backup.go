@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vvjke314/vk-test-03-2025/internal/backup"
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"github.com/vvjke314/vk-test-03-2025/internal/entities"
+	"github.com/vvjke314/vk-test-03-2025/internal/i18n"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+)
+
+// BackupHandler serves archives of the vault under /admin/backups
+type BackupHandler struct {
+	store   *backup.Store
+	logger  logger.Logger
+	timeout time.Duration
+}
+
+// NewBackupHandler bounds every operation by timeout, zero means no deadline
+func NewBackupHandler(store *backup.Store, l logger.Logger, timeout time.Duration) *BackupHandler {
+	return &BackupHandler{
+		store:   store,
+		logger:  l,
+		timeout: timeout,
+	}
+}
+
+// CreateHandler handles POST /admin/backups, it dumps the vault as of one
+// moment into a new archive
+func (h *BackupHandler) CreateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.context(r)
+	defer cancel()
+
+	info, err := h.store.Create(ctx)
+	if err != nil {
+		WriteError(w, r, h.logger, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(info)
+}
+
+// ListHandler handles GET /admin/backups
+func (h *BackupHandler) ListHandler(w http.ResponseWriter, r *http.Request) {
+	infos, err := h.store.List()
+	if err != nil {
+		WriteError(w, r, h.logger, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string][]backup.Info{"backups": infos})
+}
+
+// VerifyHandler handles GET /admin/backups/{name}, it reads the whole
+// archive and answers with its record count and checksum
+func (h *BackupHandler) VerifyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.context(r)
+	defer cancel()
+
+	info, err := h.store.Verify(ctx, chi.URLParam(r, "name"))
+	if err != nil {
+		WriteError(w, r, h.logger, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(info)
+}
+
+// RestoreHandler handles POST /admin/backups/{name}/restore. Query parameter
+// mode decides what happens to existing keys as in import, dry_run=true only
+// verifies and reports. X-Restore-Committed of an error response tells how
+// many records were written before the restore stopped.
+func (h *BackupHandler) RestoreHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts := backup.RestoreOptions{Mode: entities.ImportMode(query.Get("mode"))}
+	if s := query.Get("dry_run"); s != "" {
+		var err error
+		if opts.DryRun, err = strconv.ParseBool(s); err != nil {
+			WriteError(w, r, h.logger, custom_errors.NewValidationError("dry_run", i18n.MsgQueryBool))
+			return
+		}
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+
+	report, err := h.store.Restore(ctx, chi.URLParam(r, "name"), opts)
+	if err != nil {
+		w.Header().Set("X-Restore-Committed", strconv.Itoa(report.Committed))
+		WriteError(w, r, h.logger, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+// context bounds an operation by the backup timeout
+func (h *BackupHandler) context(r *http.Request) (context.Context, context.CancelFunc) {
+	if h.timeout > 0 {
+		return context.WithTimeout(r.Context(), h.timeout)
+	}
+	return context.WithCancel(r.Context())
+}
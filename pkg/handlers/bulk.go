@@ -0,0 +1,411 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"github.com/vvjke314/vk-test-03-2025/internal/entities"
+	"github.com/vvjke314/vk-test-03-2025/internal/i18n"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+	"github.com/vvjke314/vk-test-03-2025/internal/usecases"
+)
+
+// importChunk is the number of records written to the storage at once
+const importChunk = 500
+
+// maxConflictKeys bounds the conflicting keys listed in an import report
+const maxConflictKeys = 100
+
+// BulkOptions configures BulkHandler
+type BulkOptions struct {
+	PageTimeout  time.Duration // Deadline of reading one page of an export
+	ChunkTimeout time.Duration // Deadline of writing one chunk of an import
+	MaxRecord    int64         // Largest import line in bytes, zero means unlimited
+}
+
+// BulkHandler streams the whole vault out and in as JSON lines
+type BulkHandler struct {
+	uc     *usecases.KeyValueUseCase
+	logger logger.Logger
+	opts   BulkOptions
+}
+
+func NewBulkHandler(uc *usecases.KeyValueUseCase, l logger.Logger, opts BulkOptions) *BulkHandler {
+	return &BulkHandler{
+		uc:     uc,
+		logger: l,
+		opts:   opts,
+	}
+}
+
+// record is a line of export and import, expires_at and flags are left out
+// for keys that have none
+type record struct {
+	Key       *string         `json:"key"`
+	Value     json.RawMessage `json:"value"`
+	ExpiresAt *string         `json:"expires_at,omitempty"` // RFC 3339
+	Flags     uint32          `json:"flags,omitempty"`
+}
+
+// newRecord converts an item to its export line
+func newRecord(item *entities.VaultItem) record {
+	rec := record{Key: &item.Key, Value: json.RawMessage(item.Value), Flags: item.Flags}
+	if !item.ExpiresAt.IsZero() {
+		at := item.ExpiresAt.UTC().Format(time.RFC3339Nano)
+		rec.ExpiresAt = &at
+	}
+	return rec
+}
+
+// ImportReport tells what an import did or, in a dry run, would do
+type ImportReport struct {
+	Mode         entities.ImportMode `json:"mode"`
+	DryRun       bool                `json:"dry_run"`
+	Records      int                 `json:"records"`
+	Created      int                 `json:"created"`
+	Updated      int                 `json:"updated"`
+	Skipped      int                 `json:"skipped"`
+	Conflicts    int                 `json:"conflicts"`
+	ConflictKeys []string            `json:"conflict_keys"`
+}
+
+// ExportHandler handles GET /_export, it streams keys starting with the
+// prefix query parameter in key order, gzipped when the client accepts it.
+// Keys are read page by page, writes made meanwhile may or may not show up.
+func (h *BulkHandler) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	prefix := r.URL.Query().Get("prefix")
+	log := logger.FromContext(r.Context(), h.logger).With(logger.F("prefix", prefix))
+	log.Debug("request to export keys")
+
+	// the first page is read before the headers, so its errors get a response
+	page, err := h.page(r.Context(), prefix, "")
+	if err != nil {
+		WriteError(w, r, h.logger, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Add("Vary", "Accept-Encoding")
+	out := io.Writer(w)
+	if acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		out = gz
+	}
+	bw := bufio.NewWriter(out)
+	flusher, _ := w.(http.Flusher)
+
+	count := 0
+	for {
+		for _, item := range page {
+			line, err := json.Marshal(newRecord(&item))
+			if err != nil {
+				h.abortExport(log, count, custom_errors.NewValidationError("value", i18n.MsgValueInvalidJSON))
+			}
+			bw.Write(line)
+			if err := bw.WriteByte('\n'); err != nil {
+				h.abortExport(log, count, err)
+			}
+		}
+		count += len(page)
+		if len(page) < usecases.MaxListLimit {
+			break
+		}
+
+		// pages reach the client as they are read
+		if err := bw.Flush(); err != nil {
+			h.abortExport(log, count, err)
+		}
+		if gz, ok := out.(*gzip.Writer); ok {
+			gz.Flush()
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if page, err = h.page(r.Context(), prefix, page[len(page)-1].Key); err != nil {
+			h.abortExport(log, count, err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		h.abortExport(log, count, err)
+	}
+
+	log.Info("exported keys", logger.F("count", count), logger.F("duration_ms", time.Since(start).Milliseconds()))
+}
+
+// page reads the keys following after within the page timeout
+func (h *BulkHandler) page(ctx context.Context, prefix, after string) ([]entities.VaultItem, error) {
+	if h.opts.PageTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.opts.PageTimeout)
+		defer cancel()
+	}
+	return h.uc.List(ctx, prefix, after, usecases.MaxListLimit)
+}
+
+// abortExport breaks the connection, so the client sees an incomplete
+// response instead of a stream that looks finished
+func (h *BulkHandler) abortExport(log logger.Logger, count int, err error) {
+	log.Error("export interrupted", logger.F("count", count), logger.Err(err))
+	panic(http.ErrAbortHandler)
+}
+
+// acceptsGzip tells whether Accept-Encoding allows gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(name), "gzip") {
+			q := strings.ReplaceAll(params, " ", "")
+			return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+		}
+	}
+	return false
+}
+
+// ImportHandler handles POST /_import. The body is JSON lines as written by
+// export, gzipped when Content-Encoding says so. Query parameter mode decides
+// what happens to existing keys, dry_run=true only reports. Records are
+// written in chunks, each chunk in one transaction; the import stops at the
+// first invalid record or storage error, X-Import-Committed of the error
+// response tells how many records were written before it. A dry run counts
+// keys of earlier chunks as existing and, in fail-on-conflict mode, stops
+// after the chunk the real run would stop at.
+func (h *BulkHandler) ImportHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	query := r.URL.Query()
+	report := ImportReport{Mode: entities.ImportMode(query.Get("mode")), ConflictKeys: []string{}}
+	if report.Mode == "" {
+		report.Mode = entities.ImportFailOnConflict
+	}
+	var paramErr error
+	if s := query.Get("dry_run"); s != "" {
+		report.DryRun, paramErr = strconv.ParseBool(s)
+		if paramErr != nil {
+			paramErr = custom_errors.NewValidationError("dry_run", i18n.MsgQueryBool)
+		}
+	}
+	if err := custom_errors.NewValidationErrors(usecases.ValidImportMode(report.Mode), paramErr); err != nil {
+		WriteError(w, r, h.logger, err)
+		return
+	}
+	log := logger.FromContext(r.Context(), h.logger).With(
+		logger.F("mode", report.Mode), logger.F("dry_run", report.DryRun))
+	log.Info("import started")
+
+	body := io.Reader(r.Body)
+	if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			WriteError(w, r, h.logger, custom_errors.NewValidationError("body", i18n.MsgBodyGzip))
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	committed := 0
+	fail := func(err error) {
+		log.Warn("import stopped", logger.F("records", report.Records), logger.F("committed", committed), logger.Err(err))
+		w.Header().Set("X-Import-Committed", strconv.Itoa(committed))
+		WriteError(w, r, h.logger, err)
+	}
+
+	chunk := make([]entities.VaultItem, 0, importChunk)
+	written := map[string]bool{} // Keys of the chunks a dry run went through
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		items, result := chunk, entities.ImportResult{}
+		if report.DryRun {
+			items, result = dryRunWritten(chunk, written, report.Mode)
+		}
+		if len(items) > 0 {
+			imported, err := h.importChunk(r.Context(), items, report.Mode, report.DryRun)
+			if err != nil {
+				return err
+			}
+			result.Created += imported.Created
+			result.Updated += imported.Updated
+			result.Skipped += imported.Skipped
+			result.Conflicts += imported.Conflicts
+			result.ConflictKeys = append(result.ConflictKeys, imported.ConflictKeys...)
+		}
+		if report.DryRun {
+			// after the chunk all its keys exist: they were created,
+			// overwritten, skipped or conflicted with existing ones
+			for _, item := range chunk {
+				written[item.Key] = true
+			}
+		}
+		report.Records += len(chunk)
+		report.Created += result.Created
+		report.Updated += result.Updated
+		report.Skipped += result.Skipped
+		report.Conflicts += result.Conflicts
+		for _, key := range result.ConflictKeys {
+			if len(report.ConflictKeys) < maxConflictKeys {
+				report.ConflictKeys = append(report.ConflictKeys, key)
+			}
+		}
+		if !report.DryRun {
+			committed += len(chunk)
+		}
+		chunk = chunk[:0]
+		log.Info("import progress", logger.F("records", report.Records),
+			logger.F("created", report.Created), logger.F("updated", report.Updated), logger.F("skipped", report.Skipped))
+		return nil
+	}
+
+	lines := &lineReader{r: bufio.NewReader(body), max: h.opts.MaxRecord}
+	for {
+		line, err := lines.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fail(err)
+			return
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		item, err := h.parseRecord(line)
+		if err != nil {
+			fail(custom_errors.NewRecordError(lines.n, err))
+			return
+		}
+		chunk = append(chunk, item)
+		if len(chunk) == importChunk {
+			if err := flush(); err != nil {
+				fail(err)
+				return
+			}
+			if report.DryRun && report.Mode == entities.ImportFailOnConflict && report.Conflicts > 0 {
+				break
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		fail(err)
+		return
+	}
+
+	log.Info("import finished", logger.F("records", report.Records), logger.F("created", report.Created),
+		logger.F("updated", report.Updated), logger.F("skipped", report.Skipped),
+		logger.F("conflicts", report.Conflicts), logger.F("duration_ms", time.Since(start).Milliseconds()))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+// dryRunWritten counts the items of a dry run chunk whose keys earlier chunks
+// went through, the storage does not hold them yet. It returns the other items.
+func dryRunWritten(chunk []entities.VaultItem, written map[string]bool, mode entities.ImportMode) ([]entities.VaultItem, entities.ImportResult) {
+	var result entities.ImportResult
+	items := make([]entities.VaultItem, 0, len(chunk))
+	for _, item := range chunk {
+		switch {
+		case !written[item.Key]:
+			items = append(items, item)
+		case mode == entities.ImportOverwrite:
+			result.Updated++
+		case mode == entities.ImportSkipExisting:
+			result.Skipped++
+		default:
+			result.Conflicts++
+			result.ConflictKeys = append(result.ConflictKeys, item.Key)
+		}
+	}
+	return items, result
+}
+
+// importChunk writes a chunk within the chunk timeout
+func (h *BulkHandler) importChunk(ctx context.Context, items []entities.VaultItem, mode entities.ImportMode, dryRun bool) (entities.ImportResult, error) {
+	if h.opts.ChunkTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.opts.ChunkTimeout)
+		defer cancel()
+	}
+	return h.uc.Import(ctx, items, mode, dryRun)
+}
+
+// parseRecord strictly decodes and validates a line of import
+func (h *BulkHandler) parseRecord(line []byte) (entities.VaultItem, error) {
+	var rec record
+	if err := decodeObject(bytes.NewReader(line), &rec); err != nil {
+		return entities.VaultItem{}, err
+	}
+
+	rules := h.uc.Rules()
+	keyErr := custom_errors.NewValidationError("key", i18n.MsgFieldRequired)
+	if rec.Key != nil {
+		keyErr = rules.Key(*rec.Key)
+	}
+	var expiresAt time.Time
+	var timeErr error
+	if rec.ExpiresAt != nil {
+		var err error
+		if expiresAt, err = time.Parse(time.RFC3339Nano, *rec.ExpiresAt); err != nil {
+			timeErr = custom_errors.NewValidationError("expires_at", i18n.MsgFieldTime)
+		}
+	}
+	if err := custom_errors.NewValidationErrors(keyErr, checkValue(rules, rec.Value), timeErr); err != nil {
+		return entities.VaultItem{}, err
+	}
+	return entities.VaultItem{Key: *rec.Key, Value: string(rec.Value), ExpiresAt: expiresAt, Flags: rec.Flags}, nil
+}
+
+// lineReader reads lines of at most max bytes counting them
+type lineReader struct {
+	r   *bufio.Reader
+	max int64
+	n   int // Number of the last line read
+}
+
+// next returns the next line without its terminator
+func (l *lineReader) next() ([]byte, error) {
+	var line []byte
+	for {
+		part, err := l.r.ReadSlice('\n')
+		line = append(line, part...)
+		if l.max > 0 && int64(len(line)) > l.max+2 {
+			l.n++
+			return nil, custom_errors.NewRecordError(l.n,
+				custom_errors.NewTooLargeError("body", i18n.MsgRecordTooLarge, l.max))
+		}
+		switch {
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case err == io.EOF && len(line) > 0:
+		case corruptGzip(err):
+			return nil, custom_errors.NewValidationError("body", i18n.MsgBodyGzip)
+		case err != nil:
+			return nil, err
+		}
+		l.n++
+		return bytes.TrimRight(line, "\r\n"), nil
+	}
+}
+
+// corruptGzip tells whether a read failed on a broken gzip stream
+func corruptGzip(err error) bool {
+	var corrupt flate.CorruptInputError
+	return errors.Is(err, gzip.ErrChecksum) || errors.Is(err, gzip.ErrHeader) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &corrupt)
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"github.com/vvjke314/vk-test-03-2025/internal/i18n"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+	"github.com/vvjke314/vk-test-03-2025/internal/validation"
+)
+
+// decodeBody strictly decodes a single JSON object from request body into dst.
+// Bodies cut by http.MaxBytesReader are reported as too large, unknown fields
+// and fields of a wrong type as field errors, other failures as invalid JSON.
+func decodeBody(r *http.Request, dst interface{}) error {
+	return decodeObject(r.Body, dst)
+}
+
+// decodeObject is decodeBody for any reader
+func decodeObject(r io.Reader, dst interface{}) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err == nil {
+		// anything but whitespace after the object is a second value
+		if _, err = dec.Token(); err == io.EOF {
+			return nil
+		}
+		if err == nil {
+			return custom_errors.NewValidationError("body", i18n.MsgBodyNotObject)
+		}
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return custom_errors.NewTooLargeError("body", i18n.MsgBodyTooLarge, tooLarge.Limit)
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		if typeErr.Field == "" {
+			return custom_errors.NewValidationError("body", i18n.MsgBodyNotObject)
+		}
+		if typeErr.Type.Kind() == reflect.Uint32 {
+			return custom_errors.NewValidationError(typeErr.Field, i18n.MsgFieldUint32)
+		}
+		return custom_errors.NewValidationError(typeErr.Field, i18n.MsgFieldString)
+	}
+	// encoding/json has no typed error for unknown fields
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		if name, err := strconv.Unquote(field); err == nil {
+			field = name
+		}
+		return custom_errors.NewValidationError(field, i18n.MsgFieldUnknown)
+	}
+	return custom_errors.NewValidationError("body", i18n.MsgBodyInvalidJSON)
+}
+
+// checkValue validates a raw JSON value field, nil means the field is absent
+func checkValue(rules validation.Rules, value json.RawMessage) error {
+	switch {
+	case value == nil:
+		return custom_errors.NewValidationError("value", i18n.MsgFieldRequired)
+	case !json.Valid(value):
+		return custom_errors.NewValidationError("value", i18n.MsgValueInvalidJSON)
+	}
+	return rules.Value(string(value))
+}
+
+// statusClientClosedRequest is reported when the client went away before the answer
+const statusClientClosedRequest = 499
+
+// problem is an RFC 7807 response body extended with a stable error code
+type problem struct {
+	Type      string             `json:"type"`
+	Title     string             `json:"title"`
+	Status    int                `json:"status"`
+	Detail    string             `json:"detail,omitempty"`
+	Instance  string             `json:"instance,omitempty"`
+	Code      custom_errors.Code `json:"code"`
+	Key       string             `json:"key,omitempty"`
+	Field     string             `json:"field,omitempty"`
+	RequestID string             `json:"request_id,omitempty"`
+
+	Errors []fieldProblem `json:"errors,omitempty"`
+}
+
+// fieldProblem describes one invalid request field
+type fieldProblem struct {
+	Field  string             `json:"field"`
+	Code   custom_errors.Code `json:"code"`
+	Detail string             `json:"detail"`
+}
+
+// HTTPStatus maps error code to HTTP status
+func HTTPStatus(code custom_errors.Code) int {
+	switch code {
+	case custom_errors.CodeValidation:
+		return http.StatusBadRequest
+	case custom_errors.CodeUnauthenticated:
+		return http.StatusUnauthorized
+	case custom_errors.CodeNotFound:
+		return http.StatusNotFound
+	case custom_errors.CodeAlreadyExists, custom_errors.CodeConflict:
+		return http.StatusConflict
+	case custom_errors.CodeTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case custom_errors.CodeRateLimited:
+		return http.StatusTooManyRequests
+	case custom_errors.CodeCanceled:
+		return statusClientClosedRequest
+	case custom_errors.CodeTimeout:
+		return http.StatusGatewayTimeout
+	case custom_errors.CodeUnavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// WriteError is the single place converting errors into HTTP responses.
+// It answers with application/problem+json localized from Accept-Language,
+// the code field stays the same in every language. The error is logged
+// at a level matching its class.
+func WriteError(w http.ResponseWriter, r *http.Request, l logger.Logger, err error) {
+	code := custom_errors.CodeOf(err)
+	status := HTTPStatus(code)
+	lang := i18n.FromAcceptLanguage(r.Header.Get("Accept-Language"))
+
+	body := problem{
+		Type:      "urn:vault:error:" + string(code),
+		Title:     code.Title(lang),
+		Status:    status,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: logger.RequestID(r.Context()),
+	}
+	// internal details never leave the service
+	if e, ok := custom_errors.As(err); ok && code != custom_errors.CodeInternal {
+		body.Detail = e.Localize(lang)
+		body.Key = e.Key
+		body.Field = e.Field
+		for _, d := range e.Details {
+			body.Errors = append(body.Errors, fieldProblem{Field: d.Field, Code: d.Code, Detail: d.Localize(lang)})
+		}
+		if len(e.Details) == 0 && e.Field != "" {
+			body.Errors = []fieldProblem{{Field: e.Field, Code: e.Code, Detail: body.Detail}}
+		}
+	}
+
+	log := logger.FromContext(r.Context(), l).With(logger.F("code", string(code)), logger.F("status", status))
+	switch {
+	case status >= http.StatusInternalServerError:
+		log.Error("request failed", logger.Err(err))
+	case code == custom_errors.CodeNotFound || code == custom_errors.CodeAlreadyExists:
+		log.Info("request rejected", logger.Err(err))
+	default:
+		log.Warn("request rejected", logger.Err(err))
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("Content-Language", string(lang))
+	w.Header().Add("Vary", "Accept-Language")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"github.com/vvjke314/vk-test-03-2025/internal/i18n"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+)
+
+func TestDecodeBody(t *testing.T) {
+	tests := []struct {
+		body  string
+		field string
+		msg   i18n.MessageID
+	}{
+		{`{"key":"a","value":1}`, "", ""},
+		{`{"key":"a","value":1}` + "\n", "", ""},
+		{`{"key":"a","extra":1}`, "extra", i18n.MsgFieldUnknown},
+		{`{"key":1}`, "key", i18n.MsgFieldString},
+		{`[1]`, "body", i18n.MsgBodyNotObject},
+		{`{"key":"a"}{"key":"b"}`, "body", i18n.MsgBodyNotObject},
+		{`{"key":`, "body", i18n.MsgBodyInvalidJSON},
+	}
+
+	for _, tt := range tests {
+		var dst struct {
+			Key   *string         `json:"key"`
+			Value json.RawMessage `json:"value"`
+		}
+		err := decodeBody(httptest.NewRequest("POST", "/kv", strings.NewReader(tt.body)), &dst)
+		if tt.msg == "" {
+			if err != nil {
+				t.Errorf("decodeBody(%s) = %v", tt.body, err)
+			}
+			continue
+		}
+		e, ok := custom_errors.As(err)
+		if !ok || e.Field != tt.field || e.MsgID != tt.msg {
+			t.Errorf("decodeBody(%s) = %#v, want %s %s", tt.body, err, tt.field, tt.msg)
+		}
+	}
+}
+
+func TestWriteErrorFields(t *testing.T) {
+	err := custom_errors.NewValidationErrors(
+		custom_errors.NewValidationError("key", i18n.MsgFieldRequired),
+		custom_errors.NewValidationError("value", i18n.MsgValueInvalidJSON),
+	)
+	w := httptest.NewRecorder()
+	WriteError(w, httptest.NewRequest("POST", "/kv", nil), logger.NewWriterLogger(io.Discard), err)
+
+	var body problem
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != 400 || body.Code != custom_errors.CodeValidation {
+		t.Fatalf("status %d code %q", w.Code, body.Code)
+	}
+	if len(body.Errors) != 2 || body.Errors[0].Field != "key" || body.Errors[1].Field != "value" {
+		t.Errorf("errors = %+v", body.Errors)
+	}
+}
@@ -2,75 +2,64 @@ package handlers
 
 import (
 	"encoding/json"
-	"errors"
-	"fmt"
-	"log"
 	"net/http"
 
 	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
 	"github.com/vvjke314/vk-test-03-2025/internal/entities"
+	"github.com/vvjke314/vk-test-03-2025/internal/i18n"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
 	"github.com/vvjke314/vk-test-03-2025/internal/usecases"
 )
 
 type KVHandler struct {
 	uc     *usecases.KeyValueUseCase
-	logger *log.Logger
+	logger logger.Logger
 }
 
-func NewKVHandler(uc *usecases.KeyValueUseCase, logger *log.Logger) *KVHandler {
+func NewKVHandler(uc *usecases.KeyValueUseCase, l logger.Logger) *KVHandler {
 	return &KVHandler{
 		uc:     uc,
-		logger: logger,
+		logger: l,
 	}
 }
 
 // CreateKeyHandler handle POST /kv
 func (h *KVHandler) CreateKeyHandler(w http.ResponseWriter, r *http.Request) {
-	h.logger.Printf("Request to create key-value pair: %s %s", r.Method, r.URL.Path)
+	log := logger.FromContext(r.Context(), h.logger)
+	log.Debug("request to create key-value pair")
 
 	var req struct {
-		Key   string          `json:"key"`
+		Key   *string         `json:"key"`
 		Value json.RawMessage `json:"value"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logger.Printf("Bad JSON body: %v", err)
-		http.Error(w, `{"error": "bad json"}`, http.StatusBadRequest)
+	if err := decodeBody(r, &req); err != nil {
+		WriteError(w, r, h.logger, err)
 		return
 	}
 
-	if req.Key == "" {
-		h.logger.Printf("empty key")
-		http.Error(w, `{"error": "key can not be empty"}`, http.StatusBadRequest)
-		return
+	rules := h.uc.Rules()
+	keyErr := custom_errors.NewValidationError("key", i18n.MsgFieldRequired)
+	if req.Key != nil {
+		keyErr = rules.Key(*req.Key)
 	}
-
-	if !json.Valid(req.Value) {
-		h.logger.Printf("json did not validate %s", req.Key)
-		http.Error(w, `{"error": "bad value JSON"}`, http.StatusBadRequest)
+	if err := custom_errors.NewValidationErrors(keyErr, checkValue(rules, req.Value)); err != nil {
+		WriteError(w, r, h.logger, err)
 		return
 	}
 
 	item := entities.VaultItem{
-		Key:   req.Key,
+		Key:   *req.Key,
 		Value: string(req.Value),
 	}
 
-	err := h.uc.InsertValue(item)
-	if err != nil {
-		h.logger.Printf("error while creating key %s: %v", req.Key, err)
-
-		switch {
-		case err.Error() == fmt.Sprintf("key '%s' already exists", item.Key):
-			h.logger.Printf("key already exists: %s", req.Key)
-			http.Error(w, `{"error": "key already exists"}`, http.StatusConflict)
-		default:
-			http.Error(w, `{"error": "internal server error"}`, http.StatusInternalServerError)
-		}
+	if err := h.uc.InsertValue(r.Context(), item); err != nil {
+		WriteError(w, r, h.logger, err)
 		return
 	}
 
-	h.logger.Printf("successfully created key: %s", req.Key)
+	log.Info("successfully created key", logger.F("key", item.Key))
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
@@ -78,39 +67,32 @@ func (h *KVHandler) CreateKeyHandler(w http.ResponseWriter, r *http.Request) {
 // UpdateKeyHandler handles PUT /kv/{id}
 func (h *KVHandler) UpdateKeyHandler(w http.ResponseWriter, r *http.Request) {
 	key := r.PathValue("id")
-	h.logger.Printf("Request to update key: %s %s", r.Method, r.URL.Path)
+	log := logger.FromContext(r.Context(), h.logger).With(logger.F("key", key))
+	log.Debug("request to update key")
 
 	var req struct {
 		Value json.RawMessage `json:"value"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logger.Printf("JSON decode error for key %s: %v", key, err)
-		http.Error(w, `{"error": "Invalid JSON format"}`, http.StatusBadRequest)
+	if err := decodeBody(r, &req); err != nil {
+		WriteError(w, r, h.logger, err)
 		return
 	}
 
-	// Validate that value contains proper JSON
-	if !json.Valid(req.Value) {
-		h.logger.Printf("Invalid JSON in value for key %s", key)
-		http.Error(w, `{"error": "Invalid JSON in value"}`, http.StatusBadRequest)
+	rules := h.uc.Rules()
+	if err := custom_errors.NewValidationErrors(rules.Key(key), checkValue(rules, req.Value)); err != nil {
+		WriteError(w, r, h.logger, err)
 		return
 	}
 
-	err := h.uc.UpdateValue(key, string(req.Value))
+	err := h.uc.UpdateValue(r.Context(), entities.VaultItem{Key: key, Value: string(req.Value)})
 	if err != nil {
-		if errors.Is(err, custom_errors.ErrKeyNotExists) {
-			h.logger.Printf("Key not found: %s", key)
-			http.Error(w, `{"error": "Key not found"}`, http.StatusNotFound)
-			return
-		}
-
-		h.logger.Printf("Error updating key %s: %v", key, err)
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		WriteError(w, r, h.logger, err)
 		return
 	}
 
-	h.logger.Printf("Successfully updated key: %s", key)
+	log.Info("successfully updated key")
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
@@ -118,22 +100,16 @@ func (h *KVHandler) UpdateKeyHandler(w http.ResponseWriter, r *http.Request) {
 // GetKeyHandler handles GET /kv/{id}
 func (h *KVHandler) GetKeyHandler(w http.ResponseWriter, r *http.Request) {
 	key := r.PathValue("id")
-	h.logger.Printf("Request to get key: %s %s", r.Method, r.URL.Path)
+	log := logger.FromContext(r.Context(), h.logger).With(logger.F("key", key))
+	log.Debug("request to get key")
 
-	item, err := h.uc.Get(key)
+	item, err := h.uc.Get(r.Context(), key)
 	if err != nil {
-		if errors.Is(err, custom_errors.ErrKeyNotExists) {
-			h.logger.Printf("Key not found: %s", key)
-			http.Error(w, `{"error": "Key not found"}`, http.StatusNotFound)
-			return
-		}
-
-		h.logger.Printf("Error getting key %s: %v", key, err)
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		WriteError(w, r, h.logger, err)
 		return
 	}
 
-	h.logger.Printf("Successfully retrieved key: %s", key)
+	log.Info("successfully retrieved key")
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -145,22 +121,17 @@ func (h *KVHandler) GetKeyHandler(w http.ResponseWriter, r *http.Request) {
 // DeleteKeyHandler handles DELETE /kv/{id}
 func (h *KVHandler) DeleteKeyHandler(w http.ResponseWriter, r *http.Request) {
 	key := r.PathValue("id")
-	h.logger.Printf("Request to delete key: %s %s", r.Method, r.URL.Path)
+	log := logger.FromContext(r.Context(), h.logger).With(logger.F("key", key))
+	log.Debug("request to delete key")
 
-	err := h.uc.DeleteRow(key)
+	err := h.uc.DeleteRow(r.Context(), key)
 	if err != nil {
-		if errors.Is(err, custom_errors.ErrKeyNotExists) {
-			h.logger.Printf("Key not found: %s", key)
-			http.Error(w, `{"error": "Key not found"}`, http.StatusNotFound)
-			return
-		}
-
-		h.logger.Printf("Error deleting key %s: %v", key, err)
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		WriteError(w, r, h.logger, err)
 		return
 	}
 
-	h.logger.Printf("Successfully deleted key: %s", key)
+	log.Info("successfully deleted key")
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
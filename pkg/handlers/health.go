@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Pinger is implemented by every dependency taking part in readiness checks
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// dependencyStatus describes result of a single dependency check
+type dependencyStatus struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// HealthHandler serves liveness and readiness probes
+type HealthHandler struct {
+	ready   atomic.Bool
+	timeout time.Duration
+	checks  map[string]Pinger
+}
+
+func NewHealthHandler(timeout time.Duration) *HealthHandler {
+	return &HealthHandler{
+		timeout: timeout,
+		checks:  make(map[string]Pinger),
+	}
+}
+
+// AddCheck registers a dependency checked by /readyz. Must be called before serving.
+func (h *HealthHandler) AddCheck(name string, p Pinger) {
+	h.checks[name] = p
+}
+
+// SetReady switches readiness, e.g. to false while the server is draining
+func (h *HealthHandler) SetReady(ready bool) {
+	h.ready.Store(ready)
+}
+
+// LiveHandler handles GET /healthz
+func (h *HealthHandler) LiveHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "alive"})
+}
+
+// ReadyHandler handles GET /readyz
+func (h *HealthHandler) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !h.ready.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"status": "not ready"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	statuses := h.runChecks(ctx)
+
+	code, status := http.StatusOK, "ready"
+	for _, s := range statuses {
+		if s.Status != "up" {
+			code, status = http.StatusServiceUnavailable, "not ready"
+			break
+		}
+	}
+
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": status,
+		"checks": statuses,
+	})
+}
+
+// runChecks pings all dependencies concurrently
+func (h *HealthHandler) runChecks(ctx context.Context) map[string]dependencyStatus {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		statuses = make(map[string]dependencyStatus, len(h.checks))
+	)
+
+	for name, p := range h.checks {
+		wg.Add(1)
+		go func(name string, p Pinger) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := p.Ping(ctx)
+			s := dependencyStatus{Status: "up", LatencyMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				s.Status = "down"
+				s.Error = err.Error()
+			}
+
+			mu.Lock()
+			statuses[name] = s
+			mu.Unlock()
+		}(name, p)
+	}
+	wg.Wait()
+
+	return statuses
+}
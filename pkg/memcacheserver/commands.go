@@ -0,0 +1,348 @@
+package memcacheserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/vvjke314/vk-test-03-2025/config"
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"github.com/vvjke314/vk-test-03-2025/internal/entities"
+	"github.com/vvjke314/vk-test-03-2025/internal/i18n"
+	"github.com/vvjke314/vk-test-03-2025/internal/ratelimit"
+	"github.com/vvjke314/vk-test-03-2025/internal/textvalue"
+)
+
+// command describes a supported memcached command
+type command struct {
+	storage bool         // a data block follows the command line
+	noreply bool         // the last argument may be noreply
+	op      ratelimit.Op // how the command is accounted by the limits
+	timeout func(config.RouteTimeouts) time.Duration
+	run     func(s *session, ctx context.Context, args [][]byte, data []byte) error
+}
+
+func noTimeout(config.RouteTimeouts) time.Duration       { return 0 }
+func getTimeout(t config.RouteTimeouts) time.Duration    { return t.Get }
+func createTimeout(t config.RouteTimeouts) time.Duration { return t.Create }
+func updateTimeout(t config.RouteTimeouts) time.Duration { return t.Update }
+func deleteTimeout(t config.RouteTimeouts) time.Duration { return t.Delete }
+
+// commands supported by the server, memcached command names are case sensitive
+var commands = map[string]command{
+	"get":     {false, false, ratelimit.Get, getTimeout, cmdGet},
+	"gets":    {false, false, ratelimit.Get, getTimeout, cmdGets},
+	"set":     {true, true, ratelimit.Update, updateTimeout, cmdSet},
+	"add":     {true, true, ratelimit.Create, createTimeout, cmdAdd},
+	"replace": {true, true, ratelimit.Update, updateTimeout, cmdReplace},
+	"cas":     {true, true, ratelimit.Update, updateTimeout, cmdCas},
+	"delete":  {false, true, ratelimit.Delete, deleteTimeout, cmdDelete},
+	"touch":   {false, true, ratelimit.Update, updateTimeout, cmdTouch},
+	"version": {false, false, ratelimit.None, noTimeout, cmdVersion},
+	"quit":    {false, false, ratelimit.None, noTimeout, cmdQuit},
+}
+
+// replyError is sent to the client as is
+type replyError struct {
+	code custom_errors.Code
+	line string
+}
+
+func (e *replyError) Error() string { return e.line }
+
+var (
+	errFormat   = &replyError{custom_errors.CodeValidation, "CLIENT_ERROR bad command line format"}
+	errTooLarge = &replyError{custom_errors.CodeTooLarge, "SERVER_ERROR object too large for cache"}
+)
+
+// errorCode classifies an error, protocol level errors carry their code
+func errorCode(err error) custom_errors.Code {
+	var re *replyError
+	if errors.As(err, &re) {
+		return re.code
+	}
+	return custom_errors.CodeOf(err)
+}
+
+// errorReply renders an error as a memcached error line: invalid requests
+// are client errors, the rest are server errors
+func errorReply(err error) string {
+	var re *replyError
+	if errors.As(err, &re) {
+		return re.line
+	}
+
+	code := custom_errors.CodeOf(err)
+	message := code.Title(i18n.English)
+	// internal details never leave the service
+	if e, ok := custom_errors.As(err); ok && code != custom_errors.CodeInternal {
+		message = e.Localize(i18n.English)
+	}
+
+	switch code {
+	case custom_errors.CodeValidation:
+		return "CLIENT_ERROR " + sanitize(message)
+	case custom_errors.CodeTooLarge:
+		return errTooLarge.line
+	}
+	return "SERVER_ERROR " + sanitize(message)
+}
+
+// serverError tells whether an error is the server's fault
+func serverError(code custom_errors.Code) bool {
+	switch code {
+	case custom_errors.CodeTimeout, custom_errors.CodeUnavailable, custom_errors.CodeInternal:
+		return true
+	}
+	return false
+}
+
+// readData reads the data block of a storage command
+// <key> <flags> <exptime> <bytes> [<cas unique>]. Errors of the command line
+// are returned as cmdErr, a block larger than MaxItem is skipped.
+func (s *session) readData(args [][]byte) (data []byte, cmdErr, err error) {
+	if len(args) != 4 && len(args) != 5 {
+		return nil, errFormat, nil
+	}
+	size, perr := strconv.ParseInt(string(args[3]), 10, 64)
+	if perr != nil || size < 0 {
+		return nil, errFormat, nil
+	}
+	if size > int64(s.srv.opts.MaxItem) {
+		return nil, errTooLarge, s.r.discard(size)
+	}
+	data, err = s.r.readData(int(size))
+	return data, nil, err
+}
+
+// maxRelativeExptime is the largest exptime taken as seconds from now,
+// larger ones are Unix times as in memcached
+const maxRelativeExptime = 30 * 24 * 60 * 60
+
+// expiryTime converts exptime to the time a key expires at: zero never
+// expires, a negative one or a Unix time in the past has already expired
+func expiryTime(arg []byte, now time.Time) (time.Time, error) {
+	exptime, err := strconv.ParseInt(string(arg), 10, 32)
+	switch {
+	case err != nil:
+		return time.Time{}, errFormat
+	case exptime == 0:
+		return time.Time{}, nil
+	case exptime < 0:
+		return now, nil
+	case exptime <= maxRelativeExptime:
+		return now.Add(time.Duration(exptime) * time.Second), nil
+	}
+	at := time.Unix(exptime, 0)
+	if at.Before(now) {
+		return now, nil
+	}
+	return at, nil
+}
+
+// storageArgs parses the arguments of a storage command, flags are stored
+// with the value
+func storageArgs(args [][]byte, data []byte) (entities.VaultItem, error) {
+	flags, err := strconv.ParseUint(string(args[1]), 10, 32)
+	if err != nil {
+		return entities.VaultItem{}, errFormat
+	}
+	expiresAt, err := expiryTime(args[2], time.Now())
+	if err != nil {
+		return entities.VaultItem{}, err
+	}
+
+	value, err := textvalue.Encode(data)
+	if err != nil {
+		return entities.VaultItem{}, err
+	}
+	return entities.VaultItem{Key: string(args[0]), Value: value, ExpiresAt: expiresAt, Flags: uint32(flags)}, nil
+}
+
+// cmdGet answers get, missing keys are left out. A key too long to be
+// stored fails the command as in delete and touch.
+func cmdGet(s *session, ctx context.Context, args [][]byte, _ []byte) error {
+	return getValues(s, ctx, args, false)
+}
+
+// cmdGets is get with versions to pass to cas
+func cmdGets(s *session, ctx context.Context, args [][]byte, _ []byte) error {
+	return getValues(s, ctx, args, true)
+}
+
+func getValues(s *session, ctx context.Context, args [][]byte, withCas bool) error {
+	if len(args) == 0 {
+		return &replyError{custom_errors.CodeValidation, "ERROR"}
+	}
+
+	// values are collected first so that a failure does not cut the reply
+	type value struct {
+		key     string
+		data    []byte
+		flags   uint32
+		version uint64
+	}
+	values := make([]value, 0, len(args))
+	for _, arg := range args {
+		key := string(arg)
+		var item entities.VaultItem
+		var version uint64
+		var err error
+		if withCas {
+			item, version, err = s.srv.opts.UseCase.GetVersioned(ctx, key)
+		} else {
+			item, err = s.srv.opts.UseCase.Get(ctx, key)
+		}
+		switch {
+		case err == nil:
+			values = append(values, value{key, textvalue.Decode(item.Value), item.Flags, version})
+		case !errors.Is(err, custom_errors.ErrKeyNotExists):
+			return err
+		}
+	}
+
+	for _, v := range values {
+		if withCas {
+			fmt.Fprintf(s.w, "VALUE %s %d %d %d\r\n", v.key, v.flags, len(v.data), v.version)
+		} else {
+			fmt.Fprintf(s.w, "VALUE %s %d %d\r\n", v.key, v.flags, len(v.data))
+		}
+		s.w.Write(v.data)
+		s.writeLine("")
+	}
+	s.writeLine("END")
+	return nil
+}
+
+func cmdSet(s *session, ctx context.Context, args [][]byte, data []byte) error {
+	if len(args) != 4 {
+		return errFormat
+	}
+	item, err := storageArgs(args, data)
+	if err != nil {
+		return err
+	}
+	if _, err := s.srv.opts.UseCase.PutValue(ctx, item); err != nil {
+		return err
+	}
+	s.reply("STORED")
+	return nil
+}
+
+// cmdAdd stores a key that does not exist yet
+func cmdAdd(s *session, ctx context.Context, args [][]byte, data []byte) error {
+	if len(args) != 4 {
+		return errFormat
+	}
+	item, err := storageArgs(args, data)
+	if err != nil {
+		return err
+	}
+	err = s.srv.opts.UseCase.InsertValue(ctx, item)
+	switch {
+	case errors.Is(err, custom_errors.ErrKeyExists):
+		s.reply("NOT_STORED")
+	case err != nil:
+		return err
+	default:
+		s.reply("STORED")
+	}
+	return nil
+}
+
+// cmdReplace stores a key that already exists
+func cmdReplace(s *session, ctx context.Context, args [][]byte, data []byte) error {
+	if len(args) != 4 {
+		return errFormat
+	}
+	item, err := storageArgs(args, data)
+	if err != nil {
+		return err
+	}
+	err = s.srv.opts.UseCase.UpdateValue(ctx, item)
+	switch {
+	case errors.Is(err, custom_errors.ErrKeyNotExists):
+		s.reply("NOT_STORED")
+	case err != nil:
+		return err
+	default:
+		s.reply("STORED")
+	}
+	return nil
+}
+
+// cmdCas stores a key unless it was written since gets returned its version
+func cmdCas(s *session, ctx context.Context, args [][]byte, data []byte) error {
+	if len(args) != 5 {
+		return errFormat
+	}
+	version, err := strconv.ParseUint(string(args[4]), 10, 64)
+	if err != nil {
+		return errFormat
+	}
+	item, err := storageArgs(args, data)
+	if err != nil {
+		return err
+	}
+	err = s.srv.opts.UseCase.CompareAndSwap(ctx, item, version)
+	switch {
+	case errors.Is(err, custom_errors.ErrConflict):
+		s.reply("EXISTS")
+	case errors.Is(err, custom_errors.ErrKeyNotExists):
+		s.reply("NOT_FOUND")
+	case err != nil:
+		return err
+	default:
+		s.reply("STORED")
+	}
+	return nil
+}
+
+// cmdDelete removes a key, the legacy zero hold time is accepted
+func cmdDelete(s *session, ctx context.Context, args [][]byte, _ []byte) error {
+	if len(args) != 1 && (len(args) != 2 || string(args[1]) != "0") {
+		return errFormat
+	}
+	err := s.srv.opts.UseCase.DeleteRow(ctx, string(args[0]))
+	switch {
+	case errors.Is(err, custom_errors.ErrKeyNotExists):
+		s.reply("NOT_FOUND")
+	case err != nil:
+		return err
+	default:
+		s.reply("DELETED")
+	}
+	return nil
+}
+
+// cmdTouch sets a new exptime of a key, zero makes it persistent
+func cmdTouch(s *session, ctx context.Context, args [][]byte, _ []byte) error {
+	if len(args) != 2 {
+		return errFormat
+	}
+	at, err := expiryTime(args[1], time.Now())
+	if err != nil {
+		return err
+	}
+	err = s.srv.opts.UseCase.Expire(ctx, string(args[0]), at)
+	switch {
+	case errors.Is(err, custom_errors.ErrKeyNotExists):
+		s.reply("NOT_FOUND")
+	case err != nil:
+		return err
+	default:
+		s.reply("TOUCHED")
+	}
+	return nil
+}
+
+func cmdVersion(s *session, _ context.Context, _ [][]byte, _ []byte) error {
+	s.writeLine("VERSION 1.6.0")
+	return nil
+}
+
+func cmdQuit(*session, context.Context, [][]byte, []byte) error {
+	return nil
+}
@@ -0,0 +1,52 @@
+package memcacheserver
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// errProtocol marks input the server cannot resynchronize after,
+// the connection is closed after replying
+var errProtocol = errors.New("protocol error")
+
+// maxLineLength bounds command lines, get with many keys included
+const maxLineLength = 64 << 10
+
+// reader parses command lines and data blocks of storage commands
+type reader struct {
+	r *bufio.Reader
+}
+
+// readLine reads a line without its terminator, \n alone is accepted
+func (r *reader) readLine() ([]byte, error) {
+	line, err := r.r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) || len(line) > maxLineLength {
+		return nil, fmt.Errorf("%w: line too long", errProtocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+	line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+	return line, nil
+}
+
+// readData reads a data block of n bytes followed by \r\n
+func (r *reader) readData(n int) ([]byte, error) {
+	data := make([]byte, n+2)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return nil, err
+	}
+	if !bytes.HasSuffix(data, []byte("\r\n")) {
+		return nil, fmt.Errorf("%w: bad data chunk", errProtocol)
+	}
+	return data[:n], nil
+}
+
+// discard skips a data block of n bytes that is not going to be stored
+func (r *reader) discard(n int64) error {
+	_, err := io.CopyN(io.Discard, r.r, n+2)
+	return err
+}
@@ -0,0 +1,290 @@
+// Package memcacheserver serves the memcached text protocol on top of the
+// key-value use case, so memcached clients can use the vault
+package memcacheserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vvjke314/vk-test-03-2025/config"
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+	"github.com/vvjke314/vk-test-03-2025/internal/metrics"
+	"github.com/vvjke314/vk-test-03-2025/internal/ratelimit"
+	"github.com/vvjke314/vk-test-03-2025/internal/tracing"
+	"github.com/vvjke314/vk-test-03-2025/internal/usecases"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+)
+
+// Options groups dependencies and settings of the memcached protocol server
+type Options struct {
+	UseCase  *usecases.KeyValueUseCase
+	Logger   logger.Logger
+	Timeouts config.RouteTimeouts
+	Limits   *ratelimit.Limits // Budgets shared with the other front ends, nil limits nothing
+	MaxItem  int               // Largest data block of a storage command in bytes
+}
+
+// Server accepts memcached protocol connections
+type Server struct {
+	opts Options
+
+	mu     sync.Mutex
+	lis    net.Listener
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// New creates a server, it does not listen until Serve
+func New(opts Options) *Server {
+	if opts.MaxItem <= 0 {
+		opts.MaxItem = 1 << 20
+	}
+	return &Server{
+		opts:  opts,
+		conns: make(map[net.Conn]struct{}),
+	}
+}
+
+// Serve accepts connections on lis until Shutdown, after which it returns nil
+func (s *Server) Serve(lis net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		lis.Close()
+		return nil
+	}
+	s.lis = lis
+	s.mu.Unlock()
+
+	for {
+		nc, err := lis.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			nc.Close()
+			return nil
+		}
+		s.conns[nc] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serveConn(nc)
+	}
+}
+
+// Shutdown stops accepting connections and closes every connection once its
+// current command is answered. Connections still busy when ctx is done are
+// closed at once.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	if s.lis != nil {
+		s.lis.Close()
+	}
+	// an expired read deadline ends connections waiting for the next command
+	for nc := range s.conns {
+		nc.SetReadDeadline(time.Now())
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for nc := range s.conns {
+			nc.Close()
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// serveConn runs commands of a connection until it is closed
+func (s *Server) serveConn(nc net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, nc)
+		s.mu.Unlock()
+		nc.Close()
+		s.wg.Done()
+	}()
+
+	sess := &session{
+		srv:    s,
+		remote: nc.RemoteAddr().String(),
+		client: ratelimit.AddrKey(nc.RemoteAddr()),
+		r:      &reader{r: bufio.NewReaderSize(nc, maxLineLength)}, // holds a whole command line
+		w:      bufio.NewWriter(nc),
+	}
+
+	for {
+		line, err := sess.r.readLine()
+		if err == nil {
+			var quit bool
+			quit, err = sess.exec(line)
+			if quit {
+				sess.w.Flush()
+				return
+			}
+		}
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				sess.writeLine("CLIENT_ERROR " + strings.TrimPrefix(err.Error(), errProtocol.Error()+": "))
+				sess.w.Flush()
+			}
+			return
+		}
+
+		// pipelined commands are answered together
+		if sess.r.r.Buffered() == 0 {
+			if err := sess.w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// session is the state of a connection
+type session struct {
+	srv     *Server
+	remote  string
+	client  string // key of the client in the limits
+	r       *reader
+	w       *bufio.Writer
+	noreply bool // the current command asked not to be answered
+}
+
+// reply writes a line unless the command asked for no reply
+func (s *session) reply(line string) {
+	if !s.noreply {
+		s.writeLine(line)
+	}
+}
+
+func (s *session) writeLine(line string) {
+	s.w.WriteString(line)
+	s.w.WriteString("\r\n")
+}
+
+// exec runs a command line and writes its reply. It reports whether the
+// connection should be closed, errors are those of reading the connection.
+func (s *session) exec(line []byte) (quit bool, err error) {
+	start := time.Now()
+	args := bytes.Fields(line)
+	s.noreply = false
+	if len(args) == 0 {
+		s.writeLine("ERROR")
+		return false, nil
+	}
+	name := string(args[0])
+	cmd, ok := commands[name]
+	if !ok {
+		s.writeLine("ERROR")
+		metrics.ObserveMemcache("unknown", string(custom_errors.CodeValidation), time.Since(start))
+		return false, nil
+	}
+	args = args[1:]
+	if n := len(args); n > 0 && string(args[n-1]) == "noreply" && cmd.noreply {
+		s.noreply, args = true, args[:n-1]
+	}
+
+	// the data block is read before the command is timed
+	var data []byte
+	var cmdErr error
+	if cmd.storage {
+		data, cmdErr, err = s.readData(args)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	ctx := logger.ContextWithRequestID(context.Background(), uuid.NewString())
+	ctx, span := tracing.StartServer(ctx, http.Header{}, "MEMCACHE "+strings.ToUpper(name),
+		attribute.String("memcache.command", name),
+		attribute.String("network.peer.address", s.remote),
+	)
+	defer span.End()
+	if timeout := cmd.timeout(s.srv.opts.Timeouts); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if cmdErr == nil {
+		var release func()
+		if release, cmdErr = s.srv.opts.Limits.Take(s.client, cmd.op); cmdErr == nil {
+			cmdErr = cmd.run(s, ctx, args, data)
+			release()
+		}
+	}
+
+	result := "ok"
+	log := logger.FromContext(ctx, s.srv.opts.Logger)
+	if cmdErr != nil {
+		code := errorCode(cmdErr)
+		result = string(code)
+		s.reply(errorReply(cmdErr))
+		if serverError(code) {
+			span.SetStatus(otelcodes.Error, cmdErr.Error())
+			log.Error("command failed", logger.F("command", name), logger.Err(cmdErr))
+		} else {
+			log.Warn("command rejected", logger.F("command", name), logger.Err(cmdErr))
+		}
+	}
+
+	duration := time.Since(start)
+	metrics.ObserveMemcache(name, result, duration)
+	log.Info("memcache command",
+		logger.F("command", name),
+		logger.F("remote", s.remote),
+		logger.F("result", result),
+		logger.F("duration_ms", duration.Milliseconds()),
+	)
+	return name == "quit", nil
+}
+
+// sanitize makes a message safe to send in a reply line
+func sanitize(s string) string {
+	if len(s) > 128 {
+		s = s[:128]
+	}
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, s)
+}
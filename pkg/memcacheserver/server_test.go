@@ -0,0 +1,291 @@
+package memcacheserver
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vvjke314/vk-test-03-2025/config"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+	"github.com/vvjke314/vk-test-03-2025/internal/ratelimit"
+	"github.com/vvjke314/vk-test-03-2025/internal/repository/repotest"
+	"github.com/vvjke314/vk-test-03-2025/internal/usecases"
+	"github.com/vvjke314/vk-test-03-2025/internal/validation"
+)
+
+// startServer serves repo on a local port
+func startServer(t *testing.T, repo *repotest.Repo) (*Server, string) {
+	return serve(t, testOptions(repo))
+}
+
+// testOptions serves repo with the rules and timeouts of the tests
+func testOptions(repo *repotest.Repo) Options {
+	uc := usecases.NewKeyValueUseCase(repo, validation.Rules{
+		MaxKeyLen:     256,
+		MaxValueBytes: 64,
+		KeyPattern:    regexp.MustCompile(`^[A-Za-z0-9._~:@-]*$`),
+		KeyCharset:    "A-Za-z0-9._~:@-",
+	})
+	timeout := 3 * time.Second
+	return Options{
+		UseCase:  uc,
+		Logger:   logger.NewWriterLogger(io.Discard),
+		Timeouts: config.RouteTimeouts{Get: timeout, Create: timeout, Update: timeout, Delete: timeout},
+		MaxItem:  128,
+	}
+}
+
+// serve starts a server on a local port, it is shut down with the test
+func serve(t *testing.T, opts Options) (*Server, string) {
+	t.Helper()
+	srv := New(opts)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(lis)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	})
+	return srv, lis.Addr().String()
+}
+
+// client sends raw protocol text and reads reply lines
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dial(t *testing.T, addr string) *client {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	t.Cleanup(func() { conn.Close() })
+	return &client{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *client) send(text string) {
+	c.t.Helper()
+	if _, err := io.WriteString(c.conn, text); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// lines reads n reply lines joined by |
+func (c *client) lines(n int) string {
+	c.t.Helper()
+	lines := make([]string, n)
+	for i := range lines {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			c.t.Fatalf("read reply: %v", err)
+		}
+		lines[i] = strings.TrimSuffix(line, "\r\n")
+	}
+	return strings.Join(lines, "|")
+}
+
+func TestCommands(t *testing.T) {
+	repo := repotest.New(map[string]string{"json": `{"a":1}`})
+	_, addr := startServer(t, repo)
+	c := dial(t, addr)
+
+	steps := []struct {
+		send  string
+		lines int
+		want  string
+	}{
+		{"set a 0 0 5\r\nhello\r\n", 1, "STORED"},
+		{"get a\r\n", 3, "VALUE a 0 5|hello|END"},
+		{"get json missing a\r\n", 5, `VALUE json 0 7|{"a":1}|VALUE a 0 5|hello|END`},
+		{"get bad=key\r\n", 1, "END"},
+		{"get " + strings.Repeat("missing ", 1000) + "a\r\n", 3, "VALUE a 0 5|hello|END"},
+		{"add a 0 0 1\r\nx\r\n", 1, "NOT_STORED"},
+		{"add b 0 0 1\r\nx\r\n", 1, "STORED"},
+		{"replace c 0 0 1\r\ny\r\n", 1, "NOT_STORED"},
+		{"replace b 0 0 1\r\ny\r\n", 1, "STORED"},
+		{"touch b 0\r\n", 1, "TOUCHED"},
+		{"touch missing 0\r\n", 1, "NOT_FOUND"},
+		{"set f 3 0 1\r\nz\r\n", 1, "STORED"},
+		{"get f\r\n", 3, "VALUE f 3 1|z|END"},
+		{"set f 4294967296 0 1\r\nz\r\n", 1, "CLIENT_ERROR bad command line format"},
+		{"get " + strings.Repeat("k", 300) + "\r\n", 1, "CLIENT_ERROR key must be at most 256 bytes long"},
+		{"delete " + strings.Repeat("k", 300) + "\r\n", 1, "CLIENT_ERROR key must be at most 256 bytes long"},
+		{"set bad=key 0 0 1\r\nz\r\n", 1, "CLIENT_ERROR key may only contain characters [A-Za-z0-9._~:@-]"},
+		{"set bin 0 0 1\r\n\xff\r\n", 1, "CLIENT_ERROR value must be UTF-8 text, binary values are not supported"},
+		{"set big 0 0 70\r\n" + strings.Repeat("x", 70) + "\r\n", 1, "SERVER_ERROR object too large for cache"},
+		{"set huge 0 0 200\r\n" + strings.Repeat("x", 200) + "\r\n", 1, "SERVER_ERROR object too large for cache"},
+		{"set a 0 0\r\n", 1, "CLIENT_ERROR bad command line format"},
+		{"delete b\r\n", 1, "DELETED"},
+		{"delete b\r\n", 1, "NOT_FOUND"},
+		{"get b\r\n", 1, "END"},
+		{"flush_all\r\n", 1, "ERROR"},
+		{"GET a\r\n", 1, "ERROR"},
+		{"version\r\n", 1, "VERSION 1.6.0"},
+	}
+	for _, step := range steps {
+		c.send(step.send)
+		if got := c.lines(step.lines); got != step.want {
+			t.Errorf("%q = %q, want %q", step.send, got, step.want)
+		}
+	}
+
+	// memcached values are stored as JSON strings readable over HTTP
+	if got, _ := repo.Value("a"); got != `"hello"` {
+		t.Errorf("stored value = %s", got)
+	}
+
+	c.send("quit\r\n")
+	if _, err := c.r.ReadByte(); err != io.EOF {
+		t.Errorf("connection not closed after quit: %v", err)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	repo := repotest.New(map[string]string{"a": `"1"`})
+	_, addr := startServer(t, repo)
+	c := dial(t, addr)
+
+	// exptime up to 30 days is relative, larger is a Unix time
+	at := time.Now().Add(time.Hour).Unix()
+	steps := []struct {
+		send string
+		want string
+	}{
+		{"set rel 0 60 1\r\nx\r\n", "STORED"},
+		{fmt.Sprintf("set abs 0 %d 1\r\nx\r\n", at), "STORED"},
+		{"set gone 0 -1 1\r\nx\r\n", "STORED"},
+		{"touch a 120\r\n", "TOUCHED"},
+		{"touch missing 120\r\n", "NOT_FOUND"},
+		{"touch abs 0\r\n", "TOUCHED"},
+		{"touch rel x\r\n", "CLIENT_ERROR bad command line format"},
+	}
+	for _, step := range steps {
+		c.send(step.send)
+		if got := c.lines(1); got != step.want {
+			t.Errorf("%q = %q, want %q", step.send, got, step.want)
+		}
+	}
+
+	ctx := context.Background()
+	for key, want := range map[string]time.Duration{"rel": time.Minute, "a": 2 * time.Minute} {
+		item, err := repo.Get(ctx, key)
+		if left := time.Until(item.ExpiresAt); err != nil || left > want || left < want-5*time.Second {
+			t.Errorf("%s expires in %v, want %v: %v", key, left, want, err)
+		}
+	}
+	if item, _ := repo.Get(ctx, "abs"); !item.ExpiresAt.IsZero() {
+		t.Errorf("touch with zero exptime kept expiry %v", item.ExpiresAt)
+	}
+	// an item stored with an exptime in the past is never returned
+	c.send("get gone\r\n")
+	if got := c.lines(1); got != "END" {
+		t.Errorf("expired item returned: %q", got)
+	}
+}
+
+func TestCas(t *testing.T) {
+	repo := repotest.New(nil)
+	_, addr := startServer(t, repo)
+	c := dial(t, addr)
+
+	c.send("set k 0 0 1\r\n1\r\n")
+	c.lines(1)
+	_, version, _ := repo.GetVersioned(context.Background(), "k")
+
+	c.send("gets k\r\n")
+	if got, want := c.lines(3), fmt.Sprintf("VALUE k 0 1 %d|1|END", version); got != want {
+		t.Fatalf("gets = %q, want %q", got, want)
+	}
+
+	steps := []struct{ send, want string }{
+		{fmt.Sprintf("cas k 0 0 1 %d\r\n2\r\n", version), "STORED"},
+		// the version changed with the write above
+		{fmt.Sprintf("cas k 0 0 1 %d\r\n3\r\n", version), "EXISTS"},
+		{fmt.Sprintf("cas missing 0 0 1 %d\r\n3\r\n", version), "NOT_FOUND"},
+		{"cas k 0 0 1\r\n3\r\n", "CLIENT_ERROR bad command line format"},
+		{"cas k 0 0 1 x\r\n3\r\n", "CLIENT_ERROR bad command line format"},
+	}
+	for _, step := range steps {
+		c.send(step.send)
+		if got := c.lines(1); got != step.want {
+			t.Errorf("%q = %q, want %q", step.send, got, step.want)
+		}
+	}
+	if got, _ := repo.Value("k"); got != `"2"` {
+		t.Errorf("stored value = %s", got)
+	}
+
+	// a key deleted and created again does not reuse its version
+	repo.Delete(context.Background(), "k")
+	c.send("add k 0 0 1\r\n4\r\n")
+	c.lines(1)
+	c.send(fmt.Sprintf("cas k 0 0 1 %d\r\n5\r\n", version+1))
+	if got := c.lines(1); got != "EXISTS" {
+		t.Errorf("cas with version of deleted key = %q", got)
+	}
+}
+
+func TestPipelineAndNoreply(t *testing.T) {
+	_, addr := startServer(t, repotest.New(nil))
+	c := dial(t, addr)
+
+	c.send("set a 0 0 1 noreply\r\n1\r\nset b 0 0 1 noreply\r\n2\r\ndelete missing noreply\r\nget a b\r\n")
+	if got := c.lines(5); got != "VALUE a 0 1|1|VALUE b 0 1|2|END" {
+		t.Errorf("pipeline = %q", got)
+	}
+
+	c.send("set a 0 0 1\r\nxyz\r\n")
+	if got := c.lines(1); got != "CLIENT_ERROR bad data chunk" {
+		t.Errorf("bad data chunk = %q", got)
+	}
+	if _, err := c.r.ReadByte(); err != io.EOF {
+		t.Errorf("connection not closed after bad data chunk: %v", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	opts := testOptions(repotest.New(nil))
+	opts.Limits = ratelimit.New(config.RateLimits{Write: config.RateLimit{Rate: 1, Burst: 1}})
+	_, addr := serve(t, opts)
+	c := dial(t, addr)
+
+	c.send("set a 0 0 1\r\nx\r\nset a 0 0 1\r\ny\r\nget a\r\n")
+	want := "STORED|SERVER_ERROR Too many requests|VALUE a 0 1|x|END"
+	if got := c.lines(5); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	srv, addr := startServer(t, repotest.New(nil))
+	c := dial(t, addr)
+	c.send("version\r\n")
+	c.lines(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+	if _, err := c.r.ReadByte(); err != io.EOF {
+		t.Errorf("idle connection not closed: %v", err)
+	}
+	if conn, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+		conn.Close()
+		t.Error("listener still accepts connections")
+	}
+}
@@ -0,0 +1,582 @@
+package respserver
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vvjke314/vk-test-03-2025/config"
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"github.com/vvjke314/vk-test-03-2025/internal/entities"
+	"github.com/vvjke314/vk-test-03-2025/internal/i18n"
+	"github.com/vvjke314/vk-test-03-2025/internal/ratelimit"
+	"github.com/vvjke314/vk-test-03-2025/internal/textvalue"
+	"github.com/vvjke314/vk-test-03-2025/internal/usecases"
+)
+
+// command describes a supported Redis command
+type command struct {
+	// arity counts the arguments with the command name as Redis does:
+	// N means exactly N, -N at least N
+	arity   int
+	op      ratelimit.Op // How the command is accounted by the limits
+	timeout func(config.RouteTimeouts) time.Duration
+	run     func(s *session, ctx context.Context, args [][]byte) error
+}
+
+func noTimeout(config.RouteTimeouts) time.Duration       { return 0 }
+func getTimeout(t config.RouteTimeouts) time.Duration    { return t.Get }
+func updateTimeout(t config.RouteTimeouts) time.Duration { return t.Update }
+func deleteTimeout(t config.RouteTimeouts) time.Duration { return t.Delete }
+
+// commands supported by the server, by lower case name
+var commands = map[string]command{
+	"ping":    {-1, ratelimit.None, noTimeout, cmdPing},
+	"echo":    {2, ratelimit.None, noTimeout, cmdEcho},
+	"hello":   {-1, ratelimit.None, noTimeout, cmdHello},
+	"auth":    {-2, ratelimit.None, noTimeout, cmdOK},
+	"select":  {2, ratelimit.None, noTimeout, cmdSelect},
+	"client":  {-2, ratelimit.None, noTimeout, cmdClient},
+	"command": {-1, ratelimit.None, noTimeout, cmdCommand},
+	"info":    {-1, ratelimit.None, noTimeout, cmdInfo},
+	"quit":    {-1, ratelimit.None, noTimeout, cmdOK},
+
+	"get":    {2, ratelimit.Get, getTimeout, cmdGet},
+	"mget":   {-2, ratelimit.Get, getTimeout, cmdMGet},
+	"exists": {-2, ratelimit.Get, getTimeout, cmdExists},
+	"scan":   {-2, ratelimit.Get, getTimeout, cmdScan},
+	"ttl":    {2, ratelimit.Get, getTimeout, cmdTTL},
+	"pttl":   {2, ratelimit.Get, getTimeout, cmdPTTL},
+	"set":    {-3, ratelimit.Update, updateTimeout, cmdSet},
+	"mset":   {-3, ratelimit.Update, updateTimeout, cmdMSet},
+	"del":    {-2, ratelimit.Delete, deleteTimeout, cmdDel},
+
+	"expire":    {-3, ratelimit.Update, updateTimeout, expireCommand("expire", "ex")},
+	"pexpire":   {-3, ratelimit.Update, updateTimeout, expireCommand("pexpire", "px")},
+	"expireat":  {-3, ratelimit.Update, updateTimeout, expireCommand("expireat", "exat")},
+	"pexpireat": {-3, ratelimit.Update, updateTimeout, expireCommand("pexpireat", "pxat")},
+	"persist":   {2, ratelimit.Update, updateTimeout, cmdPersist},
+}
+
+// replyError is sent to the client as is
+type replyError string
+
+func (e replyError) Error() string { return string(e) }
+
+var (
+	errSyntax     = replyError("ERR syntax error")
+	errNotInteger = replyError("ERR value is not an integer or out of range")
+)
+
+// errorCode classifies an error, protocol level errors are validation ones
+func errorCode(err error) custom_errors.Code {
+	var re replyError
+	if errors.As(err, &re) {
+		return custom_errors.CodeValidation
+	}
+	return custom_errors.CodeOf(err)
+}
+
+// errorReply renders an error as a Redis error. Request errors use the
+// generic ERR prefix, others are prefixed with their code, e.g. TIMEOUT,
+// so clients can tell them apart.
+func errorReply(err error) string {
+	var re replyError
+	if errors.As(err, &re) {
+		return string(re)
+	}
+
+	code := custom_errors.CodeOf(err)
+	message := code.Title(i18n.English)
+	// internal details never leave the service
+	if e, ok := custom_errors.As(err); ok && code != custom_errors.CodeInternal {
+		message = e.Localize(i18n.English)
+	}
+
+	prefix := "ERR"
+	switch code {
+	case custom_errors.CodeValidation, custom_errors.CodeTooLarge, custom_errors.CodeInternal:
+	default:
+		prefix = strings.ToUpper(string(code))
+	}
+	return prefix + " " + sanitize(message)
+}
+
+// serverError tells whether an error is the server's fault
+func serverError(code custom_errors.Code) bool {
+	switch code {
+	case custom_errors.CodeTimeout, custom_errors.CodeUnavailable, custom_errors.CodeInternal:
+		return true
+	}
+	return false
+}
+
+func cmdOK(s *session, _ context.Context, _ [][]byte) error {
+	s.w.simple("OK")
+	return nil
+}
+
+func cmdPing(s *session, _ context.Context, args [][]byte) error {
+	switch len(args) {
+	case 0:
+		s.w.simple("PONG")
+	case 1:
+		s.w.bulk(args[0])
+	default:
+		return replyError("ERR wrong number of arguments for 'ping' command")
+	}
+	return nil
+}
+
+func cmdEcho(s *session, _ context.Context, args [][]byte) error {
+	s.w.bulk(args[0])
+	return nil
+}
+
+// cmdHello switches the protocol version, AUTH is accepted and ignored as
+// the listener has no authentication, like /kv
+func cmdHello(s *session, _ context.Context, args [][]byte) error {
+	resp3 := s.w.resp3
+	if len(args) > 0 {
+		switch string(args[0]) {
+		case "2":
+			resp3 = false
+		case "3":
+			resp3 = true
+		default:
+			return replyError("NOPROTO unsupported protocol version")
+		}
+		for i := 1; i < len(args); i++ {
+			switch strings.ToLower(string(args[i])) {
+			case "auth":
+				i += 2
+			case "setname":
+				i++
+				if i < len(args) {
+					s.name = string(args[i])
+				}
+			default:
+				return errSyntax
+			}
+			if i >= len(args) {
+				return errSyntax
+			}
+		}
+	}
+	s.w.resp3 = resp3
+
+	proto := int64(2)
+	if resp3 {
+		proto = 3
+	}
+	s.w.mapHeader(7)
+	s.w.bulkString("server")
+	s.w.bulkString("vault")
+	s.w.bulkString("version")
+	s.w.bulkString(redisVersion)
+	s.w.bulkString("proto")
+	s.w.integer(proto)
+	s.w.bulkString("id")
+	s.w.integer(s.id)
+	s.w.bulkString("mode")
+	s.w.bulkString("standalone")
+	s.w.bulkString("role")
+	s.w.bulkString("master")
+	s.w.bulkString("modules")
+	s.w.array(0)
+	return nil
+}
+
+// redisVersion is the Redis version whose commands are emulated
+const redisVersion = "7.0.0"
+
+func cmdSelect(s *session, _ context.Context, args [][]byte) error {
+	if string(args[0]) != "0" {
+		return replyError("ERR DB index is out of range")
+	}
+	s.w.simple("OK")
+	return nil
+}
+
+func cmdClient(s *session, _ context.Context, args [][]byte) error {
+	switch strings.ToLower(string(args[0])) {
+	case "setname":
+		if len(args) != 2 {
+			return errSyntax
+		}
+		s.name = string(args[1])
+		s.w.simple("OK")
+	case "getname":
+		if s.name == "" {
+			s.w.null()
+		} else {
+			s.w.bulkString(s.name)
+		}
+	case "id":
+		s.w.integer(s.id)
+	case "setinfo":
+		s.w.simple("OK")
+	default:
+		return replyError("ERR unknown subcommand '" + sanitize(string(args[0])) + "'")
+	}
+	return nil
+}
+
+// cmdCommand answers the introspection clients run on connect with nothing
+func cmdCommand(s *session, _ context.Context, _ [][]byte) error {
+	s.w.array(0)
+	return nil
+}
+
+func cmdInfo(s *session, _ context.Context, _ [][]byte) error {
+	s.w.bulkString("# Server\r\nredis_version:" + redisVersion + "\r\nredis_mode:standalone\r\n" +
+		"# Persistence\r\nloading:0\r\n")
+	return nil
+}
+
+func cmdGet(s *session, ctx context.Context, args [][]byte) error {
+	item, err := s.srv.opts.UseCase.Get(ctx, string(args[0]))
+	switch {
+	case errors.Is(err, custom_errors.ErrKeyNotExists):
+		s.w.null()
+	case err != nil:
+		return err
+	default:
+		s.w.bulk(textvalue.Decode(item.Value))
+	}
+	return nil
+}
+
+// cmdMGet reads keys one by one, a missing or invalid key is nil
+func cmdMGet(s *session, ctx context.Context, args [][]byte) error {
+	values := make([]*[]byte, len(args))
+	for i, key := range args {
+		item, err := s.srv.opts.UseCase.Get(ctx, string(key))
+		switch code := custom_errors.CodeOf(err); {
+		case err == nil:
+			value := textvalue.Decode(item.Value)
+			values[i] = &value
+		case code != custom_errors.CodeNotFound && code != custom_errors.CodeValidation:
+			return err
+		}
+	}
+
+	s.w.array(len(values))
+	for _, value := range values {
+		if value == nil {
+			s.w.null()
+		} else {
+			s.w.bulk(*value)
+		}
+	}
+	return nil
+}
+
+func cmdExists(s *session, ctx context.Context, args [][]byte) error {
+	var n int64
+	for _, key := range args {
+		_, err := s.srv.opts.UseCase.Get(ctx, string(key))
+		switch {
+		case err == nil:
+			n++
+		case !errors.Is(err, custom_errors.ErrKeyNotExists):
+			return err
+		}
+	}
+	s.w.integer(n)
+	return nil
+}
+
+// cmdSet supports NX, XX, the expiration options and KEEPTTL. A key set
+// without them does not expire, as in Redis.
+func cmdSet(s *session, ctx context.Context, args [][]byte) error {
+	var nx, xx, keepTTL bool
+	var expiresAt time.Time
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToLower(string(args[i])); opt {
+		case "nx":
+			nx = true
+		case "xx":
+			xx = true
+		case "keepttl":
+			keepTTL = true
+		case "ex", "px", "exat", "pxat":
+			if !expiresAt.IsZero() || i+1 == len(args) {
+				return errSyntax
+			}
+			i++
+			at, err := expiryTime(opt, args[i], "set", true)
+			if err != nil {
+				return err
+			}
+			expiresAt = at
+		default:
+			return errSyntax
+		}
+	}
+	if nx && xx || keepTTL && !expiresAt.IsZero() {
+		return errSyntax
+	}
+
+	value, err := textvalue.Encode(args[1])
+	if err != nil {
+		return err
+	}
+	item := entities.VaultItem{Key: string(args[0]), Value: value, ExpiresAt: expiresAt}
+	uc := s.srv.opts.UseCase
+	switch {
+	case nx:
+		err = uc.InsertValue(ctx, item)
+	case keepTTL:
+		err = setKeepTTL(ctx, uc, item, xx)
+	case xx:
+		err = uc.UpdateValue(ctx, item)
+	default:
+		_, err = uc.PutValue(ctx, item)
+	}
+
+	switch {
+	case nx && errors.Is(err, custom_errors.ErrKeyExists), xx && errors.Is(err, custom_errors.ErrKeyNotExists):
+		s.w.null()
+	case err != nil:
+		return err
+	default:
+		s.w.simple("OK")
+	}
+	return nil
+}
+
+// setKeepTTL writes item with the expiry its key already has. The value is
+// swapped against the version the expiry was read with, a write of another
+// client in between makes it read again.
+func setKeepTTL(ctx context.Context, uc *usecases.KeyValueUseCase, item entities.VaultItem, xx bool) error {
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		var current entities.VaultItem
+		var version uint64
+		current, version, err = uc.GetVersioned(ctx, item.Key)
+		switch {
+		case errors.Is(err, custom_errors.ErrKeyNotExists):
+			if xx {
+				return err
+			}
+			// there is no expiry to keep
+			err = uc.InsertValue(ctx, item)
+			if !errors.Is(err, custom_errors.ErrKeyExists) {
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			item.ExpiresAt = current.ExpiresAt
+			err = uc.CompareAndSwap(ctx, item, version)
+			if !errors.Is(err, custom_errors.ErrConflict) && !errors.Is(err, custom_errors.ErrKeyNotExists) {
+				return err
+			}
+		}
+	}
+	return err
+}
+
+// expiryTime converts the argument of option opt, one of EX, PX, EXAT and
+// PXAT, to the time the key expires at. SET only takes positive ones.
+func expiryTime(opt string, arg []byte, cmd string, positive bool) (time.Time, error) {
+	n, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return time.Time{}, errNotInteger
+	}
+	unit := time.Millisecond
+	if opt == "ex" || opt == "exat" {
+		unit = time.Second
+	}
+	if positive && n <= 0 || n > math.MaxInt64/int64(unit) || n < math.MinInt64/int64(unit) {
+		return time.Time{}, replyError("ERR invalid expire time in '" + cmd + "' command")
+	}
+	if opt == "ex" || opt == "px" {
+		return time.Now().Add(time.Duration(n) * unit), nil
+	}
+	return time.Unix(0, 0).Add(time.Duration(n) * unit), nil
+}
+
+// cmdMSet checks every pair before writing them one by one,
+// unlike Redis it is not atomic
+func cmdMSet(s *session, ctx context.Context, args [][]byte) error {
+	if len(args)%2 != 0 {
+		return replyError("ERR wrong number of arguments for 'mset' command")
+	}
+	uc := s.srv.opts.UseCase
+	rules := uc.Rules()
+	items := make([]entities.VaultItem, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		value, err := textvalue.Encode(args[i+1])
+		if err == nil {
+			err = rules.Value(value)
+		}
+		if err := custom_errors.NewValidationErrors(rules.Key(string(args[i])), err); err != nil {
+			return err
+		}
+		items = append(items, entities.VaultItem{Key: string(args[i]), Value: value})
+	}
+
+	for _, item := range items {
+		if _, err := uc.PutValue(ctx, item); err != nil {
+			return err
+		}
+	}
+	s.w.simple("OK")
+	return nil
+}
+
+func cmdDel(s *session, ctx context.Context, args [][]byte) error {
+	var n int64
+	for _, key := range args {
+		err := s.srv.opts.UseCase.DeleteRow(ctx, string(key))
+		switch {
+		case err == nil:
+			n++
+		case !errors.Is(err, custom_errors.ErrKeyNotExists):
+			return err
+		}
+	}
+	s.w.integer(n)
+	return nil
+}
+
+// cmdScan iterates keys in order, COUNT is the number of keys read per call
+// before MATCH and TYPE filter them, as in Redis
+func cmdScan(s *session, ctx context.Context, args [][]byte) error {
+	id, err := strconv.ParseUint(string(args[0]), 10, 64)
+	if err != nil {
+		return replyError("ERR invalid cursor")
+	}
+	var after string
+	if id != 0 {
+		var ok bool
+		if after, ok = s.srv.cursors.load(id); !ok {
+			return replyError("ERR invalid cursor")
+		}
+	}
+
+	pattern, count, onlyStrings := "*", 10, true
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return errSyntax
+		}
+		value := string(args[i+1])
+		switch strings.ToLower(string(args[i])) {
+		case "match":
+			pattern = value
+		case "count":
+			if count, err = strconv.Atoi(value); err != nil || count < 1 {
+				return errSyntax
+			}
+			count = min(count, usecases.MaxListLimit)
+		case "type":
+			onlyStrings = strings.EqualFold(value, "string")
+		default:
+			return errSyntax
+		}
+	}
+
+	items, err := s.srv.opts.UseCase.List(ctx, literalPrefix(pattern), after, count)
+	if err != nil {
+		return err
+	}
+	var keys []string
+	for _, item := range items {
+		if onlyStrings && matchGlob(pattern, item.Key) {
+			keys = append(keys, item.Key)
+		}
+	}
+
+	next := uint64(0)
+	if len(items) == count {
+		next = s.srv.cursors.save(items[len(items)-1].Key)
+	}
+	s.w.array(2)
+	s.w.bulkString(strconv.FormatUint(next, 10))
+	s.w.array(len(keys))
+	for _, key := range keys {
+		s.w.bulkString(key)
+	}
+	return nil
+}
+
+// cmdTTL answers the seconds left until the key expires, -1 for keys
+// without expiry and -2 for missing keys
+func cmdTTL(s *session, ctx context.Context, args [][]byte) error {
+	return ttl(s, ctx, string(args[0]), time.Second)
+}
+
+// cmdPTTL is cmdTTL in milliseconds
+func cmdPTTL(s *session, ctx context.Context, args [][]byte) error {
+	return ttl(s, ctx, string(args[0]), time.Millisecond)
+}
+
+func ttl(s *session, ctx context.Context, key string, unit time.Duration) error {
+	item, err := s.srv.opts.UseCase.Get(ctx, key)
+	switch {
+	case errors.Is(err, custom_errors.ErrKeyNotExists):
+		s.w.integer(-2)
+	case err != nil:
+		return err
+	case item.ExpiresAt.IsZero():
+		s.w.integer(-1)
+	default:
+		// rounded like Redis does
+		left := max(time.Until(item.ExpiresAt), 0)
+		s.w.integer(int64((left + unit/2) / unit))
+	}
+	return nil
+}
+
+// expireCommand returns EXPIRE, PEXPIRE, EXPIREAT or PEXPIREAT, which take
+// the time like the SET option opt. They answer 1 when the expiry was set
+// and 0 for missing keys, a time in the past deletes the key. The NX, XX,
+// GT and LT conditions are not supported.
+func expireCommand(name, opt string) func(s *session, ctx context.Context, args [][]byte) error {
+	return func(s *session, ctx context.Context, args [][]byte) error {
+		if len(args) > 2 {
+			return replyError("ERR conditions of '" + name + "' command are not supported")
+		}
+		at, err := expiryTime(opt, args[1], name, false)
+		if err != nil {
+			return err
+		}
+		err = s.srv.opts.UseCase.Expire(ctx, string(args[0]), at)
+		switch {
+		case errors.Is(err, custom_errors.ErrKeyNotExists):
+			s.w.integer(0)
+		case err != nil:
+			return err
+		default:
+			s.w.integer(1)
+		}
+		return nil
+	}
+}
+
+// cmdPersist removes the expiry of a key, it answers 1 when there was one
+func cmdPersist(s *session, ctx context.Context, args [][]byte) error {
+	uc := s.srv.opts.UseCase
+	item, err := uc.Get(ctx, string(args[0]))
+	if err == nil && item.ExpiresAt.IsZero() {
+		s.w.integer(0)
+		return nil
+	}
+	if err == nil {
+		err = uc.Expire(ctx, item.Key, time.Time{})
+	}
+	switch {
+	case errors.Is(err, custom_errors.ErrKeyNotExists):
+		s.w.integer(0)
+	case err != nil:
+		return err
+	default:
+		s.w.integer(1)
+	}
+	return nil
+}
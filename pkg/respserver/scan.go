@@ -0,0 +1,144 @@
+package respserver
+
+import (
+	"strings"
+	"sync"
+)
+
+// maxCursors bounds the number of SCAN iterations remembered at once
+const maxCursors = 4096
+
+// cursors remembers the last key returned by SCAN iterations. Clients parse
+// cursors as numbers, so keys can not be sent as cursors themselves. The
+// store is shared by connections because pooled clients may continue an
+// iteration on another one. The oldest cursors are forgotten first.
+type cursors struct {
+	mu    sync.Mutex
+	next  uint64
+	after map[uint64]string
+	order []uint64
+}
+
+func newCursors() *cursors {
+	return &cursors{next: 1, after: make(map[uint64]string)}
+}
+
+// save returns a new cursor continuing after key
+func (c *cursors) save(key string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.order) >= maxCursors {
+		delete(c.after, c.order[0])
+		c.order = c.order[1:]
+	}
+	id := c.next
+	c.next++
+	c.after[id] = key
+	c.order = append(c.order, id)
+	return id
+}
+
+// load returns the key a cursor continues after, it stays valid so a failed
+// call can be retried
+func (c *cursors) load(id uint64) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key, ok := c.after[id]
+	return key, ok
+}
+
+// literalPrefix returns the part of a glob pattern before its first
+// wildcard, every matching key starts with it
+func literalPrefix(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*', '?', '[':
+			return b.String()
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+		}
+		b.WriteByte(pattern[i])
+	}
+	return b.String()
+}
+
+// matchGlob reports whether s matches a Redis glob pattern: * matches any
+// bytes, ? a single byte, [abc], [^abc] and [a-z] a byte of the class and
+// \ escapes the next byte. Backtracking only to the last star keeps it
+// linear in the length of s times the pattern.
+func matchGlob(pattern, s string) bool {
+	p, i := 0, 0
+	star, starI := -1, 0
+	for i < len(s) {
+		if p < len(pattern) {
+			switch c := pattern[p]; c {
+			case '*':
+				star, starI = p, i
+				p++
+				continue
+			case '?':
+				p++
+				i++
+				continue
+			case '[':
+				if rest, ok := matchClass(pattern[p+1:], s[i]); ok {
+					p = len(pattern) - len(rest)
+					i++
+					continue
+				}
+			default:
+				width := 1
+				if c == '\\' && p+1 < len(pattern) {
+					c, width = pattern[p+1], 2
+				}
+				if c == s[i] {
+					p += width
+					i++
+					continue
+				}
+			}
+		}
+		if star < 0 {
+			return false
+		}
+		starI++
+		p, i = star+1, starI
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchClass matches c against a class whose opening bracket is already
+// consumed and returns the pattern after the closing one
+func matchClass(pattern string, c byte) (rest string, ok bool) {
+	negate := strings.HasPrefix(pattern, "^")
+	if negate {
+		pattern = pattern[1:]
+	}
+	matched := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) >= 2:
+			matched = matched || pattern[1] == c
+			pattern = pattern[2:]
+		case len(pattern) >= 3 && pattern[1] == '-' && pattern[2] != ']':
+			lo, hi := pattern[0], pattern[2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			matched = matched || (c >= lo && c <= hi)
+			pattern = pattern[3:]
+		default:
+			matched = matched || pattern[0] == c
+			pattern = pattern[1:]
+		}
+	}
+	// an unterminated class ends with the pattern
+	pattern = strings.TrimPrefix(pattern, "]")
+	return pattern, matched != negate
+}
@@ -0,0 +1,66 @@
+package respserver
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"user:*", "user:1", true},
+		{"user:*", "users", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "heeeello", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[b-a]llo", "hbllo", true},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{"*a*a*a*b", "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", false},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v", tt.pattern, tt.s, got)
+		}
+	}
+}
+
+func TestLiteralPrefix(t *testing.T) {
+	tests := map[string]string{
+		"user:*":    "user:",
+		"exact":     "exact",
+		"a?b":       "a",
+		"[ab]*":     "",
+		`a\*b*`:     "a*b",
+		"*":         "",
+		`trailing\`: `trailing\`,
+	}
+	for pattern, want := range tests {
+		if got := literalPrefix(pattern); got != want {
+			t.Errorf("literalPrefix(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+}
+
+func TestCursors(t *testing.T) {
+	c := newCursors()
+	first := c.save("a")
+	for i := 0; i < maxCursors; i++ {
+		c.save("b")
+	}
+	if _, ok := c.load(first); ok {
+		t.Error("oldest cursor is kept beyond maxCursors")
+	}
+	last := c.save("c")
+	if key, ok := c.load(last); !ok || key != "c" {
+		t.Errorf("load(%d) = %q, %v", last, key, ok)
+	}
+}
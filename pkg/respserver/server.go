@@ -0,0 +1,261 @@
+// Package respserver serves a subset of the Redis protocol (RESP2 and RESP3)
+// on top of the key-value use case, so Redis clients can use the vault
+package respserver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vvjke314/vk-test-03-2025/config"
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+	"github.com/vvjke314/vk-test-03-2025/internal/metrics"
+	"github.com/vvjke314/vk-test-03-2025/internal/ratelimit"
+	"github.com/vvjke314/vk-test-03-2025/internal/tracing"
+	"github.com/vvjke314/vk-test-03-2025/internal/usecases"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+)
+
+// Options groups dependencies and settings of the Redis protocol server
+type Options struct {
+	UseCase    *usecases.KeyValueUseCase
+	Logger     logger.Logger
+	Timeouts   config.RouteTimeouts
+	Limits     *ratelimit.Limits // Budgets shared with the other front ends, nil limits nothing
+	MaxCommand int               // Largest total size of command arguments in bytes
+}
+
+// Server accepts Redis protocol connections
+type Server struct {
+	opts    Options
+	cursors *cursors
+	lastID  atomic.Int64
+
+	mu     sync.Mutex
+	lis    net.Listener
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// New creates a server, it does not listen until Serve
+func New(opts Options) *Server {
+	if opts.MaxCommand <= 0 {
+		opts.MaxCommand = 1 << 20
+	}
+	return &Server{
+		opts:    opts,
+		cursors: newCursors(),
+		conns:   make(map[net.Conn]struct{}),
+	}
+}
+
+// Serve accepts connections on lis until Shutdown, after which it returns nil
+func (s *Server) Serve(lis net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		lis.Close()
+		return nil
+	}
+	s.lis = lis
+	s.mu.Unlock()
+
+	for {
+		nc, err := lis.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			nc.Close()
+			return nil
+		}
+		s.conns[nc] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serveConn(nc)
+	}
+}
+
+// Shutdown stops accepting connections and closes every connection once its
+// current command is answered. Connections still busy when ctx is done are
+// closed at once.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	if s.lis != nil {
+		s.lis.Close()
+	}
+	// an expired read deadline ends connections waiting for the next command
+	for nc := range s.conns {
+		nc.SetReadDeadline(time.Now())
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for nc := range s.conns {
+			nc.Close()
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// serveConn runs commands of a connection until it is closed
+func (s *Server) serveConn(nc net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, nc)
+		s.mu.Unlock()
+		nc.Close()
+		s.wg.Done()
+	}()
+
+	// the buffer holds a whole line, ReadSlice fails on longer ones
+	r := &reader{r: bufio.NewReaderSize(nc, maxLineLength), maxBytes: s.opts.MaxCommand}
+	sess := &session{
+		srv:    s,
+		id:     s.lastID.Add(1),
+		remote: nc.RemoteAddr().String(),
+		client: ratelimit.AddrKey(nc.RemoteAddr()),
+		w:      &writer{w: bufio.NewWriter(nc)},
+	}
+
+	for {
+		args, err := r.readCommand()
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				sess.w.error("ERR Protocol error: " + strings.TrimPrefix(err.Error(), errProtocol.Error()+": "))
+				sess.w.w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := sess.exec(args)
+		// pipelined commands are answered together
+		if quit || r.r.Buffered() == 0 {
+			if err := sess.w.w.Flush(); err != nil || quit {
+				return
+			}
+		}
+	}
+}
+
+// session is the state of a connection
+type session struct {
+	srv    *Server
+	id     int64
+	remote string
+	client string // Key of the client in the limits
+	name   string
+	w      *writer
+}
+
+// exec runs a command and writes its reply, it reports whether the
+// connection should be closed
+func (s *session) exec(args [][]byte) (quit bool) {
+	start := time.Now()
+	name := strings.ToLower(string(args[0]))
+	cmd, ok := commands[name]
+	if !ok {
+		s.w.error("ERR unknown command '" + sanitize(string(args[0])) + "'")
+		metrics.ObserveRESP("unknown", string(custom_errors.CodeValidation), time.Since(start))
+		return false
+	}
+
+	ctx := logger.ContextWithRequestID(context.Background(), uuid.NewString())
+	ctx, span := tracing.StartServer(ctx, http.Header{}, "RESP "+strings.ToUpper(name),
+		attribute.String("resp.command", name),
+		attribute.String("network.peer.address", s.remote),
+	)
+	defer span.End()
+	if timeout := cmd.timeout(s.srv.opts.Timeouts); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var err error
+	if cmd.arity > 0 && len(args) != cmd.arity || cmd.arity < 0 && len(args) < -cmd.arity {
+		err = replyError("ERR wrong number of arguments for '" + name + "' command")
+	} else if release, limitErr := s.srv.opts.Limits.Take(s.client, cmd.op); limitErr != nil {
+		err = limitErr
+	} else {
+		err = cmd.run(s, ctx, args[1:])
+		release()
+	}
+
+	result := "ok"
+	log := logger.FromContext(ctx, s.srv.opts.Logger)
+	if err != nil {
+		code := errorCode(err)
+		result = string(code)
+		s.w.error(errorReply(err))
+		if serverError(code) {
+			span.SetStatus(otelcodes.Error, err.Error())
+			log.Error("command failed", logger.F("command", name), logger.Err(err))
+		} else {
+			log.Warn("command rejected", logger.F("command", name), logger.Err(err))
+		}
+	}
+
+	duration := time.Since(start)
+	metrics.ObserveRESP(name, result, duration)
+	log.Info("resp command",
+		logger.F("command", name),
+		logger.F("remote", s.remote),
+		logger.F("result", result),
+		logger.F("duration_ms", duration.Milliseconds()),
+	)
+	return name == "quit"
+}
+
+// sanitize makes client input safe to echo in a simple string
+func sanitize(s string) string {
+	if len(s) > 128 {
+		s = s[:128]
+	}
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, s)
+}
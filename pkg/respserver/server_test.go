@@ -0,0 +1,354 @@
+package respserver
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vvjke314/vk-test-03-2025/config"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+	"github.com/vvjke314/vk-test-03-2025/internal/ratelimit"
+	"github.com/vvjke314/vk-test-03-2025/internal/repository/repotest"
+	"github.com/vvjke314/vk-test-03-2025/internal/usecases"
+	"github.com/vvjke314/vk-test-03-2025/internal/validation"
+)
+
+// startServer serves repo on a local port
+func startServer(t *testing.T, repo *repotest.Repo) (*Server, string) {
+	return serve(t, testOptions(repo))
+}
+
+// testOptions serves repo with the rules and timeouts of the tests
+func testOptions(repo *repotest.Repo) Options {
+	uc := usecases.NewKeyValueUseCase(repo, validation.Rules{
+		MaxKeyLen:     256,
+		MaxValueBytes: 1 << 20,
+		KeyPattern:    regexp.MustCompile(`^[A-Za-z0-9._~:@-]*$`),
+		KeyCharset:    "A-Za-z0-9._~:@-",
+	})
+	timeout := 3 * time.Second
+	return Options{
+		UseCase:    uc,
+		Logger:     logger.NewWriterLogger(io.Discard),
+		Timeouts:   config.RouteTimeouts{Get: timeout, Create: timeout, Update: timeout, Delete: timeout},
+		MaxCommand: 1 << 20,
+	}
+}
+
+// serve starts a server on a local port, it is shut down with the test
+func serve(t *testing.T, opts Options) (*Server, string) {
+	t.Helper()
+	srv := New(opts)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(lis)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	})
+	return srv, lis.Addr().String()
+}
+
+// client is a minimal Redis client rendering replies as strings:
+// simple strings, errors and integers as sent, bulk strings as their
+// content, nulls as (nil) or _ and aggregates as [a b ...]
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dial(t *testing.T, addr string) *client {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	t.Cleanup(func() { conn.Close() })
+	return &client{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *client) send(args ...string) {
+	c.t.Helper()
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) do(args ...string) string {
+	c.t.Helper()
+	c.send(args...)
+	return c.reply()
+}
+
+func (c *client) reply() string {
+	c.t.Helper()
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("read reply: %v", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	switch line[0] {
+	case '_':
+		return "_"
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return "(nil)"
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			c.t.Fatal(err)
+		}
+		return string(buf[:n])
+	case '*', '%':
+		n, _ := strconv.Atoi(line[1:])
+		if line[0] == '%' {
+			n *= 2
+		}
+		items := make([]string, n)
+		for i := range items {
+			items[i] = c.reply()
+		}
+		return "[" + strings.Join(items, " ") + "]"
+	}
+	return line
+}
+
+func TestCommands(t *testing.T) {
+	repo := repotest.New(map[string]string{"json": `{"a":1}`})
+	_, addr := startServer(t, repo)
+	c := dial(t, addr)
+
+	steps := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"PING"}, "+PONG"},
+		{[]string{"SET", "a", "hello"}, "+OK"},
+		{[]string{"GET", "a"}, "hello"},
+		{[]string{"GET", "json"}, `{"a":1}`},
+		{[]string{"SET", "a", "x", "NX"}, "(nil)"},
+		{[]string{"SET", "b", "y", "XX"}, "(nil)"},
+		{[]string{"set", "b", "y", "nx"}, "+OK"},
+		{[]string{"SET", "b", "z", "XX"}, "+OK"},
+		{[]string{"SET", "b", "z", "NX", "XX"}, "-ERR syntax error"},
+		{[]string{"MSET", "c", "1", "d", ""}, "+OK"},
+		{[]string{"MGET", "a", "b", "missing", "c", "d"}, "[hello z (nil) 1 ]"},
+		{[]string{"EXISTS", "a", "b", "missing", "a"}, ":3"},
+		{[]string{"TTL", "a"}, ":-1"},
+		{[]string{"TTL", "missing"}, ":-2"},
+		{[]string{"DEL", "a", "missing"}, ":1"},
+		{[]string{"GET", "a"}, "(nil)"},
+		{[]string{"SET", "bad=key", "v"}, "-ERR key may only contain characters [A-Za-z0-9._~:@-]"},
+		{[]string{"SET", "bin", "\xff"}, "-ERR value must be UTF-8 text, binary values are not supported"},
+		{[]string{"GET"}, "-ERR wrong number of arguments for 'get' command"},
+		{[]string{"FLUSHALL"}, "-ERR unknown command 'FLUSHALL'"},
+		{[]string{"SELECT", "1"}, "-ERR DB index is out of range"},
+		{[]string{"CLIENT", "SETNAME", "tool"}, "+OK"},
+		{[]string{"CLIENT", "GETNAME"}, "tool"},
+		{[]string{"HELLO", "3"}, "[server vault version 7.0.0 proto :3 id :1 mode standalone role master modules []]"},
+		{[]string{"GET", "a"}, "_"},
+		{[]string{"HELLO", "2", "AUTH", "default", "secret"}, "[server vault version 7.0.0 proto :2 id :1 mode standalone role master modules []]"},
+		{[]string{"GET", "a"}, "(nil)"},
+	}
+	for _, step := range steps {
+		if got := c.do(step.args...); got != step.want {
+			t.Errorf("%q = %q, want %q", step.args, got, step.want)
+		}
+	}
+
+	// Redis strings are stored as JSON strings readable over HTTP
+	if got, _ := repo.Value("c"); got != `"1"` {
+		t.Errorf("stored value = %s", got)
+	}
+
+	if got := c.do("QUIT"); got != "+OK" {
+		t.Errorf("QUIT = %q", got)
+	}
+	if _, err := c.r.ReadByte(); err != io.EOF {
+		t.Errorf("connection not closed after QUIT: %v", err)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	repo := repotest.New(nil)
+	_, addr := startServer(t, repo)
+	c := dial(t, addr)
+
+	at := strconv.FormatInt(time.Now().Add(time.Hour).UnixMilli(), 10)
+	steps := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"SET", "k", "1", "EX", "100"}, "+OK"},
+		{[]string{"TTL", "k"}, ":100"},
+		{[]string{"SET", "k", "2", "KEEPTTL"}, "+OK"},
+		{[]string{"TTL", "k"}, ":100"},
+		{[]string{"SET", "k", "3"}, "+OK"},
+		{[]string{"TTL", "k"}, ":-1"},
+		{[]string{"SET", "k", "4", "XX", "PXAT", at}, "+OK"},
+		{[]string{"TTL", "k"}, ":3600"},
+		{[]string{"EXPIRE", "k", "50"}, ":1"},
+		{[]string{"TTL", "k"}, ":50"},
+		{[]string{"PERSIST", "k"}, ":1"},
+		{[]string{"PERSIST", "k"}, ":0"},
+		{[]string{"TTL", "k"}, ":-1"},
+		{[]string{"EXPIRE", "missing", "10"}, ":0"},
+		{[]string{"SET", "new", "1", "KEEPTTL"}, "+OK"},
+		{[]string{"TTL", "new"}, ":-1"},
+		{[]string{"EXPIREAT", "new", "1"}, ":1"},
+		{[]string{"GET", "new"}, "(nil)"},
+		{[]string{"SET", "k", "1", "EX", "0"}, "-ERR invalid expire time in 'set' command"},
+		{[]string{"SET", "k", "1", "EX", "1", "PX", "1"}, "-ERR syntax error"},
+		{[]string{"SET", "k", "1", "EX", "1", "KEEPTTL"}, "-ERR syntax error"},
+		{[]string{"SET", "k", "1", "EX"}, "-ERR syntax error"},
+		{[]string{"SET", "k", "1", "EX", "soon"}, "-ERR value is not an integer or out of range"},
+		{[]string{"EXPIRE", "k", "10", "NX"}, "-ERR conditions of 'expire' command are not supported"},
+		{[]string{"PEXPIRE", "k", "30"}, ":1"},
+	}
+	for _, step := range steps {
+		if got := c.do(step.args...); got != step.want {
+			t.Errorf("%q = %q, want %q", step.args, got, step.want)
+		}
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if got := c.do("GET", "k"); got != "(nil)" {
+		t.Errorf("GET of expired key = %q", got)
+	}
+	if got := c.do("TTL", "k"); got != ":-2" {
+		t.Errorf("TTL of expired key = %q", got)
+	}
+}
+
+func TestPipelineAndInline(t *testing.T) {
+	_, addr := startServer(t, repotest.New(nil))
+	c := dial(t, addr)
+
+	c.send("SET", "k", "1")
+	c.send("GET", "k")
+	if got := c.reply() + " " + c.reply(); got != "+OK 1" {
+		t.Errorf("pipeline = %q", got)
+	}
+
+	io.WriteString(c.conn, "ECHO hi\r\n\r\nPING\n")
+	if got := c.reply() + " " + c.reply(); got != "hi +PONG" {
+		t.Errorf("inline = %q", got)
+	}
+
+	// inline lines longer than the default bufio buffer are read whole
+	long := strings.Repeat("x", 10000)
+	io.WriteString(c.conn, "ECHO "+long+"\r\n")
+	if got := c.reply(); got != long {
+		t.Errorf("long inline echoed %d bytes", len(got))
+	}
+
+	io.WriteString(c.conn, "*1\r\n$3\r\nGETX\r\n")
+	if got := c.reply(); got != "-ERR Protocol error: bulk string is not terminated" {
+		t.Errorf("malformed bulk = %q", got)
+	}
+	if _, err := c.r.ReadByte(); err != io.EOF {
+		t.Errorf("connection not closed after protocol error: %v", err)
+	}
+
+	c = dial(t, addr)
+	io.WriteString(c.conn, "ECHO "+strings.Repeat("x", maxLineLength)+"\r\n")
+	if got := c.reply(); got != "-ERR Protocol error: line too long" {
+		t.Errorf("line over the limit = %.40q", got)
+	}
+}
+
+func TestScan(t *testing.T) {
+	repo := repotest.New(nil)
+	for _, key := range []string{"user:1", "user:2", "user:3", "user:10", "other", "zzz"} {
+		repo.Set(key, `1`)
+	}
+	_, addr := startServer(t, repo)
+	c := dial(t, addr)
+
+	var keys []string
+	cursor := "0"
+	for calls := 0; calls < 10; calls++ {
+		c.send("SCAN", cursor, "MATCH", "user:?", "COUNT", "2")
+		reply := c.reply()
+		next, page, ok := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(reply, "["), "]"), " ")
+		if !ok {
+			t.Fatalf("SCAN reply %q", reply)
+		}
+		cursor = next
+		keys = append(keys, strings.Fields(strings.Trim(page, "[]"))...)
+		if cursor == "0" {
+			break
+		}
+	}
+	if strings.Join(keys, " ") != "user:1 user:2 user:3" || cursor != "0" {
+		t.Errorf("scanned %v, cursor %s", keys, cursor)
+	}
+
+	if got := c.do("SCAN", "0", "COUNT", "100"); got != "[0 [other user:1 user:10 user:2 user:3 zzz]]" {
+		t.Errorf("SCAN all = %q", got)
+	}
+	if got := c.do("SCAN", "0", "TYPE", "hash"); got != "[0 []]" {
+		t.Errorf("SCAN TYPE hash = %q", got)
+	}
+	if got := c.do("SCAN", "12345"); got != "-ERR invalid cursor" {
+		t.Errorf("SCAN unknown cursor = %q", got)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	opts := testOptions(repotest.New(map[string]string{"a": `"x"`}))
+	opts.Limits = ratelimit.New(config.RateLimits{Read: config.RateLimit{Rate: 1, Burst: 1}})
+	_, addr := serve(t, opts)
+	c := dial(t, addr)
+
+	for _, step := range []struct{ args, want string }{
+		{"GET a", "x"},
+		{"GET a", "-RATE_LIMITED Too many requests"},
+		{"PING", "+PONG"},
+		{"SET a y", "+OK"},
+	} {
+		if got := c.do(strings.Fields(step.args)...); got != step.want {
+			t.Errorf("%s: got %q, want %q", step.args, got, step.want)
+		}
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	srv, addr := startServer(t, repotest.New(nil))
+	c := dial(t, addr)
+	if got := c.do("PING"); got != "+PONG" {
+		t.Fatalf("PING = %q", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+	if _, err := c.r.ReadByte(); err != io.EOF {
+		t.Errorf("idle connection not closed: %v", err)
+	}
+	if conn, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+		conn.Close()
+		t.Error("listener still accepts connections")
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vvjke314/vk-test-03-2025/internal/backup"
+)
+
+// serve sends a request without body to router
+func serve(router *chi.Mux, method, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec
+}
+
+func TestBackupRestore(t *testing.T) {
+	router := testRouter(t)
+	importBody(t, router, "", `{"key":"a","value":1}`+"\n"+`{"key":"b","value":{"x":"é"}}`)
+
+	rec := serve(router, http.MethodPost, "/admin/backups")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body)
+	}
+	var info backup.Info
+	json.Unmarshal(rec.Body.Bytes(), &info)
+	if info.Records == nil || *info.Records != 2 {
+		t.Fatalf("unexpected info %s", rec.Body)
+	}
+
+	rec = serve(router, http.MethodGet, "/admin/backups/"+info.Name)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), info.SHA256) {
+		t.Errorf("verify: %d %s", rec.Code, rec.Body)
+	}
+
+	// the restore brings back a changed value
+	importBody(t, router, "mode=overwrite", `{"key":"a","value":2}`)
+	rec = serve(router, http.MethodPost, "/admin/backups/"+info.Name+"/restore?mode=overwrite")
+	var report backup.RestoreReport
+	json.Unmarshal(rec.Body.Bytes(), &report)
+	if rec.Code != http.StatusOK || report.Updated != 2 || !report.Verified {
+		t.Fatalf("restore: %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(router, http.MethodGet, "/kv/a"); !strings.Contains(rec.Body.String(), `"value":1`) {
+		t.Errorf("value not restored: %s", rec.Body)
+	}
+
+	rec = serve(router, http.MethodPost, "/admin/backups/"+info.Name+"/restore")
+	if rec.Code != http.StatusConflict || rec.Header().Get("X-Restore-Committed") != "0" {
+		t.Errorf("conflicting restore: %d %v", rec.Code, rec.Header())
+	}
+	for _, query := range []string{"mode=merge", "dry_run=maybe"} {
+		if rec := serve(router, http.MethodPost, "/admin/backups/"+info.Name+"/restore?"+query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s answered %d", query, rec.Code)
+		}
+	}
+}
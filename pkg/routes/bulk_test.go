@@ -0,0 +1,223 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vvjke314/vk-test-03-2025/pkg/handlers"
+)
+
+// importBody posts body to /_import with query and decodes the report
+func importBody(t *testing.T, router *chi.Mux, query, body string) (*httptest.ResponseRecorder, handlers.ImportReport) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/_import?"+query, strings.NewReader(body)))
+	var report handlers.ImportReport
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("report: %v", err)
+		}
+	}
+	return rec, report
+}
+
+func TestExportImport(t *testing.T) {
+	router := testRouter(t)
+
+	// enough records for several import chunks and export pages
+	var lines []string
+	for i := 0; i < 2500; i++ {
+		lines = append(lines, fmt.Sprintf(`{"key":"k%04d","value":{"n":%d}}`, i, i))
+	}
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	io.WriteString(zw, strings.Join(lines, "\n")+"\n")
+	zw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/_import?mode=overwrite", &gz)
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("import: %d %s", rec.Code, rec.Body)
+	}
+	var report handlers.ImportReport
+	json.Unmarshal(rec.Body.Bytes(), &report)
+	if report.Records != 2500 || report.Created != 2500 || report.Mode != "overwrite" {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/_export", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("export: %d %v", rec.Code, rec.Header())
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Split(strings.TrimSuffix(string(exported), "\n"), "\n"); strings.Join(got, "\n") != strings.Join(lines, "\n") {
+		t.Errorf("export returned %d lines, want the %d imported", len(got), len(lines))
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/_export?prefix=k001", nil))
+	if got := strings.Count(rec.Body.String(), "\n"); got != 10 || rec.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("prefix export: %d lines, %s", got, rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestExportImportExpiryAndFlags(t *testing.T) {
+	router := testRouter(t)
+	at := time.Now().Add(time.Hour).UTC().Truncate(time.Millisecond).Format(time.RFC3339Nano)
+	body := fmt.Sprintf(`{"key":"a","value":1,"expires_at":%q,"flags":7}`+"\n"+`{"key":"b","value":2}`+"\n", at)
+	if rec, report := importBody(t, router, "", body); rec.Code != http.StatusOK || report.Created != 2 {
+		t.Fatalf("import: %d %s", rec.Code, rec.Body)
+	}
+
+	// a record keeps expires_at and flags through export and import
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/_export", nil))
+	if rec.Body.String() != body {
+		t.Errorf("export = %s, want %s", rec.Body, body)
+	}
+
+	for field, line := range map[string]string{
+		"expires_at": `{"key":"c","value":1,"expires_at":"tomorrow"}`,
+		"flags":      `{"key":"c","value":1,"flags":-1}`,
+	} {
+		rec, _ := importBody(t, router, "", line)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"field":"`+field+`"`) {
+			t.Errorf("invalid %s: %d %s", field, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestImportModes(t *testing.T) {
+	router := testRouter(t)
+	importBody(t, router, "", `{"key":"a","value":1}`)
+	body := `{"key":"a","value":2}` + "\n\n" + `{"key":"b","value":2}`
+
+	rec, report := importBody(t, router, "dry_run=true", body)
+	if rec.Code != http.StatusOK || report.Created != 1 || report.Conflicts != 1 || report.ConflictKeys[0] != "a" {
+		t.Errorf("dry run: %d %+v", rec.Code, report)
+	}
+
+	rec, _ = importBody(t, router, "mode=fail-on-conflict", body)
+	if rec.Code != http.StatusConflict || rec.Header().Get("X-Import-Committed") != "0" {
+		t.Errorf("conflict: %d %v %s", rec.Code, rec.Header(), rec.Body)
+	}
+
+	rec, report = importBody(t, router, "mode=skip-existing", body)
+	if rec.Code != http.StatusOK || report.Records != 2 || report.Created != 1 || report.Skipped != 1 {
+		t.Errorf("skip existing: %d %+v", rec.Code, report)
+	}
+
+	rec, report = importBody(t, router, "mode=overwrite", body)
+	if rec.Code != http.StatusOK || report.Updated != 2 {
+		t.Errorf("overwrite: %d %+v", rec.Code, report)
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/kv/a", nil))
+	if !strings.Contains(rec.Body.String(), `"value":2`) {
+		t.Errorf("value not overwritten: %s", rec.Body)
+	}
+
+	for _, query := range []string{"mode=merge", "dry_run=maybe"} {
+		if rec, _ := importBody(t, router, query, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s answered %d", query, rec.Code)
+		}
+	}
+}
+
+func TestImportDryRunAcrossChunks(t *testing.T) {
+	// key a comes back 600 lines later, in the second chunk
+	var lines []string
+	for i := 0; i < 1200; i++ {
+		key := fmt.Sprintf("k%04d", i)
+		if i == 0 || i == 600 {
+			key = "a"
+		}
+		lines = append(lines, fmt.Sprintf(`{"key":%q,"value":%d}`, key, i))
+	}
+	body := strings.Join(lines, "\n")
+
+	for _, mode := range []string{"overwrite", "skip-existing"} {
+		router := testRouter(t)
+		_, dry := importBody(t, router, "dry_run=true&mode="+mode, body)
+		rec, real := importBody(t, router, "mode="+mode, body)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", mode, rec.Code, rec.Body)
+		}
+		if dry.Created != 1199 || dry.Created != real.Created || dry.Updated != real.Updated || dry.Skipped != real.Skipped {
+			t.Errorf("%s: dry run %+v, import %+v", mode, dry, real)
+		}
+	}
+
+	// the dry run stops after the chunk the import stops at
+	router := testRouter(t)
+	rec, dry := importBody(t, router, "dry_run=true", body)
+	if rec.Code != http.StatusOK || dry.Records != 1000 || dry.Conflicts != 1 || dry.ConflictKeys[0] != "a" {
+		t.Errorf("fail-on-conflict dry run: %d %+v", rec.Code, dry)
+	}
+	rec, _ = importBody(t, router, "", body)
+	if rec.Code != http.StatusConflict || rec.Header().Get("X-Import-Committed") != "500" {
+		t.Errorf("fail-on-conflict: %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestImportInvalidRecord(t *testing.T) {
+	router := testRouter(t)
+	body := `{"key":"a","value":1}` + "\n" + `{"key":"b","value":1,"ttl":5}` + "\n"
+
+	rec, _ := importBody(t, router, "", body)
+	if rec.Code != http.StatusBadRequest || rec.Header().Get("X-Import-Committed") != "0" {
+		t.Fatalf("invalid record: %d %v", rec.Code, rec.Header())
+	}
+	var problem struct {
+		Detail string `json:"detail"`
+		Errors []struct {
+			Field string `json:"field"`
+		} `json:"errors"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &problem)
+	if !strings.Contains(problem.Detail, "line 2") || len(problem.Errors) != 1 || problem.Errors[0].Field != "ttl" {
+		t.Errorf("unexpected problem %s", rec.Body)
+	}
+
+	// nothing before the invalid record is written
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/kv/a", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("record of a failed chunk was written: %d", rec.Code)
+	}
+}
+
+func TestBulkAuth(t *testing.T) {
+	router := SetupRoutes(Options{AdminToken: "secret", Logger: nopLogger{}})
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/_export", nil),
+		httptest.NewRequest(http.MethodPost, "/_import", strings.NewReader("")),
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s without token: %d", req.Method, req.URL.Path, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"net/http"
+)
+
+// openAPISpec describes every route registered in SetupRoutes,
+// TestOpenAPIRoutes keeps both in sync
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// docsPage renders openAPISpec with its own inline script and style, it
+// loads nothing but openapi.json
+//
+//go:embed docs.html
+var docsPage []byte
+
+// docsPolicy allows the page only its inline script and style, by hash, and
+// requests to this server
+var docsPolicy = "default-src 'none'; connect-src 'self'; script-src " + inlineHash(docsPage, "script") +
+	"; style-src " + inlineHash(docsPage, "style")
+
+// inlineHash returns the CSP source of the first inline element tag of page
+func inlineHash(page []byte, tag string) string {
+	_, body, _ := bytes.Cut(page, []byte("<"+tag+">"))
+	body, _, _ = bytes.Cut(body, []byte("</"+tag+">"))
+	sum := sha256.Sum256(body)
+	return "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+}
+
+// openAPIHandler handles GET /openapi.json
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+// docsHandler handles GET /docs
+func docsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", docsPolicy)
+	w.Write(docsPage)
+}
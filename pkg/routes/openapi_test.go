@@ -0,0 +1,333 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vvjke314/vk-test-03-2025/internal/backup"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+	"github.com/vvjke314/vk-test-03-2025/internal/repository/repotest"
+	"github.com/vvjke314/vk-test-03-2025/internal/usecases"
+	"github.com/vvjke314/vk-test-03-2025/internal/validation"
+	"github.com/vvjke314/vk-test-03-2025/pkg/handlers"
+)
+
+// testRouter builds routes over an empty repository with default limits
+func testRouter(t *testing.T) *chi.Mux {
+	t.Helper()
+	l := logger.NewWriterLogger(io.Discard)
+	uc := usecases.NewKeyValueUseCase(repotest.New(nil), validation.Rules{
+		MaxKeyLen:     256,
+		MaxValueBytes: 1 << 20,
+		KeyPattern:    regexp.MustCompile(`^[A-Za-z0-9._~:@-]*$`),
+		KeyCharset:    "A-Za-z0-9._~:@-",
+	})
+	health := handlers.NewHealthHandler(time.Second)
+	health.SetReady(true)
+	store, err := backup.NewStore(t.TempDir(), uc, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := 3 * time.Second
+	opts := Options{
+		UseCase: uc,
+		Health:  health,
+		Admin:   handlers.NewAdminHandler(l, l),
+		Backup:  handlers.NewBackupHandler(store, l, time.Minute),
+		Logger:  l,
+		MaxBody: 1<<20 + 64<<10,
+	}
+	opts.Timeouts.Get, opts.Timeouts.Create, opts.Timeouts.Update, opts.Timeouts.Delete = timeout, timeout, timeout, timeout
+	return SetupRoutes(opts)
+}
+
+// spec is the subset of OpenAPI used by the tests
+type spec struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas   map[string]*schema   `json:"schemas"`
+		Responses map[string]*response `json:"responses"`
+	} `json:"components"`
+}
+
+type operation struct {
+	RequestBody *struct {
+		Content map[string]struct {
+			Schema *schema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]*response `json:"responses"`
+}
+
+type response struct {
+	Ref     string `json:"$ref"`
+	Content map[string]struct {
+		Schema *schema `json:"schema"`
+	} `json:"content"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	Items                *schema            `json:"items"`
+	Enum                 []string           `json:"enum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Pattern              string             `json:"pattern"`
+}
+
+func loadSpec(t *testing.T) *spec {
+	t.Helper()
+	var s spec
+	if err := json.Unmarshal(openAPISpec, &s); err != nil {
+		t.Fatalf("openapi.json: %v", err)
+	}
+	return &s
+}
+
+func (s *spec) operation(t *testing.T, method, path string) *operation {
+	t.Helper()
+	raw, ok := s.Paths[path][strings.ToLower(method)]
+	if !ok {
+		t.Fatalf("%s %s is not documented", method, path)
+	}
+	var op operation
+	if err := json.Unmarshal(raw, &op); err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	return &op
+}
+
+func (s *spec) resolve(sc *schema) *schema {
+	for sc != nil && sc.Ref != "" {
+		sc = s.Components.Schemas[strings.TrimPrefix(sc.Ref, "#/components/schemas/")]
+	}
+	return sc
+}
+
+// validate checks value against the JSON schema keywords used in openapi.json
+func (s *spec) validate(sc *schema, value interface{}, path string) error {
+	sc = s.resolve(sc)
+	if sc == nil {
+		return nil
+	}
+
+	switch sc.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: want object, got %T", path, value)
+		}
+		for _, name := range sc.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s.%s: required", path, name)
+			}
+		}
+		var extra *schema
+		if len(sc.AdditionalProperties) > 0 && string(sc.AdditionalProperties) != "true" && string(sc.AdditionalProperties) != "false" {
+			json.Unmarshal(sc.AdditionalProperties, &extra)
+		}
+		for name, v := range obj {
+			prop, ok := sc.Properties[name]
+			switch {
+			case ok:
+			case extra != nil:
+				prop = extra
+			case string(sc.AdditionalProperties) == "false":
+				return fmt.Errorf("%s.%s: unknown property", path, name)
+			}
+			if err := s.validate(prop, v, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: want array, got %T", path, value)
+		}
+		for i, v := range arr {
+			if err := s.validate(sc.Items, v, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%s: want integer, got %v", path, value)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: want string, got %T", path, value)
+		}
+		if sc.MinLength != nil && len(str) < *sc.MinLength || sc.MaxLength != nil && len(str) > *sc.MaxLength {
+			return fmt.Errorf("%s: length %d out of bounds", path, len(str))
+		}
+		if sc.Pattern != "" && !regexp.MustCompile(sc.Pattern).MatchString(str) {
+			return fmt.Errorf("%s: %q does not match %s", path, str, sc.Pattern)
+		}
+		if len(sc.Enum) > 0 && !contains(sc.Enum, str) {
+			return fmt.Errorf("%s: %q not in %v", path, str, sc.Enum)
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// specPath converts a chi route pattern into an OpenAPI path
+func specPath(route string) string {
+	if len(route) > 1 {
+		route = strings.TrimSuffix(route, "/")
+	}
+	return route
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	s := loadSpec(t)
+
+	var registered []string
+	err := chi.Walk(testRouter(t), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		registered = append(registered, method+" "+specPath(route))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var documented []string
+	for path, item := range s.Paths {
+		for method := range item {
+			if method != "parameters" {
+				documented = append(documented, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+
+	sort.Strings(registered)
+	sort.Strings(documented)
+	if strings.Join(registered, "\n") != strings.Join(documented, "\n") {
+		t.Errorf("routes and openapi.json differ\nregistered:\n%s\ndocumented:\n%s",
+			strings.Join(registered, "\n"), strings.Join(documented, "\n"))
+	}
+}
+
+func TestOpenAPIConformance(t *testing.T) {
+	s := loadSpec(t)
+	router := testRouter(t)
+
+	tests := []struct {
+		method, route, path, body string
+		valid                     bool // whether body conforms to the request schema
+	}{
+		{"POST", "/kv", "/kv", `{"key":"user:1","value":{"name":"a"}}`, true},
+		{"POST", "/kv", "/kv", `{"key":"user:1","value":1}`, true},
+		{"POST", "/kv", "/kv", `{"key":"user:2"}`, false},
+		{"POST", "/kv", "/kv", `{"value":1}`, false},
+		{"POST", "/kv", "/kv", `{"key":"user:2","value":1,"ttl":5}`, false},
+		{"POST", "/kv", "/kv", `{"key":" user","value":1}`, false},
+		{"POST", "/kv", "/kv", `{"key":"","value":1}`, false},
+		{"GET", "/kv/{id}", "/kv/user:1", "", true},
+		{"GET", "/kv/{id}", "/kv/missing", "", true},
+		{"PUT", "/kv/{id}", "/kv/user:1", `{"value":[1,2]}`, true},
+		{"PUT", "/kv/{id}", "/kv/user:1", `{"value":1,"key":"x"}`, false},
+		{"PUT", "/kv/{id}", "/kv/missing", `{"value":1}`, true},
+		{"DELETE", "/kv/{id}", "/kv/user:1", "", true},
+		{"DELETE", "/kv/{id}", "/kv/user:1", "", true},
+		{"GET", "/healthz", "/healthz", "", true},
+		{"GET", "/readyz", "/readyz", "", true},
+		{"GET", "/admin/loglevel", "/admin/loglevel", "", true},
+		{"PUT", "/admin/loglevel", "/admin/loglevel", `{"level":"debug"}`, true},
+		{"PUT", "/admin/loglevel", "/admin/loglevel", `{"level":"verbose"}`, false},
+		{"GET", "/admin/backups", "/admin/backups", "", true},
+		{"POST", "/admin/backups", "/admin/backups", "", true},
+		{"GET", "/admin/backups/{name}", "/admin/backups/missing", "", true},
+		{"GET", "/admin/backups/{name}", "/admin/backups/vault-20250301T120000.000Z.backup.gz", "", true},
+		{"POST", "/admin/backups/{name}/restore", "/admin/backups/vault-20250301T120000.000Z.backup.gz/restore", "", true},
+		{"GET", "/openapi.json", "/openapi.json", "", true},
+	}
+
+	for _, tt := range tests {
+		name := tt.method + " " + tt.path + " " + tt.body
+		op := s.operation(t, tt.method, tt.route)
+
+		if op.RequestBody != nil {
+			var body interface{}
+			json.Unmarshal([]byte(tt.body), &body)
+			err := s.validate(op.RequestBody.Content["application/json"].Schema, body, "body")
+			if (err == nil) != tt.valid {
+				t.Errorf("%s: request validation = %v, want valid %v", name, err, tt.valid)
+			}
+		}
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+		if !tt.valid && rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: invalid request answered %d", name, rec.Code)
+		}
+
+		resp, ok := op.Responses[strconv.Itoa(rec.Code)]
+		if !ok {
+			t.Errorf("%s: status %d is not documented", name, rec.Code)
+			continue
+		}
+		if resp.Ref != "" {
+			resp = s.Components.Responses[strings.TrimPrefix(resp.Ref, "#/components/responses/")]
+		}
+		contentType, _, _ := strings.Cut(rec.Header().Get("Content-Type"), ";")
+		media, ok := resp.Content[contentType]
+		if !ok {
+			t.Errorf("%s: content type %q is not documented for %d", name, contentType, rec.Code)
+			continue
+		}
+		var body interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Errorf("%s: response is not JSON: %v", name, err)
+			continue
+		}
+		if err := s.validate(media.Schema, body, "response"); err != nil {
+			t.Errorf("%s: response %d does not match the spec: %v", name, rec.Code, err)
+		}
+	}
+}
+
+func TestDocsPage(t *testing.T) {
+	rec := httptest.NewRecorder()
+	testRouter(t).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("docs: %d", rec.Code)
+	}
+
+	// the page loads nothing from other hosts, the policy allows its own code
+	if page := rec.Body.String(); strings.Contains(page, "://") {
+		t.Errorf("docs page refers to another host")
+	}
+	policy := rec.Header().Get("Content-Security-Policy")
+	for _, tag := range []string{"script", "style"} {
+		if hash := inlineHash(rec.Body.Bytes(), tag); !strings.Contains(policy, tag+"-src "+hash) {
+			t.Errorf("policy %q does not allow the inline %s", policy, tag)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vvjke314/vk-test-03-2025/config"
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+	"github.com/vvjke314/vk-test-03-2025/internal/ratelimit"
+	"github.com/vvjke314/vk-test-03-2025/pkg/handlers"
+)
+
+// clientKey identifies the client a request is accounted to. Credentials
+// only count once an authenticator verified them, anything else a client
+// sends could be changed on every request to get a fresh budget.
+func clientKey(r *http.Request, cfg config.RateLimits) string {
+	if cfg.KeyBy == "apikey" {
+		if principal, ok := principalFromContext(r.Context()); ok {
+			return ratelimit.PrincipalKey(principal)
+		}
+	}
+	return ratelimit.IPKey(clientIP(r, cfg.TrustedProxies))
+}
+
+// clientIP returns the address of the client. Forwarding headers are only
+// read from trusted proxies. Every proxy appends the address it got the
+// request from to X-Forwarded-For, so the list is walked from the right and
+// the first address not of a trusted proxy is the client's, entries to its
+// left are up to the client.
+func clientIP(r *http.Request, trusted []netip.Prefix) string {
+	peer, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	addr := peer.Addr().Unmap()
+	if !isTrusted(addr, trusted) {
+		return addr.String()
+	}
+
+	forwarded := r.Header.Values("X-Forwarded-For")
+	if len(forwarded) == 0 {
+		if realIP, err := netip.ParseAddr(r.Header.Get("X-Real-IP")); err == nil {
+			return realIP.Unmap().String()
+		}
+		return addr.String()
+	}
+	hops := strings.Split(strings.Join(forwarded, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		next, err := netip.ParseAddr(hop)
+		if err != nil {
+			// not written by a proxy, the last trusted hop stands for the client
+			return addr.String()
+		}
+		addr = next.Unmap()
+		if !isTrusted(addr, trusted) {
+			return addr.String()
+		}
+	}
+	// every hop is a trusted proxy
+	return addr.String()
+}
+
+// isTrusted tells whether addr belongs to a trusted proxy
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// rateLimitMiddleware answers 429 once the client's budget of op is spent.
+// RateLimit-* headers describe the budget on every response.
+func rateLimitMiddleware(limits *ratelimit.Limits, op ratelimit.Op, cfg config.RateLimits, l logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d := limits.Allow(clientKey(r, cfg), op)
+			if !d.Limited {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+			if !d.Allowed {
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(d.RetryAfter)))
+				handlers.WriteError(w, r, l, custom_errors.ErrRateLimited)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// concurrencyMiddleware sheds requests beyond the limit of op in flight
+// across all clients, protecting the Tarantool connection from overload
+func concurrencyMiddleware(limits *ratelimit.Limits, op ratelimit.Op, l logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			release, ok := limits.Acquire(op)
+			if !ok {
+				w.Header().Set("Retry-After", "1")
+				handlers.WriteError(w, r, l, custom_errors.ErrRateLimited)
+				return
+			}
+			defer release()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// kvLimits returns limiting middlewares of a /kv route accounted as op
+func kvLimits(opts Options, op ratelimit.Op) []func(http.Handler) http.Handler {
+	return []func(http.Handler) http.Handler{
+		rateLimitMiddleware(opts.Limits, op, opts.RateLimits, opts.Logger),
+		concurrencyMiddleware(opts.Limits, op, opts.Logger),
+	}
+}
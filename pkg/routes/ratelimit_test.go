@@ -0,0 +1,148 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/vvjke314/vk-test-03-2025/config"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+	"github.com/vvjke314/vk-test-03-2025/internal/ratelimit"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...logger.Field)        {}
+func (nopLogger) Info(string, ...logger.Field)         {}
+func (nopLogger) Warn(string, ...logger.Field)         {}
+func (nopLogger) Error(string, ...logger.Field)        {}
+func (l nopLogger) With(...logger.Field) logger.Logger { return l }
+
+func TestRateLimitMiddleware(t *testing.T) {
+	cfg := config.RateLimits{KeyBy: "apikey", Read: config.RateLimit{Rate: 1, Burst: 1},
+		APIKeys: map[string]string{"k1": "billing", "k2": "reports"}}
+	h := authenticateMiddleware("secret", cfg.APIKeys)(
+		rateLimitMiddleware(ratelimit.New(cfg), ratelimit.Get, cfg, nopLogger{})(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	request := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/kv/a", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := request("one"); rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("first request: %d %v", rec.Code, rec.Header())
+	}
+	// an unverified token does not buy a budget of its own
+	rec := request("two")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("second request: %d %v", rec.Code, rec.Header())
+	}
+	if rec := request("secret"); rec.Code != http.StatusOK {
+		t.Errorf("verified principal shares the address budget: %d", rec.Code)
+	}
+
+	// every configured API key gets a budget of its own
+	withKey := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/kv/a", nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if withKey("k1") != http.StatusOK || withKey("k2") != http.StatusOK {
+		t.Errorf("API key clients share a budget")
+	}
+	if code := withKey("k1"); code != http.StatusTooManyRequests {
+		t.Errorf("second request of a client: %d", code)
+	}
+	if code := withKey("unknown"); code != http.StatusTooManyRequests {
+		t.Errorf("unknown API key bought a budget: %d", code)
+	}
+}
+
+func TestConcurrencyMiddleware(t *testing.T) {
+	limits := ratelimit.New(config.RateLimits{MaxConcurrentReads: 1})
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := concurrencyMiddleware(limits, ratelimit.Get, nopLogger{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/kv/a", nil))
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/kv/a", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 beyond concurrency limit, got %d", rec.Code)
+	}
+
+	close(release)
+	<-done
+}
+
+func TestClientKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/kv/a", nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+	req.Header.Set("X-Forwarded-For", "6.6.6.6, 1.2.3.4, 10.0.0.2")
+	req.Header.Set("Authorization", "Bearer secret")
+
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/24")}
+	if got := clientKey(req, config.RateLimits{KeyBy: "ip"}); got != "ip:10.0.0.1" {
+		t.Errorf("untrusted proxy header used: %s", got)
+	}
+	if got := clientKey(req, config.RateLimits{KeyBy: "ip", TrustedProxies: proxies}); got != "ip:1.2.3.4" {
+		t.Errorf("rightmost untrusted address not used: %s", got)
+	}
+	if got := clientKey(req, config.RateLimits{KeyBy: "apikey"}); got != "ip:10.0.0.1" {
+		t.Errorf("unverified token used: %s", got)
+	}
+	verified := req.WithContext(context.WithValue(req.Context(), principalKey{}, adminPrincipal))
+	if got := clientKey(verified, config.RateLimits{KeyBy: "apikey"}); got != "principal:admin" {
+		t.Errorf("verified principal ignored: %s", got)
+	}
+	client := req.WithContext(context.WithValue(req.Context(), principalKey{}, clientPrincipal("billing")))
+	if got := clientKey(client, config.RateLimits{KeyBy: "apikey"}); got != "principal:client:billing" {
+		t.Errorf("API key client ignored: %s", got)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/24"), netip.MustParsePrefix("::1/128")}
+	cases := []struct {
+		remote, forwarded, realIP, want string
+	}{
+		{"1.2.3.4:80", "5.5.5.5", "", "1.2.3.4"},
+		{"10.0.0.1:80", "", "", "10.0.0.1"},
+		{"10.0.0.1:80", "", "5.5.5.5", "5.5.5.5"},
+		{"10.0.0.1:80", "5.5.5.5", "7.7.7.7", "5.5.5.5"},
+		{"10.0.0.1:80", "10.0.0.3, 10.0.0.2", "", "10.0.0.3"},
+		{"10.0.0.1:80", "1.1.1.1,2.2.2.2 , 10.0.0.2", "", "2.2.2.2"},
+		{"10.0.0.1:80", "junk, 10.0.0.2", "", "10.0.0.2"},
+		{"[::1]:80", "::ffff:5.5.5.5", "", "5.5.5.5"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/kv/a", nil)
+		req.RemoteAddr = c.remote
+		if c.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", c.forwarded)
+		}
+		if c.realIP != "" {
+			req.Header.Set("X-Real-IP", c.realIP)
+		}
+		if got := clientIP(req, proxies); got != c.want {
+			t.Errorf("%s via %q: got %s, want %s", c.remote, c.forwarded, got, c.want)
+		}
+	}
+}
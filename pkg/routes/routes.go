@@ -1,35 +1,261 @@
 package api
 
 import (
-	"log"
+	"context"
+	"crypto/subtle"
 	"net/http"
-	"os"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/vvjke314/vk-test-03-2025/config"
+	"github.com/vvjke314/vk-test-03-2025/internal/custom_errors"
+	"github.com/vvjke314/vk-test-03-2025/internal/i18n"
+	"github.com/vvjke314/vk-test-03-2025/internal/logger"
+	"github.com/vvjke314/vk-test-03-2025/internal/metrics"
+	"github.com/vvjke314/vk-test-03-2025/internal/ratelimit"
+	"github.com/vvjke314/vk-test-03-2025/internal/tracing"
 	"github.com/vvjke314/vk-test-03-2025/internal/usecases"
 	"github.com/vvjke314/vk-test-03-2025/pkg/handlers"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
-func SetupRoutes(uc *usecases.KeyValueUseCase) *chi.Mux {
+// Options groups dependencies and settings of HTTP routes
+type Options struct {
+	UseCase    *usecases.KeyValueUseCase
+	Health     *handlers.HealthHandler
+	Admin      *handlers.AdminHandler
+	Backup     *handlers.BackupHandler // Routes of /admin/backups are left out when nil
+	Logger     logger.Logger
+	Timeouts   config.RouteTimeouts
+	Limits     *ratelimit.Limits // Budgets shared with the other front ends, nil limits nothing
+	RateLimits config.RateLimits // How clients are told apart
+	MaxBody    int64             // Largest accepted request body in bytes, zero means unlimited
+	AdminToken string            // Bearer token required by /admin and bulk routes, empty disables the check
+}
+
+func SetupRoutes(opts Options) *chi.Mux {
 	r := chi.NewRouter()
 
-	r.Use(loggingMiddleware)
+	r.Use(requestIDMiddleware)
+	r.Use(tracingMiddleware)
+	r.Use(metricsMiddleware(opts.Logger))
+	r.Use(authenticateMiddleware(opts.AdminToken, opts.RateLimits.APIKeys))
 
-	r.Route("/kv", func(r chi.Router) {
-		logger := log.New(os.Stdout, "KV_HANDLER: ", log.LstdFlags)
-		handler := handlers.NewKVHandler(uc, logger)
-		r.Post("/", handler.CreateKeyHandler)
-		r.Put("/{id}", handler.UpdateKeyHandler)
-		r.Get("/{id}", handler.GetKeyHandler)
-		r.Delete("/{id}", handler.DeleteKeyHandler)
+	// bulk routes stream bodies of any size, each record is limited instead
+	r.Group(func(r chi.Router) {
+		r.Use(adminAuthMiddleware(opts.AdminToken, opts.Logger))
+		handler := handlers.NewBulkHandler(opts.UseCase, opts.Logger, handlers.BulkOptions{
+			PageTimeout:  opts.Timeouts.Get,
+			ChunkTimeout: opts.Timeouts.Update,
+			MaxRecord:    opts.MaxBody,
+		})
+		r.Get("/_export", handler.ExportHandler)
+		r.Post("/_import", handler.ImportHandler)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(bodyLimitMiddleware(opts.MaxBody, opts.Logger))
+
+		r.Get("/healthz", opts.Health.LiveHandler)
+		r.Get("/readyz", opts.Health.ReadyHandler)
+		r.Method(http.MethodGet, "/metrics", promhttp.Handler())
+		r.Get("/openapi.json", openAPIHandler)
+		r.Get("/docs", docsHandler)
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(adminAuthMiddleware(opts.AdminToken, opts.Logger))
+			r.Get("/loglevel", opts.Admin.GetLogLevelHandler)
+			r.Put("/loglevel", opts.Admin.SetLogLevelHandler)
+			if opts.Backup != nil {
+				r.Post("/backups", opts.Backup.CreateHandler)
+				r.Get("/backups", opts.Backup.ListHandler)
+				r.Get("/backups/{name}", opts.Backup.VerifyHandler)
+				r.Post("/backups/{name}/restore", opts.Backup.RestoreHandler)
+			}
+		})
+
+		r.Route("/kv", func(r chi.Router) {
+			handler := handlers.NewKVHandler(opts.UseCase, opts.Logger)
+			r.With(kvLimits(opts, ratelimit.Create)...).With(timeoutMiddleware(opts.Timeouts.Create)).Post("/", handler.CreateKeyHandler)
+			r.With(kvLimits(opts, ratelimit.Update)...).With(timeoutMiddleware(opts.Timeouts.Update)).Put("/{id}", handler.UpdateKeyHandler)
+			r.With(kvLimits(opts, ratelimit.Get)...).With(timeoutMiddleware(opts.Timeouts.Get)).Get("/{id}", handler.GetKeyHandler)
+			r.With(kvLimits(opts, ratelimit.Delete)...).With(timeoutMiddleware(opts.Timeouts.Delete)).Delete("/{id}", handler.DeleteKeyHandler)
+		})
 	})
 
 	return r
 }
 
-func loggingMiddleware(next http.Handler) http.Handler {
+// requestIDMiddleware takes X-Request-ID from the client or generates one,
+// echoes it in the response and stores it in the context for log correlation
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !logger.ValidRequestID(id) {
+			id = uuid.NewString()
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(logger.ContextWithRequestID(r.Context(), id)))
+	})
+}
+
+// tracingMiddleware continues the trace from traceparent header or starts a new
+// one, the span is named after the matched route once routing is done
+func tracingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s", r.Method, r.URL.Path)
-		next.ServeHTTP(w, r)
+		ctx, span := tracing.StartServer(r.Context(), r.Header, r.Method,
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+		)
+		defer span.End()
+
+		tracing.Inject(ctx, w.Header())
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		if route := chi.RouteContext(r.Context()).RoutePattern(); route != "" {
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(attribute.String("http.route", route))
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
 	})
 }
+
+// adminPrincipal is the principal of requests bearing the admin token
+const adminPrincipal = "admin"
+
+// clientPrincipal is the principal of requests bearing the API key of the
+// named client, it never equals adminPrincipal
+func clientPrincipal(name string) string {
+	return "client:" + name
+}
+
+type principalKey struct{}
+
+// principalFromContext returns the principal verified by authenticateMiddleware
+func principalFromContext(ctx context.Context) (string, bool) {
+	principal, ok := ctx.Value(principalKey{}).(string)
+	return principal, ok
+}
+
+// authenticateMiddleware stores the principal of a request with
+// Authorization: Bearer <token> or with one of apiKeys in X-API-Key in the
+// context, the admin token wins. Requests without valid credentials pass on
+// anonymously, routes requiring them use adminAuthMiddleware.
+func authenticateMiddleware(token string, apiKeys map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got := r.Header.Get("Authorization")
+			if token != "" && subtle.ConstantTimeCompare([]byte(got), []byte("Bearer "+token)) == 1 {
+				r = r.WithContext(context.WithValue(r.Context(), principalKey{}, adminPrincipal))
+			} else if name, ok := lookupAPIKey(apiKeys, r.Header.Get("X-API-Key")); ok {
+				r = r.WithContext(context.WithValue(r.Context(), principalKey{}, clientPrincipal(name)))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// lookupAPIKey returns the client name of key comparing it with every
+// configured key in constant time
+func lookupAPIKey(apiKeys map[string]string, key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	var found string
+	for candidate, name := range apiKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(candidate)) == 1 {
+			found = name
+		}
+	}
+	return found, found != ""
+}
+
+// adminAuthMiddleware requires the admin token verified by
+// authenticateMiddleware when token is set
+func adminAuthMiddleware(token string, l logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if principal, _ := principalFromContext(r.Context()); token != "" && principal != adminPrincipal {
+				handlers.WriteError(w, r, l, custom_errors.ErrUnauthenticated)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// bodyLimitMiddleware rejects bodies declared larger than limit and cuts
+// longer ones while they are read, so no handler buffers more than limit
+func bodyLimitMiddleware(limit int64, l logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				handlers.WriteError(w, r, l, custom_errors.NewTooLargeError("body", i18n.MsgBodyTooLarge, limit))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// timeoutMiddleware sets a deadline on the request context,
+// handlers answer 504 when storage calls exceed it
+func timeoutMiddleware(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// metricsMiddleware writes an access log record for every request and records
+// its count and latency labeled by route pattern, so /kv/{id} is a single
+// series for all keys
+func metricsMiddleware(l logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			route := chi.RouteContext(r.Context()).RoutePattern()
+			if route == "" {
+				route = "unmatched"
+			}
+
+			duration := time.Since(start)
+			metrics.ObserveHTTP(route, r.Method, status, duration)
+			logger.FromContext(r.Context(), l).Info("http request",
+				logger.F("method", r.Method),
+				logger.F("path", r.URL.Path),
+				logger.F("route", route),
+				logger.F("status", status),
+				logger.F("duration_ms", duration.Milliseconds()),
+			)
+		})
+	}
+}
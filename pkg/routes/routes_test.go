@@ -0,0 +1,39 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	h := bodyLimitMiddleware(10, nopLogger{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		}
+	}))
+
+	declared := httptest.NewRequest(http.MethodPost, "/kv", strings.NewReader(strings.Repeat("x", 11)))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, declared)
+	if rec.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rec.Body.String(), `"code":"too_large"`) {
+		t.Errorf("declared large body: %d %s", rec.Code, rec.Body)
+	}
+
+	// chunked body without Content-Length is cut while reading
+	chunked := httptest.NewRequest(http.MethodPost, "/kv", io.MultiReader(strings.NewReader(strings.Repeat("x", 11))))
+	chunked.ContentLength = -1
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, chunked)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("undeclared large body: %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/kv", strings.NewReader("small")))
+	if rec.Code != http.StatusOK {
+		t.Errorf("small body rejected: %d", rec.Code)
+	}
+}
@@ -13,6 +13,16 @@ box.schema.func.create('key_check', {
     ]]
 })
 
+-- Custom function to count stored keys
+box.schema.func.create('key_count', {
+    if_not_exists = true,
+    body = [[
+    function()
+        return box.space.vault:len()
+    end
+    ]]
+})
+
 
 -- Initing database
 box.once("init", function()
@@ -29,3 +39,302 @@ box.once("init", function()
     box.schema.user.grant('go-api', 'execute', 'function', 'key_check')
  end)
 
+box.once("key_count", function()
+    box.schema.user.grant('go-api', 'execute', 'function', 'key_count')
+end)
+
+-- Connection pool detects master and replicas through box.info
+box.once("pool_roles", function()
+    box.schema.func.create('box.info', { if_not_exists = true })
+    box.schema.user.grant('go-api', 'execute', 'function', 'box.info')
+end)
+
+-- Functions below treat a tuple whose expires_at (milliseconds since the
+-- Unix epoch) has passed as missing, the expiry fiber deletes it later.
+-- Nothing yields between their checks and writes, so each is atomic.
+
+-- Returns key, value, version, expiry and flags of a stored key
+local key_gets_body = [[
+function(key)
+    local t = box.space.vault:get({ key })
+    if t == nil or (t[3] ~= nil and t[3] <= require('clock').realtime() * 1000) then
+        return
+    end
+    local v = box.space.vault_versions:get({ key })
+    return { t[1], t[2], v and v[2] or 0, t[3] or box.NULL, t[4] or box.NULL }
+end
+]]
+
+-- Replaces value, expiry and flags only while the key still has the given
+-- version
+local key_cas_body = [[
+function(key, value, version, expires_at, flags)
+    local t = box.space.vault:get({ key })
+    if t == nil or (t[3] ~= nil and t[3] <= require('clock').realtime() * 1000) then
+        return 'not_found'
+    end
+    local v = box.space.vault_versions:get({ key })
+    if (v and v[2] or 0) ~= version then
+        return 'conflict'
+    end
+    box.space.vault:replace({ key, value, expires_at, flags })
+    return 'ok'
+end
+]]
+
+-- Bulk import of a chunk of records { key, value, expires_at, flags } in
+-- one transaction, expires_at and flags are nil when the key has none.
+-- mode is fail-on-conflict, overwrite or skip-existing; a dry run only counts.
+-- Returns created, updated, skipped, the number of conflicts and up to 100
+-- conflicting keys; in fail-on-conflict mode the chunk is rolled back on the
+-- first conflict unless it is a dry run. A dry run counts a key repeated in
+-- the chunk as existing once an earlier row would have written it, as the
+-- real import does.
+local key_import_body = [[
+function(records, mode, dry_run)
+    local now = require('clock').realtime() * 1000
+    local created, updated, skipped, conflicts = 0, 0, 0, 0
+    local keys = setmetatable({}, { __serialize = 'array' })
+    local written = {}
+    local function apply()
+        for _, r in ipairs(records) do
+            local t = box.space.vault:get({ r[1] })
+            local exists = written[r[1]] or (t ~= nil and (t[3] == nil or t[3] > now))
+            if not exists then
+                created = created + 1
+                written[r[1]] = true
+                if not dry_run then
+                    box.space.vault:replace({ r[1], r[2], r[3], r[4] })
+                end
+            elseif mode == 'overwrite' then
+                updated = updated + 1
+                written[r[1]] = true
+                if not dry_run then
+                    box.space.vault:replace({ r[1], r[2], r[3], r[4] })
+                end
+            elseif mode == 'skip-existing' then
+                skipped = skipped + 1
+            else
+                conflicts = conflicts + 1
+                if #keys < 100 then
+                    table.insert(keys, r[1])
+                end
+                if not dry_run then
+                    return
+                end
+            end
+        end
+    end
+
+    if dry_run then
+        apply()
+        return { created, updated, skipped, conflicts, keys }
+    end
+    box.begin()
+    local ok, err = pcall(apply)
+    if not ok or conflicts > 0 then
+        box.rollback()
+        if not ok then
+            error(err)
+        end
+        return { 0, 0, 0, conflicts, keys }
+    end
+    box.commit()
+    return { created, updated, skipped, conflicts, keys }
+end
+]]
+
+-- Adds a key unless it is stored
+local key_insert_body = [[
+function(key, value, expires_at, flags)
+    local t = box.space.vault:get({ key })
+    if t ~= nil and (t[3] == nil or t[3] > require('clock').realtime() * 1000) then
+        return 'exists'
+    end
+    box.space.vault:replace({ key, value, expires_at, flags })
+    return 'ok'
+end
+]]
+
+-- Replaces value, expiry and flags of a stored key
+local key_update_body = [[
+function(key, value, expires_at, flags)
+    local t = box.space.vault:get({ key })
+    if t == nil or (t[3] ~= nil and t[3] <= require('clock').realtime() * 1000) then
+        return 'not_found'
+    end
+    box.space.vault:replace({ key, value, expires_at, flags })
+    return 'ok'
+end
+]]
+
+-- Sets the expiry of a stored key, nil makes it persistent
+local key_expire_body = [[
+function(key, expires_at)
+    local t = box.space.vault:get({ key })
+    if t == nil or (t[3] ~= nil and t[3] <= require('clock').realtime() * 1000) then
+        return 'not_found'
+    end
+    box.space.vault:replace({ t[1], t[2], expires_at, t[4] })
+    return 'ok'
+end
+]]
+
+-- Creates function name with body, an existing one is dropped first and
+-- its privileges with it
+local function recreate(name, body)
+    if box.schema.func.exists(name) then
+        box.schema.func.drop(name)
+    end
+    box.schema.func.create(name, { body = body })
+    box.schema.user.grant('go-api', 'execute', 'function', name)
+end
+
+-- Per-key versions for compare-and-swap. They are drawn from one sequence,
+-- so a key deleted and created again never gets an old version back.
+box.once("versions", function()
+    box.schema.sequence.create('vault_version')
+    box.schema.space.create('vault_versions')
+    box.space.vault_versions:format({
+        { name = 'key', type = 'string' },
+        { name = 'version', type = 'unsigned' }
+    })
+    box.space.vault_versions:create_index('primary',
+        { parts = { 'key' } })
+    for _, t in box.space.vault:pairs() do
+        box.space.vault_versions:replace({ t[1], box.sequence.vault_version:next() })
+    end
+
+    box.schema.func.create('key_gets', { body = key_gets_body })
+    box.schema.func.create('key_cas', { body = key_cas_body })
+
+    -- every write of vault updates versions through the trigger below
+    box.schema.user.grant('go-api', 'read,write', 'space', 'vault_versions')
+    box.schema.user.grant('go-api', 'read,write', 'sequence', 'vault_version')
+    box.schema.user.grant('go-api', 'execute', 'function', 'key_gets')
+    box.schema.user.grant('go-api', 'execute', 'function', 'key_cas')
+end)
+
+box.once("import", function()
+    box.schema.func.create('key_import', { body = key_import_body })
+    box.schema.user.grant('go-api', 'execute', 'function', 'key_import')
+end)
+
+-- Keys may expire, vault gets an optional third field expires_at. Functions
+-- writing or reading vault in Lua are created anew to respect it.
+box.once("expiry", function()
+    box.space.vault:format({
+        { name = 'key', type = 'string' },
+        { name = 'value', type = 'string' },
+        { name = 'expires_at', type = 'unsigned', is_nullable = true }
+    })
+    box.space.vault:create_index('expires', {
+        parts = { { field = 'expires_at', type = 'unsigned', is_nullable = true, exclude_null = true } },
+        unique = false
+    })
+    recreate('key_gets', key_gets_body)
+    recreate('key_cas', key_cas_body)
+    recreate('key_import', key_import_body)
+    recreate('key_insert', key_insert_body)
+    recreate('key_update', key_update_body)
+    recreate('key_expire', key_expire_body)
+end)
+
+-- memcached clients store flags with values, vault gets an optional fourth
+-- field flags. Writes of other clients store none.
+box.once("flags", function()
+    box.space.vault:format({
+        { name = 'key', type = 'string' },
+        { name = 'value', type = 'string' },
+        { name = 'expires_at', type = 'unsigned', is_nullable = true },
+        { name = 'flags', type = 'unsigned', is_nullable = true }
+    })
+    recreate('key_gets', key_gets_body)
+    recreate('key_cas', key_cas_body)
+    recreate('key_insert', key_insert_body)
+    recreate('key_update', key_update_body)
+    recreate('key_expire', key_expire_body)
+end)
+
+-- key_import of a dry run counts keys repeated in a chunk like the import
+box.once("import_dry_run", function()
+    recreate('key_import', key_import_body)
+end)
+
+-- key_import writes the expiry of records, backups and exports carry it
+box.once("import_expiry", function()
+    recreate('key_import', key_import_body)
+end)
+
+-- key_import writes memcached flags of records as well
+box.once("import_flags", function()
+    recreate('key_import', key_import_body)
+end)
+
+-- Broadcast committed changes so that clients can invalidate their caches.
+-- Watchers only see the latest value, seq lets them detect missed events.
+local change_seq = 0
+box.space.vault:on_replace(function(old, new)
+    local key = (new or old)[1]
+    -- replicas receive versions of the master through replication
+    if box.session.type() ~= 'applier' then
+        if new == nil then
+            box.space.vault_versions:delete({ key })
+        else
+            box.space.vault_versions:replace({ key, box.sequence.vault_version:next() })
+        end
+    end
+    box.on_commit(function()
+        change_seq = change_seq + 1
+        box.broadcast('vault.changes', { seq = change_seq, key = key })
+    end)
+end)
+
+-- Deletes expired keys on the master in batches. The deletes go through the
+-- trigger above, so replicas, caches and watchers see them like any other.
+local clock = require('clock')
+local fiber = require('fiber')
+local log = require('log')
+
+local EXPIRY_INTERVAL = 1 -- seconds between sweeps
+local EXPIRY_BATCH = 1000 -- keys deleted in one transaction
+
+local function expire_batch()
+    local now = math.floor(clock.realtime() * 1000)
+    local keys = {}
+    for _, t in box.space.vault.index.expires:pairs({ now }, { iterator = 'LE' }) do
+        table.insert(keys, t[1])
+        if #keys == EXPIRY_BATCH then
+            break
+        end
+    end
+    box.atomic(function()
+        for _, key in ipairs(keys) do
+            local t = box.space.vault:get({ key })
+            -- the key may have been written again since it was found
+            if t ~= nil and t[3] ~= nil and t[3] <= now then
+                box.space.vault:delete({ key })
+            end
+        end
+    end)
+    return #keys
+end
+
+fiber.create(function()
+    fiber.name('vault_expiry')
+    while true do
+        local ok, n = true, 0
+        if not box.info.ro then
+            ok, n = pcall(expire_batch)
+            if not ok then
+                log.error('expiry failed: %s', n)
+            end
+        end
+        -- a full batch means more keys are due
+        if ok and n == EXPIRY_BATCH then
+            fiber.yield()
+        else
+            fiber.sleep(EXPIRY_INTERVAL)
+        end
+    end
+end)